@@ -0,0 +1,217 @@
+// Package apiclient is a client for the deps-dev REST API, used by the
+// command-line tools.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"deps-dev/handlers"
+	"deps-dev/storage"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Export formats
+const (
+	ExportCycloneDX    = "cyclonedx"
+	ExportSPDX         = "spdx"
+	ExportSPDXTagValue = "spdx-tag-value"
+	ExportCSV          = "csv"
+	ExportNDJSON       = "ndjson"
+	ExportJSON         = "json"
+)
+
+// Error is a response with an unexpected status. Message is the body the
+// server wrote, e.g. "dependency not found".
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("request failed: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("request failed: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// DefaultServer is the server the command-line tools use unless told
+// otherwise: DEPS_DEV_SERVER, or a local backend.
+func DefaultServer() string {
+	if server := os.Getenv("DEPS_DEV_SERVER"); server != "" {
+		return server
+	}
+	return "http://localhost:8080"
+}
+
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// ListOptions are the filters of GET /dependencies.
+type ListOptions struct {
+	Name     string
+	MinScore *float64
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	if o.Name != "" {
+		q.Set("name", o.Name)
+	}
+	if o.MinScore != nil {
+		q.Set("min_score", strconv.FormatFloat(*o.MinScore, 'f', -1, 64))
+	}
+	return q
+}
+
+func (c *Client) ListDependencies(ctx context.Context, opts ListOptions) ([]storage.Dependency, error) {
+	var deps []storage.Dependency
+	err := c.do(ctx, http.MethodGet, withQuery("/dependencies", opts.query()), nil, "", &deps)
+	return deps, err
+}
+
+func (c *Client) GetDependency(ctx context.Context, system, name, version string) (storage.Dependency, error) {
+	var dep storage.Dependency
+	err := c.do(ctx, http.MethodGet, dependencyPath(system, name, version), nil, "", &dep)
+	return dep, err
+}
+
+func (c *Client) CreateDependency(ctx context.Context, dep storage.Dependency) error {
+	return c.doJSON(ctx, http.MethodPost, "/dependencies", dep, nil)
+}
+
+func (c *Client) UpdateDependency(ctx context.Context, system, name, version string, update handlers.DependencyUpdateRequest) error {
+	return c.doJSON(ctx, http.MethodPut, dependencyPath(system, name, version), update, nil)
+}
+
+func (c *Client) DeleteDependency(ctx context.Context, system, name, version string) error {
+	return c.do(ctx, http.MethodDelete, dependencyPath(system, name, version), nil, "", nil)
+}
+
+// Refresh triggers a refresh of the default root package and waits for it.
+func (c *Client) Refresh(ctx context.Context, force bool) error {
+	path := "/dependencies/refresh"
+	if force {
+		path += "?force=true"
+	}
+	return c.do(ctx, http.MethodPost, path, nil, "", nil)
+}
+
+// Export writes the stored dependencies matching opts to w in one of the
+// export formats.
+func (c *Client) Export(ctx context.Context, format string, opts ListOptions, w io.Writer) error {
+	q := opts.query()
+	var path string
+	switch format {
+	case ExportCycloneDX:
+		path = "/export/cyclonedx"
+	case ExportSPDX:
+		path = "/export/spdx"
+	case ExportSPDXTagValue:
+		path = "/export/spdx"
+		q.Set("format", "tag-value")
+	case ExportCSV, ExportNDJSON, ExportJSON:
+		path = "/dependencies"
+		q.Set("format", format)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, withQuery(path, q), nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Check checks a package version against the policy with POST /check.
+func (c *Client) Check(ctx context.Context, req handlers.CheckRequest) (handlers.CheckResponse, error) {
+	var resp handlers.CheckResponse
+	err := c.doJSON(ctx, http.MethodPost, "/check", req, &resp)
+	return resp, err
+}
+
+// CheckManifest checks a manifest of the given type, e.g. npm-lockfile,
+// with POST /check.
+func (c *Client) CheckManifest(ctx context.Context, manifest string, body io.Reader) (handlers.CheckResponse, error) {
+	var resp handlers.CheckResponse
+	err := c.do(ctx, http.MethodPost, withQuery("/check", url.Values{"manifest": {manifest}}), body, "", &resp)
+	return resp, err
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, method, path, bytes.NewReader(body), "application/json", out)
+}
+
+// do sends a request and decodes the JSON response into out, if set.
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, contentType string, out any) error {
+	resp, err := c.send(ctx, method, path, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send sends a request and returns the response if its status is 2xx, or
+// an *Error otherwise.
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.BaseURL, "/")+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// dependencyPath addresses a dependency like handlers.DependencyRoute, with
+// slashes in names encoded.
+func dependencyPath(system, name, version string) string {
+	return "/dependencies/" + url.PathEscape(system) + "/" + url.PathEscape(name) + "/" + url.PathEscape(version)
+}
+
+func withQuery(path string, q url.Values) string {
+	if len(q) == 0 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
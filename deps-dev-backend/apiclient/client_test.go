@@ -0,0 +1,152 @@
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"deps-dev/handlers"
+	"deps-dev/storage"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// request is what the test server received
+type request struct {
+	Method string
+	URI    string
+	Body   string
+}
+
+func newTestServer(t *testing.T, status int, body string) (*Client, *request) {
+	got := &request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		*got = request{Method: r.Method, URI: r.URL.RequestURI(), Body: string(b)}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return &Client{BaseURL: server.URL + "/"}, got
+}
+
+func TestClient_Requests(t *testing.T) {
+	score := 5.5
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		body     string
+		call     func(c *Client) error
+		expected request
+	}{
+		{
+			name: "list with filters",
+			body: `[{"system":"NPM","name":"react","version":"18.2.0"}]`,
+			call: func(c *Client) error {
+				deps, err := c.ListDependencies(ctx, ListOptions{Name: "react", MinScore: &score})
+				assert.Equal(t, []storage.Dependency{{System: "NPM", Name: "react", Version: "18.2.0"}}, deps)
+				return err
+			},
+			expected: request{Method: http.MethodGet, URI: "/dependencies?min_score=5.5&name=react"},
+		},
+		{
+			name: "get slashed name",
+			body: `{"system":"NPM","name":"@babel/core","version":"7.22.0"}`,
+			call: func(c *Client) error {
+				dep, err := c.GetDependency(ctx, "NPM", "@babel/core", "7.22.0")
+				assert.Equal(t, "@babel/core", dep.Name)
+				return err
+			},
+			expected: request{Method: http.MethodGet, URI: "/dependencies/NPM/@babel%2Fcore/7.22.0"},
+		},
+		{
+			name: "create",
+			call: func(c *Client) error {
+				return c.CreateDependency(ctx, storage.Dependency{System: "NPM", Name: "left-pad", Version: "1.3.0", Relation: "DIRECT"})
+			},
+			expected: request{Method: http.MethodPost, URI: "/dependencies",
+				Body: `{"system":"NPM","name":"left-pad","version":"1.3.0","relation":"DIRECT"}`},
+		},
+		{
+			name: "update",
+			call: func(c *Client) error {
+				return c.UpdateDependency(ctx, "NPM", "left-pad", "1.3.0", handlers.DependencyUpdateRequest{OpenSSFScore: &score})
+			},
+			expected: request{Method: http.MethodPut, URI: "/dependencies/NPM/left-pad/1.3.0", Body: `{"openssf_score":5.5}`},
+		},
+		{
+			name:     "delete",
+			call:     func(c *Client) error { return c.DeleteDependency(ctx, "NPM", "left-pad", "1.3.0") },
+			expected: request{Method: http.MethodDelete, URI: "/dependencies/NPM/left-pad/1.3.0"},
+		},
+		{
+			name:     "forced refresh",
+			call:     func(c *Client) error { return c.Refresh(ctx, true) },
+			expected: request{Method: http.MethodPost, URI: "/dependencies/refresh?force=true"},
+		},
+		{
+			name: "check manifest",
+			body: `{"verdict":"PASS","checked":1,"violations":[],"waived":[]}`,
+			call: func(c *Client) error {
+				resp, err := c.CheckManifest(ctx, "npm-lockfile", bytes.NewBufferString("{}"))
+				assert.Equal(t, handlers.VerdictPass, resp.Verdict)
+				return err
+			},
+			expected: request{Method: http.MethodPost, URI: "/check?manifest=npm-lockfile", Body: "{}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, got := newTestServer(t, http.StatusOK, tt.body)
+
+			assert.NoError(t, tt.call(client))
+			assert.Equal(t, tt.expected, *got)
+		})
+	}
+}
+
+func TestClient_Export(t *testing.T) {
+	tests := []struct {
+		format      string
+		expectedURI string
+	}{
+		{ExportCycloneDX, "/export/cyclonedx?name=react"},
+		{ExportSPDX, "/export/spdx?name=react"},
+		{ExportSPDXTagValue, "/export/spdx?format=tag-value&name=react"},
+		{ExportCSV, "/dependencies?format=csv&name=react"},
+		{ExportNDJSON, "/dependencies?format=ndjson&name=react"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			client, got := newTestServer(t, http.StatusOK, "exported")
+
+			var out bytes.Buffer
+			assert.NoError(t, client.Export(context.Background(), tt.format, ListOptions{Name: "react"}, &out))
+			assert.Equal(t, tt.expectedURI, got.URI)
+			assert.Equal(t, "exported", out.String())
+		})
+	}
+
+	client, _ := newTestServer(t, http.StatusOK, "")
+	err := client.Export(context.Background(), "xlsx", ListOptions{}, io.Discard)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown export format "xlsx"`)
+}
+
+func TestClient_Error(t *testing.T) {
+	client, _ := newTestServer(t, http.StatusNotFound, "dependency not found\n")
+
+	_, err := client.GetDependency(context.Background(), "NPM", "left-pad", "1.3.0")
+
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "dependency not found", apiErr.Message)
+	}
+	assert.Equal(t, "request failed: 404 Not Found: dependency not found", err.Error())
+}
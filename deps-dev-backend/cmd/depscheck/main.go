@@ -0,0 +1,125 @@
+// Command depscheck checks a package version or a manifest against the
+// dependency policy of a deps-dev server, for use as a CI gate. It exits 0
+// if the check passes, 1 if it fails and 2 if it could not be run.
+//
+//	depscheck -purl pkg:npm/left-pad@1.3.0
+//	depscheck -system NPM -name left-pad -version 1.3.0
+//	depscheck -manifest npm-lockfile package-lock.json
+package main
+
+import (
+	"context"
+	"deps-dev/apiclient"
+	"deps-dev/handlers"
+	"deps-dev/storage"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	exitPass  = 0
+	exitFail  = 1
+	exitError = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("depscheck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: depscheck [flags] -purl PURL")
+		fmt.Fprintln(stderr, "       depscheck [flags] -system SYSTEM -name NAME -version VERSION")
+		fmt.Fprintln(stderr, "       depscheck [flags] -manifest TYPE FILE")
+		flags.PrintDefaults()
+	}
+
+	server := flags.String("server", apiclient.DefaultServer(), "deps-dev server URL (DEPS_DEV_SERVER)")
+	timeout := flags.Duration("timeout", 2*time.Minute, "how long to wait for the check")
+	asJSON := flags.Bool("json", false, "print the check response as JSON")
+	manifest := flags.String("manifest", "", "manifest type, as in the /imports routes, e.g. npm-lockfile or go-mod")
+	var req handlers.CheckRequest
+	flags.StringVar(&req.PURL, "purl", "", "package URL of the package version to check")
+	flags.StringVar(&req.System, "system", "", "system of the package to check, e.g. NPM")
+	flags.StringVar(&req.Name, "name", "", "name of the package to check")
+	flags.StringVar(&req.Version, "version", "", "version of the package to check")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	client := &apiclient.Client{BaseURL: *server}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var (
+		resp handlers.CheckResponse
+		err  error
+	)
+	if *manifest != "" {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return exitError
+		}
+		f, openErr := os.Open(flags.Arg(0))
+		if openErr != nil {
+			fmt.Fprintf(stderr, "depscheck: %v\n", openErr)
+			return exitError
+		}
+		defer f.Close()
+		resp, err = client.CheckManifest(ctx, *manifest, f)
+	} else {
+		if flags.NArg() != 0 || (req.PURL == "" && (req.System == "" || req.Name == "" || req.Version == "")) {
+			flags.Usage()
+			return exitError
+		}
+		resp, err = client.Check(ctx, req)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "depscheck: %v\n", err)
+		return exitError
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(resp)
+	} else {
+		report(stdout, resp)
+	}
+
+	if resp.Verdict != handlers.VerdictPass {
+		return exitFail
+	}
+	return exitPass
+}
+
+func report(w io.Writer, resp handlers.CheckResponse) {
+	fmt.Fprintf(w, "%s: %d violations, %d waived, in %d dependencies\n",
+		resp.Verdict, len(resp.Violations), len(resp.Waived), resp.Checked)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, v := range resp.Violations {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", v.Rule, packageKey(v), v.Reason)
+	}
+	for _, v := range resp.Waived {
+		fmt.Fprintf(tw, "  %s\t%s\t%s (waived by #%d)\n", v.Rule, packageKey(v), v.Reason, *v.WaiverID)
+	}
+	tw.Flush()
+
+	for _, issue := range resp.Issues {
+		fmt.Fprintf(w, "not checked: %s: %s\n", issue.Entry, issue.Reason)
+	}
+}
+
+func packageKey(v storage.PolicyViolation) string {
+	dep := v.Dependency
+	return fmt.Sprintf("%s %s@%s", dep.System, dep.Name, dep.Version)
+}
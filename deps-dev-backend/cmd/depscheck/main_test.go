@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	const failing = `{"verdict": "FAIL", "checked": 2, "violations": [{"rule": "direct-min-score",
+		"dependency": {"system": "NPM", "name": "left-pad", "version": "1.3.0"}, "reason": "OpenSSF score 3.5 is below 5"}],
+		"waived": [{"rule": "denied", "dependency": {"system": "NPM", "name": "event-stream", "version": "3.3.6"},
+		"reason": "package is denied", "waiver_id": 5}]}`
+	const passing = `{"verdict": "PASS", "checked": 1, "violations": [], "waived": []}`
+
+	var gotPath, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotBody = r.URL.RequestURI(), string(body)
+		switch {
+		case bytes.Contains(body, []byte("left-pad")):
+			w.Write([]byte(failing))
+		case bytes.Contains(body, []byte("bower")):
+			http.Error(w, "unknown system", http.StatusBadRequest)
+		default:
+			w.Write([]byte(passing))
+		}
+	}))
+	defer server.Close()
+
+	lockfile := filepath.Join(t.TempDir(), "package-lock.json")
+	assert.NoError(t, os.WriteFile(lockfile, []byte(`{"lockfileVersion": 3}`), 0o644))
+
+	tests := []struct {
+		name         string
+		args         []string
+		expectedCode int
+		expectedPath string
+		expectedBody string
+		expectedOut  []string
+	}{
+		{
+			name:         "failing package",
+			args:         []string{"-purl", "pkg:npm/left-pad@1.3.0"},
+			expectedCode: exitFail,
+			expectedPath: "/check",
+			expectedBody: `{"purl":"pkg:npm/left-pad@1.3.0"}`,
+			expectedOut: []string{
+				"FAIL: 1 violations, 1 waived, in 2 dependencies",
+				"direct-min-score  NPM left-pad@1.3.0",
+				"package is denied (waived by #5)",
+			},
+		},
+		{
+			name:         "passing package",
+			args:         []string{"-system", "npm", "-name", "react", "-version", "18.2.0"},
+			expectedCode: exitPass,
+			expectedPath: "/check",
+			expectedBody: `{"system":"npm","name":"react","version":"18.2.0"}`,
+			expectedOut:  []string{"PASS: 0 violations, 0 waived, in 1 dependencies"},
+		},
+		{
+			name:         "manifest",
+			args:         []string{"-manifest", "npm-lockfile", lockfile},
+			expectedCode: exitPass,
+			expectedPath: "/check?manifest=npm-lockfile",
+			expectedBody: `{"lockfileVersion": 3}`,
+		},
+		{
+			name:         "server error",
+			args:         []string{"-system", "bower", "-name", "jquery", "-version", "3.0.0"},
+			expectedCode: exitError,
+		},
+		{
+			name:         "missing package",
+			args:         []string{"-system", "npm"},
+			expectedCode: exitError,
+		},
+		{
+			name:         "missing manifest file",
+			args:         []string{"-manifest", "npm-lockfile", filepath.Join(t.TempDir(), "missing.json")},
+			expectedCode: exitError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPath, gotBody = "", ""
+			var stdout, stderr bytes.Buffer
+
+			code := run(append([]string{"-server", server.URL}, tt.args...), &stdout, &stderr)
+
+			assert.Equal(t, tt.expectedCode, code, stderr.String())
+			if tt.expectedPath != "" {
+				assert.Equal(t, tt.expectedPath, gotPath)
+				assert.Equal(t, tt.expectedBody, gotBody)
+			}
+			for _, out := range tt.expectedOut {
+				assert.Contains(t, stdout.String(), out)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"deps-dev/apiclient"
+	"deps-dev/handlers"
+	"deps-dev/storage"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+func runList(ctx context.Context, e *env, args []string) error {
+	flags := commandFlags("list")
+	opts := listFlags(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	deps, err := e.client.ListDependencies(ctx, opts())
+	if err != nil {
+		return err
+	}
+	return e.printDependencies(deps)
+}
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	system, name, version, err := parseKey(commandFlags("get"), args)
+	if err != nil {
+		return err
+	}
+
+	dep, err := e.client.GetDependency(ctx, system, name, version)
+	if err != nil {
+		return err
+	}
+	return e.printDependency(dep)
+}
+
+func runCreate(ctx context.Context, e *env, args []string) error {
+	flags := commandFlags("create")
+	relation := flags.String("relation", "", "SELF, DIRECT or INDIRECT")
+	sourceRepo := flags.String("source-repo", "", "source repository, e.g. github.com/user/repo")
+	var score scoreFlag
+	flags.Var(&score, "score", "OpenSSF score")
+
+	system, name, version, err := parseKey(flags, args)
+	if err != nil {
+		return err
+	}
+
+	dep := storage.Dependency{
+		System:       system,
+		Name:         name,
+		Version:      version,
+		Relation:     *relation,
+		SourceRepo:   *sourceRepo,
+		OpenSSFScore: score.score,
+	}
+	if err := e.client.CreateDependency(ctx, dep); err != nil {
+		return err
+	}
+	// The server canonicalizes the key, so the stored one may differ
+	return e.printStored(ctx, dep)
+}
+
+func runUpdate(ctx context.Context, e *env, args []string) error {
+	flags := commandFlags("update")
+	relation := flags.String("relation", "", "SELF, DIRECT or INDIRECT")
+	sourceRepo := flags.String("source-repo", "", "source repository, e.g. github.com/user/repo")
+	var score scoreFlag
+	flags.Var(&score, "score", "OpenSSF score")
+
+	system, name, version, err := parseKey(flags, args)
+	if err != nil {
+		return err
+	}
+
+	// Only the flags given are changed
+	var update handlers.DependencyUpdateRequest
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "relation":
+			update.Relation = relation
+		case "source-repo":
+			update.SourceRepo = sourceRepo
+		case "score":
+			update.OpenSSFScore = score.score
+		}
+	})
+	if update == (handlers.DependencyUpdateRequest{}) {
+		return fmt.Errorf("%w: nothing to update", errUsage)
+	}
+
+	if err := e.client.UpdateDependency(ctx, system, name, version, update); err != nil {
+		return err
+	}
+	return e.printStored(ctx, storage.Dependency{System: system, Name: name, Version: version})
+}
+
+func runDelete(ctx context.Context, e *env, args []string) error {
+	system, name, version, err := parseKey(commandFlags("delete"), args)
+	if err != nil {
+		return err
+	}
+
+	if err := e.client.DeleteDependency(ctx, system, name, version); err != nil {
+		return err
+	}
+	e.printStatus("deleted %s %s@%s", system, name, version)
+	return nil
+}
+
+func runRefresh(ctx context.Context, e *env, args []string) error {
+	flags := commandFlags("refresh")
+	force := flags.Bool("force", false, "re-fetch everything and start over")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	if err := e.client.Refresh(ctx, *force); err != nil {
+		return err
+	}
+	e.printStatus("refresh completed")
+	return nil
+}
+
+func runExport(ctx context.Context, e *env, args []string) error {
+	flags := commandFlags("export")
+	format := flags.String("format", "", "cyclonedx, spdx, spdx-tag-value, csv, ndjson or json")
+	file := flags.String("file", "", "file to write to instead of standard output")
+	opts := listFlags(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || *format == "" {
+		return errUsage
+	}
+
+	if *file == "" {
+		return e.client.Export(ctx, *format, opts(), e.out)
+	}
+
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	if err := e.client.Export(ctx, *format, opts(), f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// listFlags adds the list filters to flags and returns a function reading
+// them after parsing.
+func listFlags(flags *flag.FlagSet) func() apiclient.ListOptions {
+	name := flags.String("name", "", "only dependencies whose name contains NAME")
+	var minScore scoreFlag
+	flags.Var(&minScore, "min-score", "only dependencies scoring at least SCORE")
+	return func() apiclient.ListOptions {
+		return apiclient.ListOptions{Name: *name, MinScore: minScore.score}
+	}
+}
+
+// printStored fetches and prints a dependency after a change.
+func (e *env) printStored(ctx context.Context, dep storage.Dependency) error {
+	stored, err := e.client.GetDependency(ctx, dep.System, dep.Name, dep.Version)
+	if err != nil {
+		return err
+	}
+	return e.printDependency(stored)
+}
+
+// printDependency prints one dependency as a table row, or a JSON object.
+func (e *env) printDependency(dep storage.Dependency) error {
+	if e.json {
+		return e.printJSON(dep)
+	}
+	return e.printDependencies([]storage.Dependency{dep})
+}
+
+func (e *env) printDependencies(deps []storage.Dependency) error {
+	if e.json {
+		if deps == nil {
+			deps = []storage.Dependency{}
+		}
+		return e.printJSON(deps)
+	}
+
+	tw := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SYSTEM\tNAME\tVERSION\tRELATION\tSCORE\tDEPTH\tSOURCE REPO")
+	for _, dep := range deps {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			dep.System, dep.Name, dep.Version, orDash(dep.Relation), score(dep.OpenSSFScore), depth(dep.Depth), orDash(dep.SourceRepo))
+	}
+	return tw.Flush()
+}
+
+func (e *env) printJSON(v any) error {
+	enc := json.NewEncoder(e.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printStatus prints a confirmation in table output; JSON output stays
+// empty for commands without a result.
+func (e *env) printStatus(format string, args ...any) {
+	if !e.json {
+		fmt.Fprintf(e.out, format+"\n", args...)
+	}
+}
+
+func score(s *float64) string {
+	if s == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*s, 'f', -1, 64)
+}
+
+func depth(d int) string {
+	if d == 0 {
+		return "-"
+	}
+	return strconv.Itoa(d)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+// Command depsctl manages the dependencies stored by a deps-dev server
+// through its REST API.
+//
+//	depsctl list -name react -min-score 5
+//	depsctl get NPM @babel/core 7.22.0
+//	depsctl create -relation DIRECT NPM left-pad 1.3.0
+//	depsctl update -score 6.5 NPM left-pad 1.3.0
+//	depsctl delete NPM left-pad 1.3.0
+//	depsctl refresh -force
+//	depsctl export -format spdx -file sbom.spdx.json
+//
+// Dependencies are printed as a table, or as JSON with -output json.
+package main
+
+import (
+	"context"
+	"deps-dev/apiclient"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// errUsage is returned by commands called with invalid arguments
+var errUsage = errors.New("invalid usage")
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, env *env, args []string) error
+}
+
+var commands = map[string]command{
+	"list":    {"list [-name NAME] [-min-score SCORE]", runList},
+	"get":     {"get SYSTEM NAME VERSION", runGet},
+	"create":  {"create [-relation R] [-source-repo REPO] [-score S] SYSTEM NAME VERSION", runCreate},
+	"update":  {"update [-relation R] [-source-repo REPO] [-score S] SYSTEM NAME VERSION", runUpdate},
+	"delete":  {"delete SYSTEM NAME VERSION", runDelete},
+	"refresh": {"refresh [-force]", runRefresh},
+	"export":  {"export -format FORMAT [-file FILE] [-name NAME] [-min-score SCORE]", runExport},
+}
+
+// env is what commands share: the client and where and how to print.
+type env struct {
+	client *apiclient.Client
+	out    io.Writer
+	json   bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("depsctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { usage(stderr, flags) }
+
+	server := flags.String("server", apiclient.DefaultServer(), "deps-dev server URL (DEPS_DEV_SERVER)")
+	output := flags.String("output", "table", "output format: table or json")
+	timeout := flags.Duration("timeout", 5*time.Minute, "how long to wait for the server")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if (*output != "table" && *output != "json") || flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "depsctl: unknown command %q\n", name)
+		flags.Usage()
+		return exitUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	e := &env{
+		client: &apiclient.Client{BaseURL: *server},
+		out:    stdout,
+		json:   *output == "json",
+	}
+	if err := cmd.run(ctx, e, flags.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(stderr, "usage: depsctl %s\n", cmd.usage)
+			return exitUsage
+		}
+		fmt.Fprintf(stderr, "depsctl %s: %v\n", name, err)
+		return exitError
+	}
+	return exitOK
+}
+
+func usage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(w, "usage: depsctl [flags] COMMAND [command flags] [args]")
+	fmt.Fprintln(w, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(w, "\nflags:")
+	flags.PrintDefaults()
+}
+
+// commandFlags returns a flag set for a command that reports errors through
+// run instead of printing them.
+func commandFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+// parseKey parses flags and then exactly the SYSTEM NAME VERSION arguments.
+func parseKey(flags *flag.FlagSet, args []string) (string, string, string, error) {
+	if err := flags.Parse(args); err != nil {
+		return "", "", "", fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 3 {
+		return "", "", "", errUsage
+	}
+	return flags.Arg(0), flags.Arg(1), flags.Arg(2), nil
+}
+
+// scoreFlag is an optional OpenSSF score flag, nil unless set.
+type scoreFlag struct{ score *float64 }
+
+func (f *scoreFlag) String() string {
+	if f.score == nil {
+		return ""
+	}
+	return fmt.Sprint(*f.score)
+}
+
+func (f *scoreFlag) Set(s string) error {
+	var score float64
+	if _, err := fmt.Sscan(strings.TrimSpace(s), &score); err != nil {
+		return fmt.Errorf("invalid score %q", s)
+	}
+	f.score = &score
+	return nil
+}
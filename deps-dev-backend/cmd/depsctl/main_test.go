@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	const leftPad = `{"system":"NPM","name":"left-pad","version":"1.3.0","relation":"DIRECT","openssf_score":3.5,"depth":1}`
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+
+		switch r.URL.Path {
+		case "/dependencies":
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+			w.Write([]byte(`[` + leftPad + `,{"system":"NPM","name":"react","version":"18.2.0","source_repo":"github.com/facebook/react"}]`))
+		case "/dependencies/NPM/left-pad/1.3.0":
+			if r.Method == http.MethodGet {
+				w.Write([]byte(leftPad))
+			}
+		case "/dependencies/NPM/missing/1.0.0":
+			http.Error(w, "dependency not found", http.StatusNotFound)
+		case "/export/spdx":
+			w.Write([]byte("SPDXVersion: SPDX-2.3\n"))
+		}
+	}))
+	defer server.Close()
+
+	exportFile := filepath.Join(t.TempDir(), "sbom.spdx")
+
+	tests := []struct {
+		name             string
+		args             []string
+		expectedCode     int
+		expectedRequests []string
+		expectedOut      string
+		expectedErr      string
+	}{
+		{
+			name:             "list as table",
+			args:             []string{"list", "-name", "e", "-min-score", "3"},
+			expectedRequests: []string{"GET /dependencies?min_score=3&name=e "},
+			expectedOut: "SYSTEM  NAME      VERSION  RELATION  SCORE  DEPTH  SOURCE REPO\n" +
+				"NPM     left-pad  1.3.0    DIRECT    3.5    1      -\n" +
+				"NPM     react     18.2.0   -         -      -      github.com/facebook/react\n",
+		},
+		{
+			name:             "get as JSON",
+			args:             []string{"-output", "json", "get", "NPM", "left-pad", "1.3.0"},
+			expectedRequests: []string{"GET /dependencies/NPM/left-pad/1.3.0 "},
+			expectedOut: "{\n  \"system\": \"NPM\",\n  \"name\": \"left-pad\",\n  \"version\": \"1.3.0\",\n" +
+				"  \"relation\": \"DIRECT\",\n  \"openssf_score\": 3.5,\n  \"depth\": 1\n}\n",
+		},
+		{
+			name: "create",
+			args: []string{"create", "-relation", "DIRECT", "-score", "3.5", "NPM", "left-pad", "1.3.0"},
+			expectedRequests: []string{
+				`POST /dependencies {"system":"NPM","name":"left-pad","version":"1.3.0","relation":"DIRECT","openssf_score":3.5}`,
+				"GET /dependencies/NPM/left-pad/1.3.0 ",
+			},
+		},
+		{
+			name: "update sends only the flags given",
+			args: []string{"update", "-source-repo", "", "NPM", "left-pad", "1.3.0"},
+			expectedRequests: []string{
+				`PUT /dependencies/NPM/left-pad/1.3.0 {"source_repo":""}`,
+				"GET /dependencies/NPM/left-pad/1.3.0 ",
+			},
+		},
+		{
+			name:             "delete",
+			args:             []string{"delete", "NPM", "left-pad", "1.3.0"},
+			expectedRequests: []string{"DELETE /dependencies/NPM/left-pad/1.3.0 "},
+			expectedOut:      "deleted NPM left-pad@1.3.0\n",
+		},
+		{
+			name:             "refresh",
+			args:             []string{"refresh", "-force"},
+			expectedRequests: []string{"POST /dependencies/refresh?force=true "},
+			expectedOut:      "refresh completed\n",
+		},
+		{
+			name:             "export to file",
+			args:             []string{"export", "-format", "spdx-tag-value", "-file", exportFile},
+			expectedRequests: []string{"GET /export/spdx?format=tag-value "},
+		},
+		{
+			name:             "server error",
+			args:             []string{"get", "NPM", "missing", "1.0.0"},
+			expectedCode:     exitError,
+			expectedRequests: []string{"GET /dependencies/NPM/missing/1.0.0 "},
+			expectedErr:      "depsctl get: request failed: 404 Not Found: dependency not found\n",
+		},
+		{
+			name:         "missing version",
+			args:         []string{"get", "NPM", "left-pad"},
+			expectedCode: exitUsage,
+			expectedErr:  "usage: depsctl get SYSTEM NAME VERSION\n",
+		},
+		{
+			name:         "nothing to update",
+			args:         []string{"update", "NPM", "left-pad", "1.3.0"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "invalid score",
+			args:         []string{"list", "-min-score", "high"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "unknown command",
+			args:         []string{"purge"},
+			expectedCode: exitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			var stdout, stderr bytes.Buffer
+
+			code := run(append([]string{"-server", server.URL}, tt.args...), &stdout, &stderr)
+
+			assert.Equal(t, tt.expectedCode, code, stderr.String())
+			assert.Equal(t, tt.expectedRequests, requests)
+			if tt.expectedOut != "" {
+				assert.Equal(t, tt.expectedOut, stdout.String())
+			}
+			if tt.expectedErr != "" {
+				assert.Equal(t, tt.expectedErr, stderr.String())
+			}
+		})
+	}
+
+	exported, err := os.ReadFile(exportFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "SPDXVersion: SPDX-2.3\n", string(exported))
+}
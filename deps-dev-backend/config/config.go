@@ -1,10 +1,200 @@
+// Package config loads the backend configuration from an optional YAML file
+// and environment variables, and validates it at startup.
 package config
 
+import (
+	"bytes"
+	"deps-dev/depsdev"
+	"deps-dev/providers"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults for settings missing from both the file and the environment
 const (
 	DefaultSystem  = "NPM"
 	DefaultPackage = "react"
 	DefaultVersion = "18.2.0"
 
 	BaseURL              = "https://api.deps.dev/v3"
+	DepsDevTimeout       = 10 * time.Second
 	DefaultMaxConcurrent = 10
+	DefaultFixturesDir   = "./data/fixtures"
+
+	DefaultPort         = 8080
+	DefaultSQLitePath   = "./data/app.db"
+	SQLiteReadConns     = 4
+	SQLiteBusyTimeoutMs = 5000
+
+	DefaultSchedule = "0 0 * * *"
+
+	RetryInterval    = time.Minute
+	RetryBatchSize   = 50
+	RetryMaxAttempts = 8
+	RetryBaseBackoff = 5 * time.Minute
+	RetryMaxBackoff  = 6 * time.Hour
+
+	// Pinned versions rarely change, project scorecards are re-run weekly
+	MetadataTTL = 7 * 24 * time.Hour
+	ScoreTTL    = 12 * time.Hour
+
+	RefreshBatchSize    = 100
+	RefreshResumeWindow = 24 * time.Hour
+)
+
+// Log formats
+const (
+	LogText = "text"
+	LogJSON = "json"
 )
+
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Database Database `yaml:"database"`
+	DepsDev  DepsDev  `yaml:"deps_dev"`
+	Refresh  Refresh  `yaml:"refresh"`
+	Retry    Retry    `yaml:"retry"`
+	Metadata Metadata `yaml:"metadata"`
+	Policy   Policy   `yaml:"policy"`
+	Log      Log      `yaml:"log"`
+}
+
+type Server struct {
+	Port int `yaml:"port"`
+	// CORSOrigins are the origins allowed to call the API from a browser,
+	// "*" for any.
+	CORSOrigins []string `yaml:"cors_origins"`
+}
+
+type Database struct {
+	Path        string        `yaml:"path"`
+	ReadConns   int           `yaml:"read_conns"`
+	BusyTimeout time.Duration `yaml:"busy_timeout"`
+}
+
+type DepsDev struct {
+	BaseURL string        `yaml:"base_url"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Mode is live, record or replay, see depsdev.NewTransport.
+	Mode     string `yaml:"mode"`
+	Fixtures string `yaml:"fixtures"`
+}
+
+// Package is a package version to refresh.
+type Package struct {
+	System  string `yaml:"system"`
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+type Refresh struct {
+	// Root is refreshed on startup, on schedule and by POST /dependencies/refresh.
+	Root      Package `yaml:"root"`
+	OnStartup bool    `yaml:"on_startup"`
+	// Scheduled keeps the refresh schedule of Root in line with Schedule,
+	// creating or updating it at startup; when off, the stored schedule of
+	// Root is disabled. Schedule is a cron expression in UTC, optionally
+	// prefixed with CRON_TZ=<zone>.
+	Scheduled     bool          `yaml:"scheduled"`
+	Schedule      string        `yaml:"schedule"`
+	MaxConcurrent int           `yaml:"max_concurrent"`
+	BatchSize     int           `yaml:"batch_size"`
+	ResumeWindow  time.Duration `yaml:"resume_window"`
+	MetadataTTL   time.Duration `yaml:"metadata_ttl"`
+	ScoreTTL      time.Duration `yaml:"score_ttl"`
+}
+
+type Retry struct {
+	Interval    time.Duration `yaml:"interval"`
+	BatchSize   int           `yaml:"batch_size"`
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseBackoff time.Duration `yaml:"base_backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+}
+
+type Metadata struct {
+	// Providers are tried in order, see providers.Chain.
+	Providers []string `yaml:"providers"`
+	MirrorURL string   `yaml:"mirror_url"`
+	Dataset   string   `yaml:"dataset"`
+}
+
+type Policy struct {
+	File string `yaml:"file"`
+}
+
+type Log struct {
+	Format string `yaml:"format"`
+	Level  string `yaml:"level"`
+}
+
+// Default is the configuration used without a file or environment variables.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:        DefaultPort,
+			CORSOrigins: []string{"http://localhost:5173"},
+		},
+		Database: Database{
+			Path:        DefaultSQLitePath,
+			ReadConns:   SQLiteReadConns,
+			BusyTimeout: SQLiteBusyTimeoutMs * time.Millisecond,
+		},
+		DepsDev: DepsDev{
+			BaseURL:  BaseURL,
+			Timeout:  DepsDevTimeout,
+			Mode:     depsdev.ModeLive,
+			Fixtures: DefaultFixturesDir,
+		},
+		Refresh: Refresh{
+			Root:          Package{System: DefaultSystem, Name: DefaultPackage, Version: DefaultVersion},
+			Schedule:      DefaultSchedule,
+			MaxConcurrent: DefaultMaxConcurrent,
+			BatchSize:     RefreshBatchSize,
+			ResumeWindow:  RefreshResumeWindow,
+			MetadataTTL:   MetadataTTL,
+			ScoreTTL:      ScoreTTL,
+		},
+		Retry: Retry{
+			Interval:    RetryInterval,
+			BatchSize:   RetryBatchSize,
+			MaxAttempts: RetryMaxAttempts,
+			BaseBackoff: RetryBaseBackoff,
+			MaxBackoff:  RetryMaxBackoff,
+		},
+		Metadata: Metadata{Providers: []string{providers.DepsDev}},
+		Log:      Log{Format: LogText, Level: "info"},
+	}
+}
+
+// Load reads the YAML file at path, if path is set, over the defaults, then
+// applies the environment variables looked up with getenv and validates the
+// result. All invalid settings are reported together in a *ValidationError.
+func Load(path string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+	}
+
+	problems := applyEnv(&cfg, getenv)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return Config{}, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
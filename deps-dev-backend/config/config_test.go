@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load("", env(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, Package{System: "NPM", Name: "react", Version: "18.2.0"}, cfg.Refresh.Root)
+}
+
+func TestLoad_FileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+server:
+  port: 9000
+deps_dev:
+  timeout: 30s
+refresh:
+  root: {system: pypi, name: requests, version: 2.31.0}
+  scheduled: true
+  schedule: CRON_TZ=Europe/Berlin 0 3 * * *
+metadata:
+  providers: [imported, deps.dev]
+log:
+  format: json
+`), 0o644))
+
+	cfg, err := Load(path, env(map[string]string{
+		"PORT":                   "9100",
+		"REFRESH_MAX_CONCURRENT": "4",
+		"CORS_ORIGINS":           "https://a.example.com, https://b.example.com",
+		"LOG_LEVEL":              " ",
+	}))
+	assert.NoError(t, err)
+
+	// The environment overrides the file, the file overrides the defaults
+	assert.Equal(t, 9100, cfg.Server.Port)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.Server.CORSOrigins)
+	assert.Equal(t, 30*time.Second, cfg.DepsDev.Timeout)
+	assert.Equal(t, Package{System: "pypi", Name: "requests", Version: "2.31.0"}, cfg.Refresh.Root)
+	assert.True(t, cfg.Refresh.Scheduled)
+	assert.Equal(t, 4, cfg.Refresh.MaxConcurrent)
+	assert.Equal(t, []string{"imported", "deps.dev"}, cfg.Metadata.Providers)
+	assert.Equal(t, LogJSON, cfg.Log.Format)
+	assert.Equal(t, "info", cfg.Log.Level)
+	assert.Equal(t, RetryMaxAttempts, cfg.Retry.MaxAttempts)
+}
+
+func TestLoad_FileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Load(filepath.Join(dir, "missing.yaml"), env(nil))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	path := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("server:\n  prot: 9000\n"), 0o644))
+	_, err = Load(path, env(nil))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "field prot not found")
+}
+
+func TestLoad_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected []string
+	}{
+		{
+			name: "malformed values",
+			env:  map[string]string{"PORT": "http", "WITH_DAILY_DATA_REFRESH": "yes", "RETRY_INTERVAL": "60"},
+			expected: []string{
+				`PORT: invalid integer "http"`,
+				`WITH_DAILY_DATA_REFRESH: invalid boolean "yes"`,
+				`RETRY_INTERVAL: invalid duration "60", expected e.g. 30s or 12h`,
+			},
+		},
+		{
+			name: "out of range",
+			env: map[string]string{
+				"PORT":                "70000",
+				"SQLITE_READ_CONNS":   "0",
+				"DEPS_DEV_TIMEOUT":    "-1s",
+				"RETRY_BASE_BACKOFF":  "1h",
+				"RETRY_MAX_BACKOFF":   "1m",
+				"REFRESH_SCORE_TTL":   "-1h",
+				"REFRESH_BATCH_SIZE":  "0",
+				"SQLITE_BUSY_TIMEOUT": "-5s",
+			},
+			expected: []string{
+				"server.port: must be between 1 and 65535",
+				"database.read_conns: must be at least 1",
+				"database.busy_timeout: must not be negative",
+				"deps_dev.timeout: must be positive",
+				"refresh.batch_size: must be at least 1",
+				"refresh.score_ttl: must not be negative",
+				"retry.max_backoff: must not be shorter than retry.base_backoff",
+			},
+		},
+		{
+			name: "invalid names and formats",
+			env: map[string]string{
+				"CORS_ORIGINS":      "localhost:5173",
+				"DEPS_DEV_BASE_URL": "api.deps.dev/v3",
+				"DEPS_DEV_MODE":     "playback",
+				"REFRESH_SYSTEM":    "bower",
+				"REFRESH_SCHEDULE":  "every day",
+				"LOG_FORMAT":        "xml",
+				"LOG_LEVEL":         "verbose",
+			},
+			expected: []string{
+				`server.cors_origins: invalid origin "localhost:5173", expected * or e.g. https://deps.example.com`,
+				`deps_dev.base_url: invalid URL "api.deps.dev/v3"`,
+				`deps_dev.mode: unknown deps.dev mode "playback", expected live, record or replay`,
+				"refresh.root: invalid package bower/react@18.2.0",
+				`refresh.schedule: invalid cron expression "every day": expected exactly 5 fields, found 2: [every day]`,
+				"log.format: must be text or json",
+				`log.level: not a valid logrus Level: "verbose"`,
+			},
+		},
+		{
+			name: "providers",
+			env:  map[string]string{"METADATA_PROVIDERS": "mirror,dataset,deps.dev,libraries.io,deps.dev"},
+			expected: []string{
+				`metadata.mirror_url: invalid URL "", required by the mirror provider`,
+				"metadata.dataset: is required by the dataset provider",
+				`metadata.providers: unknown provider "libraries.io", expected deps.dev, mirror, dataset or imported`,
+				`metadata.providers: "deps.dev" listed twice`,
+			},
+		},
+		{
+			name: "scheduled refresh without a graph source",
+			env:  map[string]string{"METADATA_PROVIDERS": "imported", "WITH_DAILY_DATA_REFRESH": "true"},
+			expected: []string{
+				"metadata.providers: refreshes need dependency graphs from deps.dev or mirror, or deps_dev.mode replay",
+			},
+		},
+		{
+			name:     "no providers",
+			env:      map[string]string{"METADATA_PROVIDERS": " , "},
+			expected: []string{"metadata.providers: at least one provider is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load("", env(tt.env))
+			var verr *ValidationError
+			if assert.ErrorAs(t, err, &verr) {
+				assert.Equal(t, tt.expected, verr.Problems)
+				assert.Contains(t, err.Error(), "invalid configuration: "+tt.expected[0])
+			}
+		})
+	}
+}
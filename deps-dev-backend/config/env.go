@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envVars maps environment variables to the settings they override. Empty
+// variables are ignored; lists are comma separated.
+var envVars = []struct {
+	name  string
+	field func(*Config) any
+}{
+	{"PORT", func(c *Config) any { return &c.Server.Port }},
+	{"CORS_ORIGINS", func(c *Config) any { return &c.Server.CORSOrigins }},
+	{"SQLITE_PATH", func(c *Config) any { return &c.Database.Path }},
+	{"SQLITE_READ_CONNS", func(c *Config) any { return &c.Database.ReadConns }},
+	{"SQLITE_BUSY_TIMEOUT", func(c *Config) any { return &c.Database.BusyTimeout }},
+	{"DEPS_DEV_BASE_URL", func(c *Config) any { return &c.DepsDev.BaseURL }},
+	{"DEPS_DEV_TIMEOUT", func(c *Config) any { return &c.DepsDev.Timeout }},
+	{"DEPS_DEV_MODE", func(c *Config) any { return &c.DepsDev.Mode }},
+	{"DEPS_DEV_FIXTURES", func(c *Config) any { return &c.DepsDev.Fixtures }},
+	{"REFRESH_SYSTEM", func(c *Config) any { return &c.Refresh.Root.System }},
+	{"REFRESH_PACKAGE", func(c *Config) any { return &c.Refresh.Root.Name }},
+	{"REFRESH_VERSION", func(c *Config) any { return &c.Refresh.Root.Version }},
+	{"WITH_INITIAL_DATA_REFRESH", func(c *Config) any { return &c.Refresh.OnStartup }},
+	{"WITH_DAILY_DATA_REFRESH", func(c *Config) any { return &c.Refresh.Scheduled }},
+	{"REFRESH_SCHEDULE", func(c *Config) any { return &c.Refresh.Schedule }},
+	{"REFRESH_MAX_CONCURRENT", func(c *Config) any { return &c.Refresh.MaxConcurrent }},
+	{"REFRESH_BATCH_SIZE", func(c *Config) any { return &c.Refresh.BatchSize }},
+	{"REFRESH_RESUME_WINDOW", func(c *Config) any { return &c.Refresh.ResumeWindow }},
+	{"REFRESH_METADATA_TTL", func(c *Config) any { return &c.Refresh.MetadataTTL }},
+	{"REFRESH_SCORE_TTL", func(c *Config) any { return &c.Refresh.ScoreTTL }},
+	{"RETRY_INTERVAL", func(c *Config) any { return &c.Retry.Interval }},
+	{"RETRY_BATCH_SIZE", func(c *Config) any { return &c.Retry.BatchSize }},
+	{"RETRY_MAX_ATTEMPTS", func(c *Config) any { return &c.Retry.MaxAttempts }},
+	{"RETRY_BASE_BACKOFF", func(c *Config) any { return &c.Retry.BaseBackoff }},
+	{"RETRY_MAX_BACKOFF", func(c *Config) any { return &c.Retry.MaxBackoff }},
+	{"METADATA_PROVIDERS", func(c *Config) any { return &c.Metadata.Providers }},
+	{"METADATA_MIRROR_URL", func(c *Config) any { return &c.Metadata.MirrorURL }},
+	{"METADATA_DATASET", func(c *Config) any { return &c.Metadata.Dataset }},
+	{"POLICY_FILE", func(c *Config) any { return &c.Policy.File }},
+	{"LOG_FORMAT", func(c *Config) any { return &c.Log.Format }},
+	{"LOG_LEVEL", func(c *Config) any { return &c.Log.Level }},
+}
+
+func applyEnv(cfg *Config, getenv func(string) string) []string {
+	var problems []string
+	for _, env := range envVars {
+		value := strings.TrimSpace(getenv(env.name))
+		if value == "" {
+			continue
+		}
+		if err := setField(env.field(cfg), value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", env.name, err))
+		}
+	}
+	return problems
+}
+
+func setField(field any, value string) error {
+	switch f := field.(type) {
+	case *string:
+		*f = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*f = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*f = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, expected e.g. 30s or 12h", value)
+		}
+		*f = d
+	case *[]string:
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*f = list
+	default:
+		panic(fmt.Sprintf("unsupported config field type %T", field))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"deps-dev/depsdev"
+	"deps-dev/ecosystem"
+	"deps-dev/providers"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// ValidationError lists every invalid setting, so all of them can be fixed
+// at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+func (c *Config) validate() []string {
+	var problems []string
+	check := func(ok bool, setting, format string, args ...any) {
+		if !ok {
+			problems = append(problems, setting+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port", "must be between 1 and 65535")
+	for _, origin := range c.Server.CORSOrigins {
+		check(origin == "*" || isHTTPURL(origin), "server.cors_origins", "invalid origin %q, expected * or e.g. https://deps.example.com", origin)
+	}
+
+	check(c.Database.Path != "", "database.path", "is required")
+	check(c.Database.ReadConns > 0, "database.read_conns", "must be at least 1")
+	check(c.Database.BusyTimeout >= 0, "database.busy_timeout", "must not be negative")
+
+	check(isHTTPURL(c.DepsDev.BaseURL), "deps_dev.base_url", "invalid URL %q", c.DepsDev.BaseURL)
+	check(c.DepsDev.Timeout > 0, "deps_dev.timeout", "must be positive")
+	if _, err := depsdev.NewTransport(c.DepsDev.Mode, c.DepsDev.Fixtures, nil); err != nil {
+		check(false, "deps_dev.mode", "%v", err)
+	}
+	check(c.DepsDev.Mode == depsdev.ModeLive || c.DepsDev.Fixtures != "", "deps_dev.fixtures", "is required in %s mode", c.DepsDev.Mode)
+
+	root := c.Refresh.Root
+	if _, _, _, err := ecosystem.Canonicalize(root.System, root.Name, root.Version); err != nil || root.Name == "" || root.Version == "" {
+		check(false, "refresh.root", "invalid package %s/%s@%s", root.System, root.Name, root.Version)
+	}
+	if _, err := cron.ParseStandard(c.Refresh.Schedule); err != nil {
+		check(false, "refresh.schedule", "invalid cron expression %q: %v", c.Refresh.Schedule, err)
+	}
+	check(c.Refresh.MaxConcurrent > 0, "refresh.max_concurrent", "must be at least 1")
+	check(c.Refresh.BatchSize > 0, "refresh.batch_size", "must be at least 1")
+	check(c.Refresh.ResumeWindow >= 0, "refresh.resume_window", "must not be negative")
+	check(c.Refresh.MetadataTTL >= 0, "refresh.metadata_ttl", "must not be negative")
+	check(c.Refresh.ScoreTTL >= 0, "refresh.score_ttl", "must not be negative")
+
+	check(c.Retry.Interval > 0, "retry.interval", "must be positive")
+	check(c.Retry.BatchSize > 0, "retry.batch_size", "must be at least 1")
+	check(c.Retry.MaxAttempts > 0, "retry.max_attempts", "must be at least 1")
+	check(c.Retry.BaseBackoff > 0, "retry.base_backoff", "must be positive")
+	check(c.Retry.MaxBackoff >= c.Retry.BaseBackoff, "retry.max_backoff", "must not be shorter than retry.base_backoff")
+
+	problems = append(problems, c.Metadata.validate()...)
+	if (c.Refresh.OnStartup || c.Refresh.Scheduled) && !c.Metadata.servesGraphs() && c.DepsDev.Mode != depsdev.ModeReplay {
+		check(false, "metadata.providers", "refreshes need dependency graphs from %s or %s, or deps_dev.mode %s",
+			providers.DepsDev, providers.Mirror, depsdev.ModeReplay)
+	}
+
+	check(c.Log.Format == LogText || c.Log.Format == LogJSON, "log.format", "must be %s or %s", LogText, LogJSON)
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		check(false, "log.level", "%v", err)
+	}
+
+	return problems
+}
+
+func (m *Metadata) validate() []string {
+	if len(m.Providers) == 0 {
+		return []string{"metadata.providers: at least one provider is required"}
+	}
+
+	var problems []string
+	seen := make(map[string]bool)
+	for _, name := range m.Providers {
+		switch {
+		case seen[name]:
+			problems = append(problems, fmt.Sprintf("metadata.providers: %q listed twice", name))
+		case name == providers.Mirror && !isHTTPURL(m.MirrorURL):
+			problems = append(problems, fmt.Sprintf("metadata.mirror_url: invalid URL %q, required by the mirror provider", m.MirrorURL))
+		case name == providers.Dataset && m.Dataset == "":
+			problems = append(problems, "metadata.dataset: is required by the dataset provider")
+		case name != providers.DepsDev && name != providers.Mirror && name != providers.Dataset && name != providers.Imported:
+			problems = append(problems, fmt.Sprintf("metadata.providers: unknown provider %q, expected %s, %s, %s or %s", name,
+				providers.DepsDev, providers.Mirror, providers.Dataset, providers.Imported))
+		}
+		seen[name] = true
+	}
+	return problems
+}
+
+// servesGraphs reports whether a provider serves dependency graphs.
+func (m *Metadata) servesGraphs() bool {
+	for _, name := range m.Providers {
+		if providers.ServesGraphs(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
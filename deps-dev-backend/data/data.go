@@ -3,9 +3,10 @@ package data
 import (
 	"context"
 	"deps-dev/depsdev"
+	"deps-dev/ecosystem"
 	"deps-dev/storage"
-	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -13,6 +14,13 @@ import (
 type Storage interface {
 	UpsertDependencies(ctx context.Context, deps []storage.Dependency) error
 	GetDependenciesMap(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error)
+	RecordFetchFailure(ctx context.Context, f storage.FetchFailure) error
+	ClearFetchFailures(ctx context.Context, deps []storage.Dependency) error
+	ResumableRefreshRun(ctx context.Context, system, name, version string, since time.Time) (*storage.RefreshRun, error)
+	CreateRefreshRun(ctx context.Context, system, name, version string, total int) (storage.RefreshRun, error)
+	RefreshRunItems(ctx context.Context, runID int64) (map[string]bool, error)
+	CheckpointRefreshRun(ctx context.Context, runID int64, deps []storage.Dependency) error
+	FinishRefreshRun(ctx context.Context, runID int64, status, errMsg string) error
 }
 
 type DepsDevAPI interface {
@@ -21,111 +29,312 @@ type DepsDevAPI interface {
 	GetScorecardData(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo
 }
 
+// PolicyEvaluator checks the stored dependencies against the active policy.
+type PolicyEvaluator interface {
+	Evaluate(ctx context.Context) (int, error)
+}
+
 type DataManager struct {
 	Store         Storage
 	API           DepsDevAPI
 	Log           *logrus.Logger
 	MaxConcurrent int
+	// BatchSize is how many dependencies are written and checkpointed at once.
+	BatchSize int
+	// ResumeWindow is how long an interrupted refresh can be resumed; older
+	// runs start over.
+	ResumeWindow time.Duration
+	// RetryDelay is how long a failed lookup waits in the retry queue before
+	// its first retry.
+	RetryDelay time.Duration
+	// MetadataTTL and ScoreTTL are how long fetched version metadata and
+	// scorecards are considered fresh; zero always re-fetches.
+	MetadataTTL time.Duration
+	ScoreTTL    time.Duration
+	// Policies, if set, is evaluated after every refresh and import.
+	Policies PolicyEvaluator
+
+	rootLocksMu sync.Mutex
+	rootLocks   map[string]chan struct{}
+}
+
+type RefreshOptions struct {
+	// Force re-fetches everything, ignoring MetadataTTL and ScoreTTL, and
+	// starts over instead of resuming an interrupted refresh.
+	Force bool
 }
 
-func (dm *DataManager) RefreshDependencies(ctx context.Context, system, name, version string) error {
+// RefreshDependencies fetches the dependency graph of a root package version
+// from deps.dev and stores every dependency in it. The root is addressed by
+// its canonical key, so "npm" refreshes the same run as "NPM". Refreshes of
+// the same root wait for each other, so only one of them writes to a run.
+func (dm *DataManager) RefreshDependencies(ctx context.Context, system, name, version string, opts RefreshOptions) error {
+	system, name, version, err := ecosystem.Canonicalize(system, name, version)
+	if err != nil {
+		dm.Log.WithError(err).Error("invalid refresh root")
+		return err
+	}
+
+	unlock, err := dm.lockRoot(ctx, storage.DependencyKey(system, name, version))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	dm.Log.Infof("Fetching dependencies for %s/%s@%s", system, name, version)
 
-	// Fetch data from deps.dev
-	fetchedDeps, err := dm.fetchDependenciesWithScores(ctx, system, name, version)
+	graph, err := dm.API.GetDependencyGraph(ctx, system, name, version)
 	if err != nil {
 		dm.Log.WithError(err).Error("failed to fetch dependencies")
 		return err
 	}
 
-	// Fetch existing records from DB
-	existingMap, err := dm.Store.GetDependenciesMap(ctx, fetchedDeps)
+	run, done, err := dm.startRefreshRun(ctx, system, name, version, len(graph.Nodes), opts)
 	if err != nil {
-		dm.Log.WithError(err).Error("failed to get existing dependencies")
+		dm.Log.WithError(err).Error("failed to start refresh run")
 		return err
 	}
 
-	// Merge only non-empty fields from incoming
-	var mergedDeps []storage.Dependency
-	for _, incoming := range fetchedDeps {
-		key := fmt.Sprintf("%s|%s|%s", incoming.System, incoming.Name, incoming.Version)
-
-		existing, found := existingMap[key]
-		if !found {
-			mergedDeps = append(mergedDeps, incoming)
-			continue
+	// Skip dependencies an interrupted run already wrote
+	depths := graph.Depths()
+	nodes := make([]depsdev.DependencyNode, 0, len(graph.Nodes))
+	for i, node := range graph.Nodes {
+		node.Depth = depths[i]
+		if !done[storage.DependencyKey(node.VersionKey.System, node.VersionKey.Name, node.VersionKey.Version)] {
+			nodes = append(nodes, node)
 		}
+	}
 
-		// Merge non-empty fields from incoming into existing
-		merged := existing
-		if incoming.Relation != "" {
-			merged.Relation = incoming.Relation
-		}
-		if incoming.SourceRepo != "" {
-			merged.SourceRepo = incoming.SourceRepo
-		}
-		if incoming.OpenSSFScore != nil {
-			merged.OpenSSFScore = incoming.OpenSSFScore
+	upserted, err := dm.runPipeline(ctx, nodes, pipelineOptions{
+		RefreshOptions: opts,
+		checkpoint: func(ctx context.Context, deps []storage.Dependency) error {
+			return dm.Store.CheckpointRefreshRun(ctx, run.ID, deps)
+		},
+	})
+	if err != nil {
+		// Record the failure even if ctx was cancelled, so the run can be resumed
+		if finishErr := dm.Store.FinishRefreshRun(context.WithoutCancel(ctx), run.ID, storage.RunStatusFailed, err.Error()); finishErr != nil {
+			dm.Log.WithError(finishErr).Error("failed to mark refresh run as failed")
 		}
-
-		mergedDeps = append(mergedDeps, merged)
+		return err
 	}
 
-	// Upsert into DB
-	if err := dm.Store.UpsertDependencies(ctx, mergedDeps); err != nil {
-		dm.Log.WithError(err).Error("failed to upsert dependencies to database")
+	if err := dm.Store.FinishRefreshRun(ctx, run.ID, storage.RunStatusCompleted, ""); err != nil {
+		dm.Log.WithError(err).Error("failed to mark refresh run as completed")
 		return err
 	}
 
-	dm.Log.Infof("Successfully upserted %d dependencies", len(mergedDeps))
+	dm.Log.Infof("Successfully upserted %d dependencies", upserted)
+	dm.evaluatePolicies(ctx)
 	return nil
 }
 
-func (dm *DataManager) fetchDependenciesWithScores(ctx context.Context, system, name, version string) ([]storage.Dependency, error) {
+// ImportDependencies stores dependencies parsed from a manifest or lockfile
+// and enriches them with source repos and scores from deps.dev. Dependencies
+// whose lookup fails are stored as they are and queued for retry.
+func (dm *DataManager) ImportDependencies(ctx context.Context, deps []storage.Dependency) (int, error) {
+	dm.Log.Infof("Importing %d dependencies", len(deps))
+
+	nodes := dm.canonicalNodes(deps)
+	imported, err := dm.runPipeline(ctx, nodes, pipelineOptions{keepFailed: true})
+	if err != nil {
+		dm.Log.WithError(err).Error("failed to import dependencies")
+		return imported, err
+	}
+
+	dm.Log.Infof("Successfully imported %d dependencies", imported)
+	dm.evaluatePolicies(ctx)
+	return imported, nil
+}
+
+// ResolvePackage fetches the dependency graph of a package version and looks
+// up every package in it, as a refresh would, without storing anything. The
+// dependencies are returned as if the package were added to the project: the
+// package itself as DIRECT and the rest of its graph as INDIRECT, one level
+// deeper.
+func (dm *DataManager) ResolvePackage(ctx context.Context, system, name, version string) ([]storage.Dependency, error) {
+	system, name, version, err := ecosystem.Canonicalize(system, name, version)
+	if err != nil {
+		return nil, err
+	}
+
 	graph, err := dm.API.GetDependencyGraph(ctx, system, name, version)
 	if err != nil {
 		return nil, err
 	}
 
-	var (
-		results []storage.Dependency
-		mu      sync.Mutex
-		wg      sync.WaitGroup
-		sem     = make(chan struct{}, 10)
-	)
+	depths := graph.Depths()
+	nodes := make([]depsdev.DependencyNode, 0, len(graph.Nodes))
+	for i, node := range graph.Nodes {
+		node.Relation, node.Depth = "INDIRECT", 0
+		if i == 0 {
+			node.Relation = "DIRECT"
+		}
+		// Depth 0 is unknown, except for the root
+		if i == 0 || depths[i] > 0 {
+			node.Depth = depths[i] + 1
+		}
+		nodes = append(nodes, node)
+	}
+
+	return dm.resolvePipeline(ctx, nodes)
+}
+
+// ResolveDependencies looks up source repos and scores of dependencies parsed
+// from a manifest, as an import would, without storing anything.
+func (dm *DataManager) ResolveDependencies(ctx context.Context, deps []storage.Dependency) ([]storage.Dependency, error) {
+	return dm.resolvePipeline(ctx, dm.canonicalNodes(deps))
+}
 
-	for _, node := range graph.Nodes {
-		wg.Add(1)
-		go func(node depsdev.DependencyNode) {
-			defer wg.Done()
+// canonicalNodes turns dependencies into graph nodes under their canonical
+// keys, so they match stored rows and policy rules. Dependencies spelled
+// differently but naming the same package version become one node, DIRECT
+// if any of them is. Dependencies with invalid keys are skipped.
+func (dm *DataManager) canonicalNodes(deps []storage.Dependency) []depsdev.DependencyNode {
+	seen := make(map[string]int)
+	nodes := make([]depsdev.DependencyNode, 0, len(deps))
+	for _, dep := range deps {
+		system, name, version, err := ecosystem.Canonicalize(dep.System, dep.Name, dep.Version)
+		if err != nil {
+			dm.Log.WithError(err).Warnf("skipping dependency %s/%s@%s", dep.System, dep.Name, dep.Version)
+			continue
+		}
 
-			select {
-			case sem <- struct{}{}:
-				defer func() { <-sem }()
-			case <-ctx.Done():
-				return
+		key := storage.DependencyKey(system, name, version)
+		if i, ok := seen[key]; ok {
+			if dep.Relation == "DIRECT" {
+				nodes[i].Relation = "DIRECT"
 			}
+			continue
+		}
+		seen[key] = len(nodes)
+		nodes = append(nodes, depsdev.DependencyNode{
+			VersionKey: depsdev.VersionKey{System: system, Name: name, Version: version},
+			Relation:   dep.Relation,
+			Depth:      dep.Depth,
+		})
+	}
+	return nodes
+}
 
-			meta, err := dm.API.GetPackageMetadata(ctx, node.VersionKey)
+// evaluatePolicies re-evaluates the policy after dependencies changed. A
+// failure leaves the previous violations in place and does not fail the
+// refresh or import that stored the dependencies.
+func (dm *DataManager) evaluatePolicies(ctx context.Context) {
+	if dm.Policies == nil {
+		return
+	}
+	if _, err := dm.Policies.Evaluate(ctx); err != nil {
+		dm.Log.WithError(err).Error("failed to evaluate policies")
+	}
+}
+
+// lockRoot waits until no other refresh of the root is running, or ctx is
+// done. It returns the function releasing the root.
+func (dm *DataManager) lockRoot(ctx context.Context, key string) (func(), error) {
+	dm.rootLocksMu.Lock()
+	if dm.rootLocks == nil {
+		dm.rootLocks = make(map[string]chan struct{})
+	}
+	lock, ok := dm.rootLocks[key]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		dm.rootLocks[key] = lock
+	}
+	dm.rootLocksMu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startRefreshRun resumes the latest failed run for the root package, or
+// starts a new one. It returns the keys of dependencies already processed.
+func (dm *DataManager) startRefreshRun(ctx context.Context, system, name, version string, total int, opts RefreshOptions) (storage.RefreshRun, map[string]bool, error) {
+	if !opts.Force && dm.ResumeWindow > 0 {
+		resumable, err := dm.Store.ResumableRefreshRun(ctx, system, name, version, time.Now().Add(-dm.ResumeWindow))
+		if err != nil {
+			return storage.RefreshRun{}, nil, err
+		}
+		if resumable != nil {
+			done, err := dm.Store.RefreshRunItems(ctx, resumable.ID)
 			if err != nil {
-				return
+				return storage.RefreshRun{}, nil, err
 			}
+			dm.Log.Infof("Resuming refresh run %d, %d of %d dependencies already processed", resumable.ID, len(done), total)
+			return *resumable, done, nil
+		}
+	}
+
+	run, err := dm.Store.CreateRefreshRun(ctx, system, name, version, total)
+	return run, nil, err
+}
+
+func mergeDependency(existingMap map[string]storage.Dependency, incoming storage.Dependency) storage.Dependency {
+	existing, found := existingMap[storage.DependencyKey(incoming.System, incoming.Name, incoming.Version)]
+	if !found {
+		return incoming
+	}
+	return storage.MergeDependency(existing, incoming)
+}
 
-			scorecard := dm.API.GetScorecardData(ctx, meta)
+func isFresh(fetchedAt *time.Time, ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && fetchedAt != nil && now.Sub(*fetchedAt) < ttl
+}
 
-			mu.Lock()
-			results = append(results, storage.Dependency{
-				System:       node.VersionKey.System,
-				Name:         node.VersionKey.Name,
-				Version:      node.VersionKey.Version,
-				Relation:     node.Relation,
-				SourceRepo:   scorecard.SourceRepo,
-				OpenSSFScore: scorecard.OpenSSFScore,
-			})
-			mu.Unlock()
-		}(node)
+func storedMetadata(dep storage.Dependency) *depsdev.PackageVersionMetadata {
+	meta := &depsdev.PackageVersionMetadata{Provider: dep.SourceRepoProvider}
+	if dep.SourceRepo != "" {
+		meta.RelatedProjects = []depsdev.RelatedProject{
+			{ProjectKey: depsdev.ProjectKey{ID: dep.SourceRepo}, RelationType: "SOURCE_REPO"},
+		}
 	}
+	return meta
+}
 
-	wg.Wait()
-	return results, nil
+// applyMetadata and applyScorecard copy looked-up values into dep, along with
+// the providers that supplied them.
+func applyMetadata(dep *storage.Dependency, meta *depsdev.PackageVersionMetadata) {
+	dep.License = meta.License()
+	if dep.License != "" {
+		dep.LicenseProvider = meta.Provider
+	}
+}
+
+func applyScorecard(dep *storage.Dependency, meta *depsdev.PackageVersionMetadata, scorecard depsdev.ScorecardInfo) {
+	dep.SourceRepo = scorecard.SourceRepo
+	if dep.SourceRepo != "" {
+		dep.SourceRepoProvider = meta.Provider
+	}
+	dep.OpenSSFScore = scorecard.OpenSSFScore
+	if dep.OpenSSFScore != nil {
+		dep.ScoreProvider = scorecard.Provider
+	}
+}
+
+func (dm *DataManager) recordFetchFailure(ctx context.Context, node depsdev.DependencyNode, stage string, fetchErr error) {
+	log := dm.Log.WithFields(logrus.Fields{
+		"system":  node.VersionKey.System,
+		"name":    node.VersionKey.Name,
+		"version": node.VersionKey.Version,
+		"stage":   stage,
+	})
+	log.WithError(fetchErr).Warn("queueing failed lookup for retry")
+
+	err := dm.Store.RecordFetchFailure(ctx, storage.FetchFailure{
+		System:        node.VersionKey.System,
+		Name:          node.VersionKey.Name,
+		Version:       node.VersionKey.Version,
+		Relation:      node.Relation,
+		Depth:         node.Depth,
+		Stage:         stage,
+		LastError:     fetchErr.Error(),
+		NextAttemptAt: time.Now().Add(dm.RetryDelay),
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to record fetch failure")
+	}
 }
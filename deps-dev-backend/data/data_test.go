@@ -2,11 +2,16 @@ package data_test
 
 import (
 	"context"
+	"deps-dev/config"
 	"deps-dev/data"
 	"deps-dev/depsdev"
+	"deps-dev/ecosystem"
 	"deps-dev/storage"
 	"errors"
+	"net/http"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -33,6 +38,18 @@ type mockStorage struct {
 	UpsertFn   func(ctx context.Context, deps []storage.Dependency) error
 	Upserted   []storage.Dependency
 	LastMerged *[]storage.Dependency
+
+	DueFn        func(ctx context.Context, now time.Time, limit int) ([]storage.FetchFailure, error)
+	Recorded     []storage.FetchFailure
+	Cleared      []storage.Dependency
+	Rescheduled  []storage.FetchFailure
+	failuresLock sync.Mutex
+
+	ResumableRun   *storage.RefreshRun
+	RunItems       map[string]bool
+	CreatedRuns    int
+	Checkpointed   []storage.Dependency
+	FinishedStatus string
 }
 
 func (m *mockStorage) GetDependenciesMap(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
@@ -45,6 +62,44 @@ func (m *mockStorage) UpsertDependencies(ctx context.Context, deps []storage.Dep
 	}
 	return m.UpsertFn(ctx, deps)
 }
+func (m *mockStorage) RecordFetchFailure(ctx context.Context, f storage.FetchFailure) error {
+	m.failuresLock.Lock()
+	defer m.failuresLock.Unlock()
+	m.Recorded = append(m.Recorded, f)
+	return nil
+}
+func (m *mockStorage) ClearFetchFailures(ctx context.Context, deps []storage.Dependency) error {
+	m.Cleared = append(m.Cleared, deps...)
+	return nil
+}
+func (m *mockStorage) DueFetchFailures(ctx context.Context, now time.Time, limit int) ([]storage.FetchFailure, error) {
+	return m.DueFn(ctx, now, limit)
+}
+func (m *mockStorage) RescheduleFetchFailure(ctx context.Context, f storage.FetchFailure) error {
+	m.Rescheduled = append(m.Rescheduled, f)
+	return nil
+}
+func (m *mockStorage) ResumableRefreshRun(ctx context.Context, system, name, version string, since time.Time) (*storage.RefreshRun, error) {
+	if m.ResumableRun == nil {
+		return nil, nil
+	}
+	return m.ResumableRun, nil
+}
+func (m *mockStorage) CreateRefreshRun(ctx context.Context, system, name, version string, total int) (storage.RefreshRun, error) {
+	m.CreatedRuns++
+	return storage.RefreshRun{ID: int64(m.CreatedRuns), System: system, Name: name, Version: version, Total: total}, nil
+}
+func (m *mockStorage) RefreshRunItems(ctx context.Context, runID int64) (map[string]bool, error) {
+	return m.RunItems, nil
+}
+func (m *mockStorage) CheckpointRefreshRun(ctx context.Context, runID int64, deps []storage.Dependency) error {
+	m.Checkpointed = append(m.Checkpointed, deps...)
+	return nil
+}
+func (m *mockStorage) FinishRefreshRun(ctx context.Context, runID int64, status, errMsg string) error {
+	m.FinishedStatus = status
+	return nil
+}
 
 func TestRefreshDependencies_Success(t *testing.T) {
 	score := 9.5
@@ -67,6 +122,7 @@ func TestRefreshDependencies_Success(t *testing.T) {
 						RelationType: "SOURCE_REPO",
 					},
 				},
+				Licenses: []string{"MIT"},
 			}, nil
 		},
 		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
@@ -97,7 +153,7 @@ func TestRefreshDependencies_Success(t *testing.T) {
 		MaxConcurrent: 5,
 	}
 
-	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0")
+	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0", data.RefreshOptions{})
 	assert.NoError(t, err)
 
 	assert.Len(t, capturedMerged, 1)
@@ -109,6 +165,58 @@ func TestRefreshDependencies_Success(t *testing.T) {
 	assert.Equal(t, "github.com/facebook/react", dep.SourceRepo)
 	assert.NotNil(t, dep.OpenSSFScore)
 	assert.Equal(t, 9.5, *dep.OpenSSFScore)
+	assert.Equal(t, "MIT", dep.License)
+}
+
+func TestRefreshDependencies_StoresDepths(t *testing.T) {
+	key := func(name string) depsdev.VersionKey {
+		return depsdev.VersionKey{System: "NPM", Name: name, Version: "1.0.0"}
+	}
+	api := &mockDepsDevAPI{
+		GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+			return &depsdev.DependencyGraph{
+				Nodes: []depsdev.DependencyNode{
+					{VersionKey: key("root"), Relation: "SELF"},
+					{VersionKey: key("a"), Relation: "DIRECT"},
+					{VersionKey: key("b"), Relation: "INDIRECT"},
+					{VersionKey: key("c"), Relation: "INDIRECT"},
+				},
+				Edges: []depsdev.DependencyEdge{
+					{FromNode: 0, ToNode: 1},
+					{FromNode: 1, ToNode: 2},
+					{FromNode: 2, ToNode: 3},
+				},
+			}, nil
+		},
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			return &depsdev.PackageVersionMetadata{}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{}
+		},
+	}
+
+	var written []storage.Dependency
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			written = append(written, deps...)
+			return nil
+		},
+	}
+
+	manager := &data.DataManager{API: api, Store: store, Log: logrus.New()}
+
+	err := manager.RefreshDependencies(context.Background(), "NPM", "root", "1.0.0", data.RefreshOptions{})
+	assert.NoError(t, err)
+
+	depths := make(map[string]int)
+	for _, dep := range written {
+		depths[dep.Name] = dep.Depth
+	}
+	assert.Equal(t, map[string]int{"root": 0, "a": 1, "b": 2, "c": 3}, depths)
 }
 
 func TestRefreshDependencies_MergesExistingFields(t *testing.T) {
@@ -172,7 +280,7 @@ func TestRefreshDependencies_MergesExistingFields(t *testing.T) {
 		MaxConcurrent: 5,
 	}
 
-	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0")
+	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0", data.RefreshOptions{})
 	assert.NoError(t, err)
 }
 
@@ -192,11 +300,41 @@ func TestRefreshDependencies_GraphError(t *testing.T) {
 		MaxConcurrent: 5,
 	}
 
-	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0")
+	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0", data.RefreshOptions{})
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "graph fetch failed")
 }
 
+func TestRefreshDependencies_CanonicalRoot(t *testing.T) {
+	t.Run("root key is canonicalized", func(t *testing.T) {
+		var requested []string
+		api := &mockDepsDevAPI{
+			GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+				requested = []string{system, name, version}
+				return nil, errors.New("graph fetch failed")
+			},
+		}
+		manager := &data.DataManager{API: api, Store: &mockStorage{}, Log: logrus.New()}
+
+		err := manager.RefreshDependencies(context.Background(), "pypi", "Django_REST", "3.14.0", data.RefreshOptions{})
+		assert.Error(t, err)
+		assert.Equal(t, []string{"PYPI", "django-rest", "3.14.0"}, requested)
+	})
+
+	t.Run("unknown system", func(t *testing.T) {
+		api := &mockDepsDevAPI{
+			GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+				t.Fatal("should not fetch an invalid root")
+				return nil, nil
+			},
+		}
+		manager := &data.DataManager{API: api, Store: &mockStorage{}, Log: logrus.New()}
+
+		err := manager.RefreshDependencies(context.Background(), "bower", "jquery", "3.0.0", data.RefreshOptions{})
+		assert.ErrorIs(t, err, ecosystem.ErrUnknownSystem)
+	})
+}
+
 func TestRefreshDependencies_GetMapError(t *testing.T) {
 	score := 8.0
 
@@ -241,7 +379,7 @@ func TestRefreshDependencies_GetMapError(t *testing.T) {
 		MaxConcurrent: 5,
 	}
 
-	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0")
+	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0", data.RefreshOptions{})
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "db map error")
 }
@@ -293,7 +431,701 @@ func TestRefreshDependencies_UpsertError(t *testing.T) {
 		MaxConcurrent: 5,
 	}
 
-	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0")
+	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0", data.RefreshOptions{})
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "upsert failed")
+	assert.Equal(t, storage.RunStatusFailed, store.FinishedStatus)
+	assert.Empty(t, store.Checkpointed)
+}
+
+func TestRefreshDependencies_QueuesFailedLookups(t *testing.T) {
+	score := 5.0
+	api := &mockDepsDevAPI{
+		GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+			return &depsdev.DependencyGraph{
+				Nodes: []depsdev.DependencyNode{
+					{VersionKey: depsdev.VersionKey{System: "npm", Name: "react", Version: "18.2.0"}, Relation: "SELF"},
+					{VersionKey: depsdev.VersionKey{System: "npm", Name: "loose-envify", Version: "1.4.0"}, Relation: "DIRECT"},
+					{VersionKey: depsdev.VersionKey{System: "npm", Name: "js-tokens", Version: "4.0.0"}, Relation: "INDIRECT"},
+				},
+			}, nil
+		},
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			if vk.Name == "loose-envify" {
+				return nil, errors.New("metadata timeout")
+			}
+			return &depsdev.PackageVersionMetadata{
+				RelatedProjects: []depsdev.RelatedProject{
+					{ProjectKey: depsdev.ProjectKey{ID: "github.com/" + vk.Name}, RelationType: "SOURCE_REPO"},
+				},
+			}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			repo := meta.RelatedProjects[0].ProjectKey.ID
+			if repo == "github.com/js-tokens" {
+				return depsdev.ScorecardInfo{SourceRepo: repo, Err: errors.New("project request failed")}
+			}
+			return depsdev.ScorecardInfo{SourceRepo: repo, OpenSSFScore: &score}
+		},
+	}
+
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			return nil
+		},
+	}
+
+	manager := &data.DataManager{
+		API:           api,
+		Store:         store,
+		Log:           logrus.New(),
+		MaxConcurrent: 5,
+		RetryDelay:    time.Minute,
+	}
+
+	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0", data.RefreshOptions{})
+	assert.NoError(t, err)
+
+	// The scorecard failure is still stored with its source repo, the metadata failure is not
+	assert.Len(t, store.Upserted, 2)
+
+	stages := map[string]string{}
+	for _, f := range store.Recorded {
+		stages[f.Name] = f.Stage
+		assert.True(t, f.NextAttemptAt.After(time.Now()))
+	}
+	assert.Equal(t, map[string]string{
+		"loose-envify": storage.FailureStageMetadata,
+		"js-tokens":    storage.FailureStageScorecard,
+	}, stages)
+
+	assert.Len(t, store.Cleared, 1)
+	assert.Equal(t, "react", store.Cleared[0].Name)
+}
+
+func TestRefreshDependencies_SkipsFreshLookups(t *testing.T) {
+	score := 8.1
+	recent := time.Now().Add(-time.Hour)
+	stale := time.Now().Add(-48 * time.Hour)
+
+	tests := []struct {
+		name              string
+		existing          storage.Dependency
+		force             bool
+		expectMetadata    bool
+		expectScorecard   bool
+		expectedScoreRepo string
+	}{
+		{
+			name: "metadata and score fresh",
+			existing: storage.Dependency{
+				SourceRepo: "github.com/facebook/react", MetadataFetchedAt: &recent, ScoreFetchedAt: &recent,
+			},
+		},
+		{
+			name: "score stale reuses stored source repo",
+			existing: storage.Dependency{
+				SourceRepo: "github.com/facebook/react", MetadataFetchedAt: &recent, ScoreFetchedAt: &stale,
+			},
+			expectScorecard:   true,
+			expectedScoreRepo: "github.com/facebook/react",
+		},
+		{
+			name: "metadata stale",
+			existing: storage.Dependency{
+				SourceRepo: "github.com/facebook/react", MetadataFetchedAt: &stale, ScoreFetchedAt: &recent,
+			},
+			expectMetadata:    true,
+			expectScorecard:   true,
+			expectedScoreRepo: "github.com/facebook/react-fetched",
+		},
+		{
+			name: "never fetched",
+			existing: storage.Dependency{
+				SourceRepo: "github.com/facebook/react",
+			},
+			expectMetadata:    true,
+			expectScorecard:   true,
+			expectedScoreRepo: "github.com/facebook/react-fetched",
+		},
+		{
+			name: "force ignores freshness",
+			existing: storage.Dependency{
+				SourceRepo: "github.com/facebook/react", MetadataFetchedAt: &recent, ScoreFetchedAt: &recent,
+			},
+			force:             true,
+			expectMetadata:    true,
+			expectScorecard:   true,
+			expectedScoreRepo: "github.com/facebook/react-fetched",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metadataCalled, scorecardCalled bool
+			api := &mockDepsDevAPI{
+				GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+					return &depsdev.DependencyGraph{
+						Nodes: []depsdev.DependencyNode{
+							{VersionKey: depsdev.VersionKey{System: "npm", Name: "react", Version: "18.2.0"}, Relation: "SELF"},
+						},
+					}, nil
+				},
+				GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+					metadataCalled = true
+					return &depsdev.PackageVersionMetadata{
+						RelatedProjects: []depsdev.RelatedProject{
+							{ProjectKey: depsdev.ProjectKey{ID: "github.com/facebook/react-fetched"}, RelationType: "SOURCE_REPO"},
+						},
+					}, nil
+				},
+				GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+					scorecardCalled = true
+					repo := meta.RelatedProjects[0].ProjectKey.ID
+					assert.Equal(t, tt.expectedScoreRepo, repo)
+					return depsdev.ScorecardInfo{SourceRepo: repo, OpenSSFScore: &score}
+				},
+			}
+
+			existing := tt.existing
+			existing.System, existing.Name, existing.Version = "npm", "react", "18.2.0"
+			store := &mockStorage{
+				GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+					return map[string]storage.Dependency{"npm|react|18.2.0": existing}, nil
+				},
+				UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+					return nil
+				},
+			}
+
+			manager := &data.DataManager{
+				API:           api,
+				Store:         store,
+				Log:           logrus.New(),
+				MaxConcurrent: 5,
+				MetadataTTL:   24 * time.Hour,
+				ScoreTTL:      24 * time.Hour,
+			}
+
+			err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0", data.RefreshOptions{Force: tt.force})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectMetadata, metadataCalled)
+			assert.Equal(t, tt.expectScorecard, scorecardCalled)
+
+			assert.Len(t, store.Upserted, 1)
+			dep := store.Upserted[0]
+			assert.Equal(t, "SELF", dep.Relation)
+			if tt.expectMetadata {
+				assert.True(t, dep.MetadataFetchedAt.After(recent))
+			} else {
+				assert.Equal(t, existing.MetadataFetchedAt, dep.MetadataFetchedAt)
+			}
+			if tt.expectScorecard {
+				assert.True(t, dep.ScoreFetchedAt.After(recent))
+			} else {
+				assert.Equal(t, existing.ScoreFetchedAt, dep.ScoreFetchedAt)
+			}
+		})
+	}
+}
+
+func graphOf(names ...string) *depsdev.DependencyGraph {
+	graph := &depsdev.DependencyGraph{}
+	for _, name := range names {
+		graph.Nodes = append(graph.Nodes, depsdev.DependencyNode{
+			VersionKey: depsdev.VersionKey{System: "npm", Name: name, Version: "1.0.0"},
+			Relation:   "INDIRECT",
+		})
+	}
+	return graph
+}
+
+func TestRefreshDependencies_WritesInBatches(t *testing.T) {
+	api := &mockDepsDevAPI{
+		GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+			return graphOf("a", "b", "c", "d", "e"), nil
+		},
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			if vk.Name == "c" {
+				return nil, errors.New("metadata timeout")
+			}
+			return &depsdev.PackageVersionMetadata{}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{}
+		},
+	}
+
+	var (
+		lookups    int
+		batchSizes []int
+	)
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			lookups++
+			assert.LessOrEqual(t, len(deps), 2)
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			batchSizes = append(batchSizes, len(deps))
+			return nil
+		},
+	}
+
+	manager := &data.DataManager{
+		API:           api,
+		Store:         store,
+		Log:           logrus.New(),
+		MaxConcurrent: 2,
+		BatchSize:     2,
+	}
+
+	err := manager.RefreshDependencies(context.Background(), "npm", "root", "1.0.0", data.RefreshOptions{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, lookups)
+	// The failed lookup is checkpointed but not written
+	total := 0
+	for _, size := range batchSizes {
+		assert.LessOrEqual(t, size, 2)
+		total += size
+	}
+	assert.Equal(t, 4, total)
+	assert.Len(t, store.Checkpointed, 5)
+	assert.Equal(t, 1, store.CreatedRuns)
+	assert.Equal(t, storage.RunStatusCompleted, store.FinishedStatus)
+}
+
+func TestRefreshDependencies_ResumesInterruptedRun(t *testing.T) {
+	tests := []struct {
+		name            string
+		force           bool
+		expectedFetched []string
+		expectedCreated int
+	}{
+		{
+			name:            "resume skips processed dependencies",
+			expectedFetched: []string{"c"},
+			expectedCreated: 0,
+		},
+		{
+			name:            "force starts over",
+			force:           true,
+			expectedFetched: []string{"a", "b", "c"},
+			expectedCreated: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				fetched []string
+				mu      sync.Mutex
+			)
+			api := &mockDepsDevAPI{
+				GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+					return graphOf("a", "b", "c"), nil
+				},
+				GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+					mu.Lock()
+					fetched = append(fetched, vk.Name)
+					mu.Unlock()
+					return &depsdev.PackageVersionMetadata{}, nil
+				},
+				GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+					return depsdev.ScorecardInfo{}
+				},
+			}
+
+			store := &mockStorage{
+				GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+					return map[string]storage.Dependency{}, nil
+				},
+				UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+					return nil
+				},
+				ResumableRun: &storage.RefreshRun{ID: 7, Status: storage.RunStatusFailed},
+				RunItems: map[string]bool{
+					"npm|a|1.0.0": true,
+					"npm|b|1.0.0": true,
+				},
+			}
+
+			manager := &data.DataManager{
+				API:           api,
+				Store:         store,
+				Log:           logrus.New(),
+				MaxConcurrent: 2,
+				ResumeWindow:  time.Hour,
+			}
+
+			err := manager.RefreshDependencies(context.Background(), "npm", "root", "1.0.0", data.RefreshOptions{Force: tt.force})
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.expectedFetched, fetched)
+			assert.Equal(t, tt.expectedCreated, store.CreatedRuns)
+			assert.Equal(t, storage.RunStatusCompleted, store.FinishedStatus)
+		})
+	}
+}
+
+func TestRefreshDependencies_SerializesSameRoot(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	api := &mockDepsDevAPI{
+		GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+			started <- name
+			if name == "root" {
+				<-release
+			}
+			return graphOf("a"), nil
+		},
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			return &depsdev.PackageVersionMetadata{}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{}
+		},
+	}
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			return nil
+		},
+	}
+	manager := &data.DataManager{API: api, Store: store, Log: logrus.New(), ResumeWindow: time.Hour}
+
+	first := make(chan error)
+	go func() {
+		first <- manager.RefreshDependencies(context.Background(), "npm", "root", "1.0.0", data.RefreshOptions{})
+	}()
+	assert.Equal(t, "root", <-started)
+
+	// A refresh of the same root waits, and gives up with its context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := manager.RefreshDependencies(ctx, "NPM", "root", "1.0.0", data.RefreshOptions{Force: true})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Other roots are not held up
+	other := make(chan error)
+	go func() {
+		other <- manager.RefreshDependencies(context.Background(), "npm", "other", "1.0.0", data.RefreshOptions{})
+	}()
+	assert.Equal(t, "other", <-started)
+	assert.NoError(t, <-other)
+
+	second := make(chan error)
+	go func() {
+		second <- manager.RefreshDependencies(context.Background(), "npm", "root", "1.0.0", data.RefreshOptions{})
+	}()
+	select {
+	case <-started:
+		t.Fatal("second refresh of the root started while the first was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-first)
+	assert.Equal(t, "root", <-started)
+	assert.NoError(t, <-second)
+}
+
+func TestImportDependencies(t *testing.T) {
+	score := 6.0
+	api := &mockDepsDevAPI{
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			if vk.Name == "private" {
+				return nil, errors.New("package metadata request failed for private: 404 Not Found")
+			}
+			return &depsdev.PackageVersionMetadata{
+				RelatedProjects: []depsdev.RelatedProject{
+					{ProjectKey: depsdev.ProjectKey{ID: "github.com/facebook/react"}, RelationType: "SOURCE_REPO"},
+				},
+			}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{SourceRepo: meta.RelatedProjects[0].ProjectKey.ID, OpenSSFScore: &score}
+		},
+	}
+
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			return nil
+		},
+	}
+
+	manager := &data.DataManager{
+		API:           api,
+		Store:         store,
+		Log:           logrus.New(),
+		MaxConcurrent: 2,
+	}
+
+	imported, err := manager.ImportDependencies(context.Background(), []storage.Dependency{
+		{System: "NPM", Name: "react", Version: "18.2.0", Relation: "DIRECT"},
+		{System: "NPM", Name: "private", Version: "1.0.0", Relation: "DIRECT"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imported)
+
+	byName := map[string]storage.Dependency{}
+	for _, dep := range store.Upserted {
+		byName[dep.Name] = dep
+	}
+	assert.Equal(t, "github.com/facebook/react", byName["react"].SourceRepo)
+	assert.Equal(t, 6.0, *byName["react"].OpenSSFScore)
+	// Stored without enrichment and queued for retry
+	assert.Equal(t, "DIRECT", byName["private"].Relation)
+	assert.Empty(t, byName["private"].SourceRepo)
+	assert.Len(t, store.Recorded, 1)
+	// Imports are not refresh runs
+	assert.Zero(t, store.CreatedRuns)
+	assert.Empty(t, store.Checkpointed)
+}
+
+func TestImportDependencies_CanonicalKeys(t *testing.T) {
+	api := &mockDepsDevAPI{
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			return &depsdev.PackageVersionMetadata{}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{}
+		},
+	}
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			return nil
+		},
+	}
+	manager := &data.DataManager{API: api, Store: store, Log: logrus.New(), MaxConcurrent: 2}
+
+	imported, err := manager.ImportDependencies(context.Background(), []storage.Dependency{
+		{System: "pypi", Name: "Zope.Interface", Version: "5.0", Relation: "INDIRECT"},
+		{System: "PYPI", Name: "zope_interface", Version: "5.0", Relation: "DIRECT"},
+		{System: "go", Name: "github.com/a/b", Version: "1.2.3", Relation: "DIRECT"},
+		{System: "bower", Name: "jquery", Version: "3.7.0", Relation: "DIRECT"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imported)
+
+	keys := map[string]string{}
+	for _, dep := range store.Upserted {
+		keys[storage.DependencyKey(dep.System, dep.Name, dep.Version)] = dep.Relation
+	}
+	assert.Equal(t, map[string]string{
+		"PYPI|zope-interface|5.0":  "DIRECT",
+		"GO|github.com/a/b|v1.2.3": "DIRECT",
+	}, keys)
+}
+
+type mockPolicies struct {
+	EvaluateFn func(ctx context.Context) (int, error)
+	Calls      int
+}
+
+func (m *mockPolicies) Evaluate(ctx context.Context) (int, error) {
+	m.Calls++
+	return m.EvaluateFn(ctx)
+}
+
+func TestDataManager_EvaluatesPolicies(t *testing.T) {
+	api := &mockDepsDevAPI{
+		GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+			return &depsdev.DependencyGraph{Nodes: []depsdev.DependencyNode{
+				{VersionKey: depsdev.VersionKey{System: "NPM", Name: "react", Version: "18.2.0"}, Relation: "SELF"},
+			}}, nil
+		},
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			return &depsdev.PackageVersionMetadata{}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{}
+		},
+	}
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			return nil
+		},
+	}
+	// A failed evaluation is logged, not returned
+	policies := &mockPolicies{EvaluateFn: func(ctx context.Context) (int, error) {
+		return 0, errors.New("invalid policy")
+	}}
+
+	manager := &data.DataManager{API: api, Store: store, Log: logrus.New(), Policies: policies}
+
+	err := manager.RefreshDependencies(context.Background(), "NPM", "react", "18.2.0", data.RefreshOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, policies.Calls)
+
+	_, err = manager.ImportDependencies(context.Background(), []storage.Dependency{
+		{System: "NPM", Name: "react", Version: "18.2.0", Relation: "DIRECT"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, policies.Calls)
+
+	// Nothing is evaluated when the refresh fails
+	api.GetDependencyGraphFn = func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+		return nil, errors.New("graph fetch failed")
+	}
+	err = manager.RefreshDependencies(context.Background(), "NPM", "react", "18.2.0", data.RefreshOptions{})
+	assert.Error(t, err)
+	assert.Equal(t, 2, policies.Calls)
+}
+
+func TestResolvePackage(t *testing.T) {
+	score := 4.0
+	api := &mockDepsDevAPI{
+		GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+			assert.Equal(t, []string{"NPM", "left-pad", "1.3.0"}, []string{system, name, version})
+			return &depsdev.DependencyGraph{
+				Nodes: []depsdev.DependencyNode{
+					{VersionKey: depsdev.VersionKey{System: "NPM", Name: "left-pad", Version: "1.3.0"}, Relation: "SELF"},
+					{VersionKey: depsdev.VersionKey{System: "NPM", Name: "is-odd", Version: "3.0.1"}, Relation: "DIRECT"},
+					{VersionKey: depsdev.VersionKey{System: "NPM", Name: "is-number", Version: "6.0.0"}, Relation: "INDIRECT"},
+				},
+				Edges: []depsdev.DependencyEdge{{FromNode: 0, ToNode: 1}},
+			}, nil
+		},
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			if vk.Name == "is-odd" {
+				return nil, errors.New("package metadata request failed for is-odd: 404 Not Found")
+			}
+			return &depsdev.PackageVersionMetadata{
+				RelatedProjects: []depsdev.RelatedProject{
+					{ProjectKey: depsdev.ProjectKey{ID: "github.com/x/" + vk.Name}, RelationType: "SOURCE_REPO"},
+				},
+			}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{SourceRepo: meta.RelatedProjects[0].ProjectKey.ID, OpenSSFScore: &score}
+		},
+	}
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			t.Fatal("resolving must not store dependencies")
+			return nil
+		},
+	}
+	manager := &data.DataManager{API: api, Store: store, Log: logrus.New(), MaxConcurrent: 2}
+
+	deps, err := manager.ResolvePackage(context.Background(), "npm", "left-pad", "1.3.0")
+	assert.NoError(t, err)
+	if assert.Len(t, deps, 3) {
+		// The package is checked as a new direct dependency of the project
+		assert.Equal(t, "DIRECT", deps[0].Relation)
+		assert.Equal(t, 1, deps[0].Depth)
+		assert.Equal(t, "pkg:npm/left-pad@1.3.0", deps[0].PURL)
+		assert.Equal(t, 4.0, *deps[0].OpenSSFScore)
+
+		// Failed lookups are returned as they are, and not queued for retry
+		assert.Equal(t, "INDIRECT", deps[1].Relation)
+		assert.Equal(t, 2, deps[1].Depth)
+		assert.Nil(t, deps[1].OpenSSFScore)
+
+		// Not reached by the edges, so of unknown depth
+		assert.Equal(t, 0, deps[2].Depth)
+	}
+	assert.Empty(t, store.Recorded)
+}
+
+func TestRefreshDependencies_ReplayedFixtures(t *testing.T) {
+	// Recorded deps.dev responses, see depsdev.RecordTransport
+	api := &depsdev.DepsDevClient{
+		BaseURL:    config.BaseURL,
+		HTTPClient: &http.Client{Transport: &depsdev.ReplayTransport{Dir: "testdata/depsdev"}},
+	}
+
+	var written []storage.Dependency
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			written = append(written, deps...)
+			return nil
+		},
+	}
+	manager := &data.DataManager{API: api, Store: store, Log: logrus.New(), MaxConcurrent: 2}
+
+	err := manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0", data.RefreshOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, store.Recorded)
+
+	byName := make(map[string]storage.Dependency)
+	for _, dep := range written {
+		byName[dep.Name] = dep
+	}
+	if assert.Len(t, byName, 3) {
+		assert.Equal(t, "github.com/facebook/react", byName["react"].SourceRepo)
+		assert.Equal(t, 6.8, *byName["react"].OpenSSFScore)
+		assert.Equal(t, 3.2, *byName["loose-envify"].OpenSSFScore)
+		assert.Equal(t, 2, byName["js-tokens"].Depth)
+		assert.Equal(t, "", byName["js-tokens"].SourceRepo)
+		assert.Nil(t, byName["js-tokens"].OpenSSFScore)
+		assert.Equal(t, "MIT", byName["js-tokens"].License)
+	}
+}
+
+func TestRefreshDependencies_StoresProvenance(t *testing.T) {
+	score := 7.2
+	api := &mockDepsDevAPI{
+		GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+			return &depsdev.DependencyGraph{Nodes: []depsdev.DependencyNode{
+				{VersionKey: depsdev.VersionKey{System: "NPM", Name: "@acme/ui", Version: "1.4.0"}, Relation: "SELF"},
+			}}, nil
+		},
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			return &depsdev.PackageVersionMetadata{
+				RelatedProjects: []depsdev.RelatedProject{
+					{ProjectKey: depsdev.ProjectKey{ID: "git.acme.com/web/ui"}, RelationType: "SOURCE_REPO"},
+				},
+				Provider: "mirror",
+			}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{SourceRepo: meta.SourceRepo(), OpenSSFScore: &score, Provider: "dataset"}
+		},
+	}
+
+	var written []storage.Dependency
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			// The stored license is kept along with its provenance
+			return map[string]storage.Dependency{
+				"NPM|@acme/ui|1.4.0": {System: "NPM", Name: "@acme/ui", Version: "1.4.0", License: "MIT", LicenseProvider: storage.ProviderManual},
+			}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			written = append(written, deps...)
+			return nil
+		},
+	}
+	manager := &data.DataManager{API: api, Store: store, Log: logrus.New()}
+
+	err := manager.RefreshDependencies(context.Background(), "NPM", "@acme/ui", "1.4.0", data.RefreshOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, written, 1) {
+		assert.Equal(t, "mirror", written[0].SourceRepoProvider)
+		assert.Equal(t, "dataset", written[0].ScoreProvider)
+		assert.Equal(t, "MIT", written[0].License)
+		assert.Equal(t, storage.ProviderManual, written[0].LicenseProvider)
+	}
 }
@@ -0,0 +1,339 @@
+package data
+
+import (
+	"context"
+	"deps-dev/depsdev"
+	"deps-dev/purl"
+	"deps-dev/storage"
+	"sync"
+	"time"
+)
+
+const defaultBatchSize = 100
+
+// pipelineItem carries one dependency through the refresh stages.
+type pipelineItem struct {
+	node     depsdev.DependencyNode
+	existing storage.Dependency
+	found    bool
+	meta     *depsdev.PackageVersionMetadata
+	dep      storage.Dependency
+	// failed is set when the metadata lookup failed; the item is queued for
+	// retry and, unless keepFailed is set, only checkpointed, not written.
+	failed   bool
+	resolved bool
+}
+
+type checkpointFunc func(ctx context.Context, deps []storage.Dependency) error
+
+type pipelineOptions struct {
+	RefreshOptions
+	// checkpoint, if set, is called with every written batch.
+	checkpoint checkpointFunc
+	// keepFailed writes dependencies whose metadata lookup failed as they
+	// are, instead of leaving them to the retry queue.
+	keepFailed bool
+	// readOnly leaves failed lookups out of the retry queue, for pipelines
+	// that resolve dependencies without storing them.
+	readOnly bool
+}
+
+// runPipeline streams nodes through lookup of stored records, metadata and
+// scorecard fetching, and batched writes. It returns the number of
+// dependencies written.
+func (dm *DataManager) runPipeline(parent context.Context, nodes []depsdev.DependencyNode, opts pipelineOptions) (int, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var (
+		firstErr error
+		errOnce  sync.Once
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	looked := dm.lookupStage(ctx, nodes, fail)
+	fetched := dm.metadataStage(ctx, looked, opts)
+	scored := dm.scorecardStage(ctx, fetched, opts)
+	written := dm.writeStage(ctx, scored, opts, fail)
+
+	if firstErr != nil {
+		return written, firstErr
+	}
+	return written, parent.Err()
+}
+
+// resolvePipeline runs nodes through the same lookups as runPipeline, but
+// returns the resolved dependencies in node order instead of writing them.
+// Dependencies whose lookups fail are returned with what is known.
+func (dm *DataManager) resolvePipeline(parent context.Context, nodes []depsdev.DependencyNode) ([]storage.Dependency, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var (
+		firstErr error
+		errOnce  sync.Once
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	opts := pipelineOptions{readOnly: true}
+	looked := dm.lookupStage(ctx, nodes, fail)
+	fetched := dm.metadataStage(ctx, looked, opts)
+	scored := dm.scorecardStage(ctx, fetched, opts)
+
+	resolved := make(map[string]storage.Dependency, len(nodes))
+	for item := range scored {
+		dep := item.dep
+		if item.found {
+			dep = storage.MergeDependency(item.existing, item.dep)
+		}
+		resolved[storage.DependencyKey(dep.System, dep.Name, dep.Version)] = dep
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+
+	deps := make([]storage.Dependency, 0, len(nodes))
+	for _, node := range nodes {
+		vk := node.VersionKey
+		dep := resolved[storage.DependencyKey(vk.System, vk.Name, vk.Version)]
+		dep.PURL = purl.Format(dep.System, dep.Name, dep.Version)
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+func (dm *DataManager) batchSize() int {
+	if dm.BatchSize > 0 {
+		return dm.BatchSize
+	}
+	return defaultBatchSize
+}
+
+func (dm *DataManager) workers() int {
+	if dm.MaxConcurrent > 0 {
+		return dm.MaxConcurrent
+	}
+	return 1
+}
+
+// lookupStage loads the stored records for nodes one batch at a time.
+func (dm *DataManager) lookupStage(ctx context.Context, nodes []depsdev.DependencyNode, fail func(error)) <-chan pipelineItem {
+	out := make(chan pipelineItem, dm.batchSize())
+
+	go func() {
+		defer close(out)
+
+		for start := 0; start < len(nodes); start += dm.batchSize() {
+			batch := nodes[start:min(start+dm.batchSize(), len(nodes))]
+
+			keys := make([]storage.Dependency, 0, len(batch))
+			for _, node := range batch {
+				keys = append(keys, storage.Dependency{
+					System:  node.VersionKey.System,
+					Name:    node.VersionKey.Name,
+					Version: node.VersionKey.Version,
+				})
+			}
+
+			existingMap, err := dm.Store.GetDependenciesMap(ctx, keys)
+			if err != nil {
+				dm.Log.WithError(err).Error("failed to get existing dependencies")
+				fail(err)
+				return
+			}
+
+			for _, node := range batch {
+				vk := node.VersionKey
+				existing, found := existingMap[storage.DependencyKey(vk.System, vk.Name, vk.Version)]
+				item := pipelineItem{
+					node:     node,
+					existing: existing,
+					found:    found,
+					dep: storage.Dependency{
+						System:   vk.System,
+						Name:     vk.Name,
+						Version:  vk.Version,
+						Relation: node.Relation,
+						Depth:    node.Depth,
+					},
+				}
+
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// metadataStage fetches version metadata unless the stored one is fresh.
+func (dm *DataManager) metadataStage(ctx context.Context, in <-chan pipelineItem, opts pipelineOptions) <-chan pipelineItem {
+	return dm.parallelStage(ctx, in, func(item pipelineItem) pipelineItem {
+		if !opts.Force && item.found && isFresh(item.existing.MetadataFetchedAt, dm.MetadataTTL, time.Now()) {
+			// Reuse the stored source repo instead of looking up the version again
+			item.meta = storedMetadata(item.existing)
+			return item
+		}
+
+		meta, err := dm.API.GetPackageMetadata(ctx, item.node.VersionKey)
+		if err != nil {
+			if !opts.readOnly {
+				dm.recordFetchFailure(ctx, item.node, storage.FailureStageMetadata, err)
+			}
+			item.failed = true
+			return item
+		}
+
+		fetchedAt := time.Now().UTC()
+		item.meta = meta
+		applyMetadata(&item.dep, meta)
+		item.dep.MetadataFetchedAt = &fetchedAt
+		return item
+	})
+}
+
+// scorecardStage fetches the project scorecard unless the stored one is fresh.
+func (dm *DataManager) scorecardStage(ctx context.Context, in <-chan pipelineItem, opts pipelineOptions) <-chan pipelineItem {
+	return dm.parallelStage(ctx, in, func(item pipelineItem) pipelineItem {
+		if item.failed {
+			return item
+		}
+
+		if !opts.Force && item.found && item.dep.MetadataFetchedAt == nil &&
+			isFresh(item.existing.ScoreFetchedAt, dm.ScoreTTL, time.Now()) {
+			// Nothing fetched, only the relation from the graph is refreshed
+			item.resolved = true
+			return item
+		}
+
+		scorecard := dm.API.GetScorecardData(ctx, item.meta)
+		if scorecard.Err != nil {
+			if !opts.readOnly {
+				dm.recordFetchFailure(ctx, item.node, storage.FailureStageScorecard, scorecard.Err)
+			}
+		} else {
+			fetchedAt := time.Now().UTC()
+			item.dep.ScoreFetchedAt = &fetchedAt
+			item.resolved = true
+		}
+		applyScorecard(&item.dep, item.meta, scorecard)
+		return item
+	})
+}
+
+// parallelStage applies fn to items from in using MaxConcurrent workers.
+func (dm *DataManager) parallelStage(ctx context.Context, in <-chan pipelineItem, fn func(pipelineItem) pipelineItem) <-chan pipelineItem {
+	out := make(chan pipelineItem, dm.workers())
+
+	var wg sync.WaitGroup
+	for i := 0; i < dm.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for item := range in {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				select {
+				case out <- fn(item):
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// writeStage merges items into their stored records and upserts them in
+// batches. It drains in even after a failure so upstream stages can exit.
+func (dm *DataManager) writeStage(ctx context.Context, in <-chan pipelineItem, opts pipelineOptions, fail func(error)) int {
+	var (
+		batch   []pipelineItem
+		written int
+	)
+
+	flush := func() {
+		if len(batch) == 0 || ctx.Err() != nil {
+			return
+		}
+
+		var merged, resolved, processed []storage.Dependency
+		for _, item := range batch {
+			processed = append(processed, item.dep)
+			if item.failed && !opts.keepFailed {
+				continue
+			}
+
+			dep := item.dep
+			if item.found {
+				dep = storage.MergeDependency(item.existing, item.dep)
+			}
+			merged = append(merged, dep)
+			if item.resolved {
+				resolved = append(resolved, dep)
+			}
+		}
+		batch = batch[:0]
+
+		if len(merged) > 0 {
+			if err := dm.Store.UpsertDependencies(ctx, merged); err != nil {
+				dm.Log.WithError(err).Error("failed to upsert dependencies to database")
+				fail(err)
+				return
+			}
+			written += len(merged)
+		}
+
+		// Drop queued retries for dependencies that were fetched completely this time
+		if err := dm.Store.ClearFetchFailures(ctx, resolved); err != nil {
+			dm.Log.WithError(err).Warn("failed to clear resolved fetch failures")
+		}
+
+		if opts.checkpoint != nil {
+			if err := opts.checkpoint(ctx, processed); err != nil {
+				dm.Log.WithError(err).Error("failed to checkpoint refresh progress")
+				fail(err)
+			}
+		}
+	}
+
+	for item := range in {
+		if ctx.Err() != nil {
+			continue
+		}
+
+		batch = append(batch, item)
+		if len(batch) >= dm.batchSize() {
+			flush()
+		}
+	}
+	flush()
+
+	return written
+}
@@ -0,0 +1,157 @@
+package data
+
+import (
+	"context"
+	"deps-dev/depsdev"
+	"deps-dev/storage"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type RetryStore interface {
+	Storage
+	DueFetchFailures(ctx context.Context, now time.Time, limit int) ([]storage.FetchFailure, error)
+	RescheduleFetchFailure(ctx context.Context, f storage.FetchFailure) error
+}
+
+// RetryWorker periodically retries lookups that failed during a refresh,
+// backing off exponentially until they succeed or run out of attempts.
+type RetryWorker struct {
+	Store       RetryStore
+	API         DepsDevAPI
+	Log         *logrus.Logger
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Policies, if set, is evaluated after a batch in which a lookup recovered.
+	Policies PolicyEvaluator
+}
+
+func (w *RetryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.RetryDue(ctx); err != nil {
+				w.Log.WithError(err).Error("failed to process retry queue")
+			}
+		}
+	}
+}
+
+func (w *RetryWorker) RetryDue(ctx context.Context) error {
+	due, err := w.Store.DueFetchFailures(ctx, time.Now(), w.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	recovered := 0
+	for _, f := range due {
+		ok, err := w.retry(ctx, f)
+		if ok {
+			recovered++
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.Log.WithFields(logrus.Fields{
+				"system":  f.System,
+				"name":    f.Name,
+				"version": f.Version,
+			}).WithError(err).Error("failed to update retry queue")
+		}
+	}
+
+	if recovered > 0 && w.Policies != nil {
+		if _, err := w.Policies.Evaluate(ctx); err != nil {
+			w.Log.WithError(err).Error("failed to evaluate policies")
+		}
+	}
+	return nil
+}
+
+// retry repeats a failed lookup. It reports whether the dependency was stored
+// with fresh data, even if clearing its queue entry failed afterwards.
+func (w *RetryWorker) retry(ctx context.Context, f storage.FetchFailure) (bool, error) {
+	vk := depsdev.VersionKey{System: f.System, Name: f.Name, Version: f.Version}
+
+	meta, err := w.API.GetPackageMetadata(ctx, vk)
+	if err != nil {
+		return false, w.reschedule(ctx, f, storage.FailureStageMetadata, err)
+	}
+
+	scorecard := w.API.GetScorecardData(ctx, meta)
+	if scorecard.Err != nil {
+		return false, w.reschedule(ctx, f, storage.FailureStageScorecard, scorecard.Err)
+	}
+
+	fetchedAt := time.Now().UTC()
+	incoming := storage.Dependency{
+		System:            f.System,
+		Name:              f.Name,
+		Version:           f.Version,
+		Relation:          f.Relation,
+		Depth:             f.Depth,
+		MetadataFetchedAt: &fetchedAt,
+		ScoreFetchedAt:    &fetchedAt,
+	}
+	applyMetadata(&incoming, meta)
+	applyScorecard(&incoming, meta, scorecard)
+
+	existingMap, err := w.Store.GetDependenciesMap(ctx, []storage.Dependency{incoming})
+	if err != nil {
+		return false, err
+	}
+
+	merged := []storage.Dependency{mergeDependency(existingMap, incoming)}
+	if err := w.Store.UpsertDependencies(ctx, merged); err != nil {
+		return false, err
+	}
+
+	w.Log.Infof("Retried lookup for %s/%s@%s succeeded after %d failed attempts", f.System, f.Name, f.Version, f.Attempts)
+	return true, w.Store.ClearFetchFailures(ctx, merged)
+}
+
+func (w *RetryWorker) reschedule(ctx context.Context, f storage.FetchFailure, stage string, fetchErr error) error {
+	// Shutting down is not a failure of the lookup itself
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	f.Stage = stage
+	f.Attempts++
+	f.LastError = fetchErr.Error()
+	f.NextAttemptAt = time.Now().Add(w.backoff(f.Attempts))
+	f.Status = storage.FailureStatusPending
+	if f.Attempts >= w.MaxAttempts {
+		f.Status = storage.FailureStatusExhausted
+		w.Log.WithFields(logrus.Fields{
+			"system":   f.System,
+			"name":     f.Name,
+			"version":  f.Version,
+			"attempts": f.Attempts,
+		}).WithError(fetchErr).Warn("giving up on failed lookup")
+	}
+
+	return w.Store.RescheduleFetchFailure(ctx, f)
+}
+
+// backoff doubles the delay for every attempt made so far, capped at MaxBackoff.
+func (w *RetryWorker) backoff(attempts int) time.Duration {
+	delay := w.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= w.MaxBackoff {
+			return w.MaxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,189 @@
+package data_test
+
+import (
+	"context"
+	"deps-dev/data"
+	"deps-dev/depsdev"
+	"deps-dev/storage"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRetryWorker(api data.DepsDevAPI, store data.RetryStore) *data.RetryWorker {
+	return &data.RetryWorker{
+		Store:       store,
+		API:         api,
+		Log:         logrus.New(),
+		Interval:    time.Minute,
+		BatchSize:   10,
+		MaxAttempts: 3,
+		BaseBackoff: time.Minute,
+		MaxBackoff:  time.Hour,
+	}
+}
+
+func TestRetryWorker_Success(t *testing.T) {
+	score := 7.0
+	api := &mockDepsDevAPI{
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			return &depsdev.PackageVersionMetadata{}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{SourceRepo: "github.com/lodash/lodash", OpenSSFScore: &score}
+		},
+	}
+
+	store := &mockStorage{
+		DueFn: func(ctx context.Context, now time.Time, limit int) ([]storage.FetchFailure, error) {
+			assert.Equal(t, 10, limit)
+			return []storage.FetchFailure{
+				{ID: 1, System: "npm", Name: "lodash", Version: "4.17.21", Relation: "DIRECT", Attempts: 1},
+			}, nil
+		},
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{
+				"npm|lodash|4.17.21": {System: "npm", Name: "lodash", Version: "4.17.21", Relation: "DIRECT"},
+			}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			return nil
+		},
+	}
+
+	policies := &mockPolicies{EvaluateFn: func(ctx context.Context) (int, error) {
+		return 0, nil
+	}}
+	worker := newRetryWorker(api, store)
+	worker.Policies = policies
+
+	err := worker.RetryDue(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, policies.Calls)
+	assert.Len(t, store.Upserted, 1)
+	assert.Equal(t, "github.com/lodash/lodash", store.Upserted[0].SourceRepo)
+	assert.Equal(t, 7.0, *store.Upserted[0].OpenSSFScore)
+	assert.Len(t, store.Cleared, 1)
+	assert.Empty(t, store.Rescheduled)
+}
+
+func TestRetryWorker_Reschedules(t *testing.T) {
+	tests := []struct {
+		name            string
+		attempts        int
+		expectedStatus  string
+		expectedBackoff time.Duration
+	}{
+		{
+			name:            "second attempt backs off",
+			attempts:        1,
+			expectedStatus:  storage.FailureStatusPending,
+			expectedBackoff: 2 * time.Minute,
+		},
+		{
+			name:            "max attempts exhausts item",
+			attempts:        2,
+			expectedStatus:  storage.FailureStatusExhausted,
+			expectedBackoff: 4 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &mockDepsDevAPI{
+				GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+					return nil, errors.New("still failing")
+				},
+			}
+
+			store := &mockStorage{
+				DueFn: func(ctx context.Context, now time.Time, limit int) ([]storage.FetchFailure, error) {
+					return []storage.FetchFailure{
+						{ID: 1, System: "npm", Name: "lodash", Version: "4.17.21", Attempts: tt.attempts},
+					}, nil
+				},
+			}
+
+			policies := &mockPolicies{EvaluateFn: func(ctx context.Context) (int, error) {
+				return 0, nil
+			}}
+			worker := newRetryWorker(api, store)
+			worker.Policies = policies
+
+			before := time.Now()
+			err := worker.RetryDue(context.Background())
+			assert.NoError(t, err)
+
+			assert.Len(t, store.Rescheduled, 1)
+			f := store.Rescheduled[0]
+			assert.Equal(t, tt.attempts+1, f.Attempts)
+			assert.Equal(t, tt.expectedStatus, f.Status)
+			assert.Equal(t, storage.FailureStageMetadata, f.Stage)
+			assert.Equal(t, "still failing", f.LastError)
+			assert.WithinDuration(t, before.Add(tt.expectedBackoff), f.NextAttemptAt, time.Second)
+			assert.Empty(t, store.Cleared)
+			assert.Zero(t, policies.Calls)
+		})
+	}
+}
+
+func TestRetryWorker_KeepsDepth(t *testing.T) {
+	failing := true
+	api := &mockDepsDevAPI{
+		GetDependencyGraphFn: func(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+			return &depsdev.DependencyGraph{
+				Nodes: []depsdev.DependencyNode{
+					{VersionKey: depsdev.VersionKey{System: "npm", Name: "react", Version: "18.2.0"}, Relation: "SELF"},
+					{VersionKey: depsdev.VersionKey{System: "npm", Name: "loose-envify", Version: "1.4.0"}, Relation: "DIRECT"},
+					{VersionKey: depsdev.VersionKey{System: "npm", Name: "js-tokens", Version: "4.0.0"}, Relation: "INDIRECT"},
+				},
+				Edges: []depsdev.DependencyEdge{{FromNode: 0, ToNode: 1}, {FromNode: 1, ToNode: 2}},
+			}, nil
+		},
+		GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			if failing && vk.Name == "js-tokens" {
+				return nil, errors.New("metadata timeout")
+			}
+			return &depsdev.PackageVersionMetadata{}, nil
+		},
+		GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			return depsdev.ScorecardInfo{}
+		},
+	}
+
+	store := &mockStorage{
+		GetMapFn: func(ctx context.Context, deps []storage.Dependency) (map[string]storage.Dependency, error) {
+			return map[string]storage.Dependency{}, nil
+		},
+		UpsertFn: func(ctx context.Context, deps []storage.Dependency) error {
+			return nil
+		},
+	}
+	store.DueFn = func(ctx context.Context, now time.Time, limit int) ([]storage.FetchFailure, error) {
+		return store.Recorded, nil
+	}
+
+	manager := &data.DataManager{
+		API:           api,
+		Store:         store,
+		Log:           logrus.New(),
+		MaxConcurrent: 5,
+		RetryDelay:    time.Minute,
+	}
+	assert.NoError(t, manager.RefreshDependencies(context.Background(), "npm", "react", "18.2.0", data.RefreshOptions{}))
+	if assert.Len(t, store.Recorded, 1) {
+		assert.Equal(t, 2, store.Recorded[0].Depth)
+	}
+
+	failing = false
+	assert.NoError(t, newRetryWorker(api, store).RetryDue(context.Background()))
+	if assert.Len(t, store.Upserted, 1) {
+		assert.Equal(t, "js-tokens", store.Upserted[0].Name)
+		assert.Equal(t, "INDIRECT", store.Upserted[0].Relation)
+		assert.Equal(t, 2, store.Upserted[0].Depth)
+	}
+}
@@ -0,0 +1,282 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"deps-dev/ecosystem"
+	"deps-dev/storage"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+type ScheduleStore interface {
+	ListRefreshSchedules(ctx context.Context) ([]storage.RefreshSchedule, error)
+	RecordScheduleRun(ctx context.Context, id int64, ranAt time.Time, runErr error) error
+	SetScheduleNextRun(ctx context.Context, id int64, next *time.Time) error
+}
+
+// RootScheduleStore stores the refresh schedule of the configured root package.
+type RootScheduleStore interface {
+	CreateRefreshSchedule(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error)
+	UpdateRefreshSchedule(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error)
+	ListRefreshSchedules(ctx context.Context) ([]storage.RefreshSchedule, error)
+}
+
+type Refresher interface {
+	RefreshDependencies(ctx context.Context, system, name, version string, opts RefreshOptions) error
+}
+
+// Scheduler refreshes packages on their stored schedules. Reload picks up
+// schedules that were added, changed or removed since it started.
+type Scheduler struct {
+	Store     ScheduleStore
+	Refresher Refresher
+	Log       *logrus.Logger
+
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	cron    *cron.Cron
+	chain   cron.Chain
+	entries map[int64]scheduledEntry
+}
+
+type scheduledEntry struct {
+	id       cron.EntryID
+	schedule storage.RefreshSchedule
+}
+
+// ParseSchedule parses a standard 5-field cron expression, or a descriptor
+// like @hourly, evaluated in the named IANA timezone.
+func ParseSchedule(expr, timezone string) (cron.Schedule, error) {
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, errors.New("cron expression must not contain a timezone, set timezone instead")
+	}
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "" {
+		return nil, fmt.Errorf("unknown timezone %q", timezone)
+	}
+
+	schedule, err := cron.ParseStandard("CRON_TZ=" + timezone + " " + expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return schedule, nil
+}
+
+// SplitTimezone splits a CRON_TZ= or TZ= prefix off a cron spec. Specs
+// without one are in UTC.
+func SplitTimezone(spec string) (expr, timezone string) {
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if rest, ok := strings.CutPrefix(spec, prefix); ok {
+			timezone, expr, _ = strings.Cut(rest, " ")
+			return strings.TrimSpace(expr), timezone
+		}
+	}
+	return spec, "UTC"
+}
+
+// Start schedules the stored schedules and starts running them.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	// Refreshes outlive the context of Start and are cancelled by Stop
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.cron = cron.New()
+	// A refresh running past the next run time delays it instead of
+	// overlapping with it
+	s.chain = cron.NewChain(cron.DelayIfStillRunning(cron.PrintfLogger(s.Log)))
+	s.entries = make(map[int64]scheduledEntry)
+	s.mu.Unlock()
+
+	if err := s.Reload(ctx); err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Stop stops scheduling refreshes, cancels running ones and waits for them
+// to return.
+func (s *Scheduler) Stop() {
+	done := s.cron.Stop().Done()
+	s.cancel()
+	<-done
+}
+
+// Reload brings the running schedules in line with the stored ones and
+// stores when each of them runs next.
+func (s *Scheduler) Reload(ctx context.Context) error {
+	schedules, err := s.Store.ListRefreshSchedules(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	scheduled := make(map[int64]bool)
+	for _, rs := range schedules {
+		var next *time.Time
+		if rs.Enabled {
+			schedule, err := ParseSchedule(rs.Cron, rs.Timezone)
+			if err != nil {
+				s.Log.WithField("schedule", rs.ID).WithError(err).Error("skipping invalid refresh schedule")
+			} else {
+				s.schedule(rs, schedule)
+				scheduled[rs.ID] = true
+				t := schedule.Next(now)
+				next = &t
+			}
+		}
+
+		if !sameTime(rs.NextRunAt, next) {
+			if err := s.Store.SetScheduleNextRun(ctx, rs.ID, next); err != nil {
+				return err
+			}
+		}
+	}
+
+	for id, entry := range s.entries {
+		if !scheduled[id] {
+			s.cron.Remove(entry.id)
+			delete(s.entries, id)
+		}
+	}
+	return nil
+}
+
+// schedule adds rs to the cron, replacing its previous entry if the package
+// or timing changed.
+func (s *Scheduler) schedule(rs storage.RefreshSchedule, schedule cron.Schedule) {
+	entry, ok := s.entries[rs.ID]
+	if ok {
+		if sameSchedule(entry.schedule, rs) {
+			return
+		}
+		s.cron.Remove(entry.id)
+	}
+
+	id := s.cron.Schedule(schedule, s.chain.Then(cron.FuncJob(func() { s.run(rs, schedule) })))
+	s.entries[rs.ID] = scheduledEntry{id: id, schedule: rs}
+}
+
+func (s *Scheduler) run(rs storage.RefreshSchedule, schedule cron.Schedule) {
+	log := s.Log.WithFields(logrus.Fields{
+		"schedule": rs.ID,
+		"system":   rs.System,
+		"name":     rs.Name,
+		"version":  rs.Version,
+	})
+	// A run delayed behind one that Stop cancelled is dropped
+	if s.ctx.Err() != nil {
+		return
+	}
+	log.Info("Scheduled refresh triggered")
+
+	ranAt := time.Now()
+	err := s.Refresher.RefreshDependencies(s.ctx, rs.System, rs.Name, rs.Version, RefreshOptions{})
+	if err != nil {
+		log.WithError(err).Error("scheduled refresh failed")
+	}
+
+	// A cancelled refresh is still recorded
+	ctx := context.WithoutCancel(s.ctx)
+
+	if err := s.Store.RecordScheduleRun(ctx, rs.ID, ranAt, err); err != nil {
+		log.WithError(err).Error("recording scheduled refresh")
+	}
+
+	// A reload during the refresh already stored the next run of a changed
+	// or removed schedule
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[rs.ID]; ok && sameSchedule(entry.schedule, rs) {
+		next := schedule.Next(time.Now())
+		if err := s.Store.SetScheduleNextRun(ctx, rs.ID, &next); err != nil {
+			log.WithError(err).Error("recording next scheduled refresh")
+		}
+	}
+}
+
+// ConfigureRootSchedule makes the stored schedule of the root package follow
+// the refresh.scheduled and refresh.schedule settings. When scheduled, it
+// creates the schedule from spec, or updates and enables a stored one that
+// differs; otherwise it disables the stored one, so turning the setting off
+// stops refreshes scheduled on an earlier start.
+func ConfigureRootSchedule(ctx context.Context, store RootScheduleStore, log *logrus.Logger, system, name, version, spec string, scheduled bool) error {
+	system, name, version, err := ecosystem.Canonicalize(system, name, version)
+	if err != nil {
+		return err
+	}
+
+	expr, timezone := SplitTimezone(spec)
+	if scheduled {
+		_, err = store.CreateRefreshSchedule(ctx, storage.RefreshSchedule{
+			System:   system,
+			Name:     name,
+			Version:  version,
+			Cron:     expr,
+			Timezone: timezone,
+			Enabled:  true,
+		})
+		if !errors.Is(err, storage.ErrScheduleExists) {
+			return err
+		}
+	}
+
+	schedules, err := store.ListRefreshSchedules(ctx)
+	if err != nil {
+		return err
+	}
+	for _, rs := range schedules {
+		if rs.System != system || rs.Name != name || rs.Version != version {
+			continue
+		}
+		log := log.WithField("schedule_id", rs.ID)
+
+		if !scheduled {
+			if !rs.Enabled {
+				return nil
+			}
+			rs.Enabled = false
+			if _, err := store.UpdateRefreshSchedule(ctx, rs); err != nil {
+				return err
+			}
+			log.Info("Disabled the refresh schedule of the root package, refresh.scheduled is off")
+			return nil
+		}
+
+		if rs.Cron == expr && rs.Timezone == timezone && rs.Enabled {
+			return nil
+		}
+		stored := "CRON_TZ=" + rs.Timezone + " " + rs.Cron
+		rs.Cron, rs.Timezone, rs.Enabled = expr, timezone, true
+		if _, err := store.UpdateRefreshSchedule(ctx, rs); err != nil {
+			return err
+		}
+		log.WithFields(logrus.Fields{
+			"stored":     stored,
+			"configured": "CRON_TZ=" + timezone + " " + expr,
+		}).Info("Updated the refresh schedule of the root package from refresh.schedule")
+		return nil
+	}
+	return nil
+}
+
+func sameSchedule(a, b storage.RefreshSchedule) bool {
+	return a.System == b.System && a.Name == b.Name && a.Version == b.Version &&
+		a.Cron == b.Cron && a.Timezone == b.Timezone
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,272 @@
+package data_test
+
+import (
+	"context"
+	"database/sql"
+	"deps-dev/data"
+	"deps-dev/storage"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockScheduleStore struct {
+	mu        sync.Mutex
+	Schedules []storage.RefreshSchedule
+	NextRuns  map[int64]*time.Time
+	Runs      chan error
+}
+
+func (m *mockScheduleStore) ListRefreshSchedules(ctx context.Context) ([]storage.RefreshSchedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := append([]storage.RefreshSchedule(nil), m.Schedules...)
+	for i, rs := range list {
+		if next, ok := m.NextRuns[rs.ID]; ok {
+			list[i].NextRunAt = next
+		}
+	}
+	return list, nil
+}
+func (m *mockScheduleStore) RecordScheduleRun(ctx context.Context, id int64, ranAt time.Time, runErr error) error {
+	m.Runs <- runErr
+	return nil
+}
+func (m *mockScheduleStore) SetScheduleNextRun(ctx context.Context, id int64, next *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.NextRuns[id] = next
+	return nil
+}
+
+func (m *mockScheduleStore) CreateRefreshSchedule(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.Schedules {
+		if existing.System == rs.System && existing.Name == rs.Name && existing.Version == rs.Version {
+			return storage.RefreshSchedule{}, storage.ErrScheduleExists
+		}
+	}
+	rs.ID = int64(len(m.Schedules) + 1)
+	m.Schedules = append(m.Schedules, rs)
+	return rs, nil
+}
+func (m *mockScheduleStore) UpdateRefreshSchedule(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.Schedules {
+		if m.Schedules[i].ID == rs.ID {
+			m.Schedules[i] = rs
+			return rs, nil
+		}
+	}
+	return storage.RefreshSchedule{}, sql.ErrNoRows
+}
+
+type refresherFn func(ctx context.Context, system, name, version string, opts data.RefreshOptions) error
+
+func (fn refresherFn) RefreshDependencies(ctx context.Context, system, name, version string, opts data.RefreshOptions) error {
+	return fn(ctx, system, name, version, opts)
+}
+
+func TestParseSchedule(t *testing.T) {
+	schedule, err := data.ParseSchedule("0 3 * * 1", "Europe/Berlin")
+	assert.NoError(t, err)
+	// Monday 3:00 in Berlin, still on summer time
+	next := schedule.Next(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2026, 10, 19, 1, 0, 0, 0, time.UTC).Equal(next))
+
+	_, err = data.ParseSchedule("@hourly", "Nowhere")
+	assert.EqualError(t, err, `unknown timezone "Nowhere"`)
+	_, err = data.ParseSchedule("TZ=UTC @hourly", "UTC")
+	assert.Error(t, err)
+
+	expr, tz := data.SplitTimezone("CRON_TZ=Asia/Tokyo 0 9 * * *")
+	assert.Equal(t, "0 9 * * *", expr)
+	assert.Equal(t, "Asia/Tokyo", tz)
+	expr, tz = data.SplitTimezone("@weekly")
+	assert.Equal(t, "@weekly", expr)
+	assert.Equal(t, "UTC", tz)
+}
+
+func TestScheduler_Reload(t *testing.T) {
+	stale := time.Now().Add(-time.Hour)
+	store := &mockScheduleStore{
+		Schedules: []storage.RefreshSchedule{
+			{ID: 1, System: "NPM", Name: "react", Version: "18.2.0", Cron: "@hourly", Timezone: "UTC", Enabled: true},
+			{ID: 2, System: "NPM", Name: "left-pad", Version: "1.3.0", Cron: "@weekly", Timezone: "UTC", NextRunAt: &stale},
+			{ID: 3, System: "NPM", Name: "is-odd", Version: "3.0.1", Cron: "bad", Timezone: "UTC", Enabled: true},
+		},
+		NextRuns: make(map[int64]*time.Time),
+	}
+	s := &data.Scheduler{Store: store, Log: logrus.New()}
+
+	assert.NoError(t, s.Start(context.Background()))
+	defer s.Stop()
+
+	// Next runs are stored for enabled schedules and cleared for disabled ones
+	if assert.NotNil(t, store.NextRuns[1]) {
+		assert.True(t, store.NextRuns[1].After(time.Now()))
+		assert.Zero(t, store.NextRuns[1].Minute())
+	}
+	assert.Contains(t, store.NextRuns, int64(2))
+	assert.Nil(t, store.NextRuns[2])
+	assert.NotContains(t, store.NextRuns, int64(3))
+
+	// Unchanged next runs are not written again
+	store.Schedules[0].NextRunAt = store.NextRuns[1]
+	store.Schedules[1].NextRunAt = nil
+	store.NextRuns = make(map[int64]*time.Time)
+	store.Schedules[1].Enabled = true
+	assert.NoError(t, s.Reload(context.Background()))
+	assert.NotContains(t, store.NextRuns, int64(1))
+	if assert.NotNil(t, store.NextRuns[2]) {
+		assert.Equal(t, time.Sunday, store.NextRuns[2].Weekday())
+	}
+}
+
+func TestScheduler_RunsAndRecords(t *testing.T) {
+	store := &mockScheduleStore{
+		Schedules: []storage.RefreshSchedule{
+			{ID: 1, System: "NPM", Name: "react", Version: "18.2.0", Cron: "@every 1s", Timezone: "UTC", Enabled: true},
+		},
+		NextRuns: make(map[int64]*time.Time),
+		Runs:     make(chan error, 10),
+	}
+	refreshed := make(chan string, 10)
+	s := &data.Scheduler{
+		Store: store,
+		Refresher: refresherFn(func(ctx context.Context, system, name, version string, opts data.RefreshOptions) error {
+			refreshed <- system + "/" + name + "@" + version
+			return errors.New("deps.dev unavailable")
+		}),
+		Log: logrus.New(),
+	}
+
+	assert.NoError(t, s.Start(context.Background()))
+	defer s.Stop()
+
+	select {
+	case err := <-store.Runs:
+		assert.EqualError(t, err, "deps.dev unavailable")
+		assert.Equal(t, "NPM/react@18.2.0", <-refreshed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("schedule did not run")
+	}
+
+	// Picked up without a restart
+	store.mu.Lock()
+	store.Schedules[0].Enabled = false
+	store.mu.Unlock()
+	assert.NoError(t, s.Reload(context.Background()))
+	store.mu.Lock()
+	assert.Nil(t, store.NextRuns[1])
+	store.mu.Unlock()
+}
+
+func TestConfigureRootSchedule(t *testing.T) {
+	stored := storage.RefreshSchedule{ID: 1, System: "NPM", Name: "react", Version: "18.2.0", Cron: "0 0 * * *", Timezone: "UTC", Enabled: true}
+	tests := []struct {
+		name      string
+		stored    []storage.RefreshSchedule
+		scheduled bool
+		expected  []storage.RefreshSchedule
+	}{
+		{
+			name:      "scheduled creates a schedule",
+			scheduled: true,
+			expected:  []storage.RefreshSchedule{stored},
+		},
+		{
+			name:      "not scheduled creates nothing",
+			scheduled: false,
+			expected:  nil,
+		},
+		{
+			name:      "not scheduled disables a stored schedule",
+			stored:    []storage.RefreshSchedule{stored},
+			scheduled: false,
+			expected: []storage.RefreshSchedule{
+				{ID: 1, System: "NPM", Name: "react", Version: "18.2.0", Cron: "0 0 * * *", Timezone: "UTC", Enabled: false},
+			},
+		},
+		{
+			name: "scheduled updates a stored schedule that differs",
+			stored: []storage.RefreshSchedule{
+				{ID: 1, System: "NPM", Name: "react", Version: "18.2.0", Cron: "@hourly", Timezone: "Europe/Berlin", Enabled: true},
+			},
+			scheduled: true,
+			expected:  []storage.RefreshSchedule{stored},
+		},
+		{
+			name: "scheduled enables a disabled schedule",
+			stored: []storage.RefreshSchedule{
+				{ID: 1, System: "NPM", Name: "react", Version: "18.2.0", Cron: "0 0 * * *", Timezone: "UTC", Enabled: false},
+			},
+			scheduled: true,
+			expected:  []storage.RefreshSchedule{stored},
+		},
+		{
+			name: "other packages keep their schedules",
+			stored: []storage.RefreshSchedule{
+				{ID: 7, System: "NPM", Name: "left-pad", Version: "1.3.0", Cron: "@hourly", Timezone: "UTC", Enabled: true},
+			},
+			scheduled: false,
+			expected: []storage.RefreshSchedule{
+				{ID: 7, System: "NPM", Name: "left-pad", Version: "1.3.0", Cron: "@hourly", Timezone: "UTC", Enabled: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockScheduleStore{Schedules: append([]storage.RefreshSchedule(nil), tt.stored...)}
+			err := data.ConfigureRootSchedule(context.Background(), store, logrus.New(), "npm", "react", "18.2.0", "0 0 * * *", tt.scheduled)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, store.Schedules)
+		})
+	}
+}
+
+func TestScheduler_StopCancelsRunningRefresh(t *testing.T) {
+	store := &mockScheduleStore{
+		Schedules: []storage.RefreshSchedule{
+			{ID: 1, System: "NPM", Name: "react", Version: "18.2.0", Cron: "@every 1s", Timezone: "UTC", Enabled: true},
+		},
+		NextRuns: make(map[int64]*time.Time),
+		Runs:     make(chan error, 10),
+	}
+	started := make(chan struct{}, 10)
+	s := &data.Scheduler{
+		Store: store,
+		Refresher: refresherFn(func(ctx context.Context, system, name, version string, opts data.RefreshOptions) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		Log: logrus.New(),
+	}
+	assert.NoError(t, s.Start(context.Background()))
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("schedule did not run")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not cancel the running refresh")
+	}
+	assert.ErrorIs(t, <-store.Runs, context.Canceled)
+}
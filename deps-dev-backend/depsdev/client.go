@@ -68,33 +68,48 @@ func (c *DepsDevClient) GetPackageMetadata(ctx context.Context, vk VersionKey) (
 
 // Fetch scorecard data for single project
 func (c *DepsDevClient) GetScorecardData(ctx context.Context, meta *PackageVersionMetadata) ScorecardInfo {
-	var projectID string
-	for _, proj := range meta.RelatedProjects {
-		if proj.RelationType == "SOURCE_REPO" {
-			projectID = proj.ProjectKey.ID
-			break
-		}
-	}
+	projectID := meta.SourceRepo()
 
-	var score *float64
+	var (
+		score     *float64
+		lookupErr error
+	)
 	if projectID != "" {
-		projectURL := fmt.Sprintf("%s/projects/%s", c.BaseURL, url.PathEscape(projectID))
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, projectURL, nil)
-		if err == nil {
-			resp, err := c.HTTPClient.Do(req)
-			if err == nil && resp.StatusCode == http.StatusOK {
-				defer resp.Body.Close()
-
-				var projMeta ProjectMetadata
-				if err := json.NewDecoder(resp.Body).Decode(&projMeta); err == nil {
-					score = &projMeta.Scorecard.OverallScore
-				}
-			}
-		}
+		score, lookupErr = c.getProjectScore(ctx, projectID)
 	}
 
 	return ScorecardInfo{
 		SourceRepo:   projectID,
 		OpenSSFScore: score,
+		Err:          lookupErr,
+	}
+}
+
+// Fetch overall scorecard score for a project, nil if deps.dev has none
+func (c *DepsDevClient) getProjectScore(ctx context.Context, projectID string) (*float64, error) {
+	projectURL := fmt.Sprintf("%s/projects/%s", c.BaseURL, url.PathEscape(projectID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, projectURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch project %s: %w", projectID, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("project request failed for %s: %s", projectID, resp.Status)
+	}
+
+	var projMeta ProjectMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&projMeta); err != nil {
+		return nil, fmt.Errorf("failed to decode project metadata: %w", err)
 	}
+	return &projMeta.Scorecard.OverallScore, nil
 }
@@ -198,6 +198,61 @@ func TestGetPackageMetadata(t *testing.T) {
 	}
 }
 
+func TestPackageVersionMetadata_License(t *testing.T) {
+	tests := []struct {
+		licenses []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"MIT"}, "MIT"},
+		{[]string{"MIT OR Apache-2.0"}, "MIT OR Apache-2.0"},
+		{[]string{"BSD-3-Clause", "MIT OR Apache-2.0"}, "BSD-3-Clause AND (MIT OR Apache-2.0)"},
+		{[]string{"non-standard", "ISC"}, ""},
+		{[]string{"MIT", "non-standard"}, ""},
+		{[]string{"", "MIT OR Apache-2.0"}, "MIT OR Apache-2.0"},
+	}
+
+	for _, tt := range tests {
+		meta := &PackageVersionMetadata{Licenses: tt.licenses}
+		if got := meta.License(); got != tt.expected {
+			t.Errorf("License() of %v = %q, expected %q", tt.licenses, got, tt.expected)
+		}
+	}
+}
+
+func TestDependencyGraph_Depths(t *testing.T) {
+	graph := &DependencyGraph{
+		Nodes: []DependencyNode{
+			{Relation: "SELF"},
+			{Relation: "DIRECT"},
+			{Relation: "DIRECT"},
+			{Relation: "INDIRECT"},
+			{Relation: "INDIRECT"},
+			{Relation: "DIRECT"},
+			{Relation: "INDIRECT"},
+		},
+		Edges: []DependencyEdge{
+			{FromNode: 0, ToNode: 1},
+			{FromNode: 0, ToNode: 2},
+			{FromNode: 1, ToNode: 3},
+			{FromNode: 3, ToNode: 4},
+			// A shorter path wins
+			{FromNode: 2, ToNode: 4},
+			// Cycles are followed once
+			{FromNode: 4, ToNode: 1},
+			// Out of range nodes are ignored
+			{FromNode: 4, ToNode: 42},
+		},
+	}
+
+	// Nodes 5 and 6 are not reached by any edge
+	expected := []int{0, 1, 1, 2, 2, 1, 0}
+	got := graph.Depths()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Depths() = %v, expected %v", got, expected)
+	}
+}
+
 func TestGetScorecardData(t *testing.T) {
 	projectID := "github.com/facebook/react"
 
@@ -206,6 +261,7 @@ func TestGetScorecardData(t *testing.T) {
 		statusCode       int
 		body             any
 		expectedScore    *float64
+		expectErr        bool
 		expectedMetadata *PackageVersionMetadata
 	}{
 		{
@@ -249,6 +305,22 @@ func TestGetScorecardData(t *testing.T) {
 			statusCode:    http.StatusOK,
 			body:          "bad-json",
 			expectedScore: nil,
+			expectErr:     true,
+			expectedMetadata: &PackageVersionMetadata{
+				RelatedProjects: []RelatedProject{
+					{
+						ProjectKey:   ProjectKey{ID: projectID},
+						RelationType: "SOURCE_REPO",
+					},
+				},
+			},
+		},
+		{
+			name:          "Server error",
+			statusCode:    http.StatusServiceUnavailable,
+			body:          nil,
+			expectedScore: nil,
+			expectErr:     true,
 			expectedMetadata: &PackageVersionMetadata{
 				RelatedProjects: []RelatedProject{
 					{
@@ -295,6 +367,12 @@ func TestGetScorecardData(t *testing.T) {
 					t.Errorf("expected score %v, got %v", *tt.expectedScore, *result.OpenSSFScore)
 				}
 			}
+			if tt.expectErr && result.Err == nil {
+				t.Errorf("expected lookup error, got nil")
+			}
+			if !tt.expectErr && result.Err != nil {
+				t.Errorf("unexpected lookup error: %v", result.Err)
+			}
 		})
 	}
 }
@@ -1,5 +1,7 @@
 package depsdev
 
+import "strings"
+
 type VersionKey struct {
 	System  string `json:"system"`
 	Name    string `json:"name"`
@@ -9,13 +11,61 @@ type VersionKey struct {
 type DependencyNode struct {
 	VersionKey VersionKey `json:"versionKey"`
 	Relation   string     `json:"relation"`
+	// Depth is the shortest distance from the root, 0 if unknown. deps.dev
+	// does not send it; see DependencyGraph.Depths.
+	Depth int `json:"-"`
+}
+
+type DependencyEdge struct {
+	FromNode    int    `json:"fromNode"`
+	ToNode      int    `json:"toNode"`
+	Requirement string `json:"requirement"`
 }
 
 type DependencyGraph struct {
 	Nodes []DependencyNode `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
 	Error string           `json:"error"`
 }
 
+// Depths returns the shortest distance of every node from the root, the
+// first node: 1 for DIRECT dependencies, 2 for theirs and so on. Nodes the
+// edges do not reach fall back to 1 if DIRECT and 0 otherwise.
+func (g *DependencyGraph) Depths() []int {
+	depths := make([]int, len(g.Nodes))
+	if len(g.Nodes) == 0 {
+		return depths
+	}
+
+	children := make(map[int][]int)
+	for _, e := range g.Edges {
+		children[e.FromNode] = append(children[e.FromNode], e.ToNode)
+	}
+
+	reached := make([]bool, len(g.Nodes))
+	reached[0] = true
+	queue := []int{0}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, child := range children[node] {
+			if child < 0 || child >= len(g.Nodes) || reached[child] {
+				continue
+			}
+			reached[child] = true
+			depths[child] = depths[node] + 1
+			queue = append(queue, child)
+		}
+	}
+
+	for i, node := range g.Nodes {
+		if !reached[i] && node.Relation == "DIRECT" {
+			depths[i] = 1
+		}
+	}
+	return depths
+}
+
 type ProjectKey struct {
 	ID string `json:"id"`
 }
@@ -28,6 +78,44 @@ type RelatedProject struct {
 
 type PackageVersionMetadata struct {
 	RelatedProjects []RelatedProject `json:"relatedProjects"`
+	// Licenses are SPDX expressions; "non-standard" marks unrecognized ones
+	Licenses []string `json:"licenses"`
+	// Provider names the metadata provider that answered, if not deps.dev
+	// itself; see providers.Chain
+	Provider string `json:"-"`
+}
+
+// SourceRepo is the ID of the version's source repository project, or "" if
+// none is known.
+func (m *PackageVersionMetadata) SourceRepo() string {
+	for _, proj := range m.RelatedProjects {
+		if proj.RelationType == "SOURCE_REPO" {
+			return proj.ProjectKey.ID
+		}
+	}
+	return ""
+}
+
+// License combines the version's licenses into one SPDX expression, or ""
+// if none is known. A "non-standard" license makes the whole expression
+// unknown, as leaving it out would claim fewer obligations than apply.
+func (m *PackageVersionMetadata) License() string {
+	var licenses []string
+	for _, license := range m.Licenses {
+		if license == "non-standard" {
+			return ""
+		}
+		if license != "" {
+			licenses = append(licenses, license)
+		}
+	}
+
+	for i, license := range licenses {
+		if len(licenses) > 1 && strings.Contains(license, " ") {
+			licenses[i] = "(" + license + ")"
+		}
+	}
+	return strings.Join(licenses, " AND ")
 }
 
 type ProjectMetadata struct {
@@ -39,4 +127,9 @@ type ProjectMetadata struct {
 type ScorecardInfo struct {
 	SourceRepo   string
 	OpenSSFScore *float64
+	// Err is set when the project lookup failed for a reason other than the
+	// project being unknown to deps.dev, so callers can retry it later.
+	Err error
+	// Provider names the metadata provider that supplied the score
+	Provider string
 }
@@ -0,0 +1,193 @@
+package depsdev
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Transport modes
+const (
+	ModeLive   = "live"
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// ErrNoFixture is returned when replaying a request that was never recorded.
+var ErrNoFixture = errors.New("no recorded response")
+
+// Fixture is a recorded response, stored as one JSON file per request.
+type Fixture struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	// Body holds JSON responses as they are, so fixtures stay readable and
+	// can be edited by hand; other bodies are kept in Text.
+	Body json.RawMessage `json:"body,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+// NewTransport returns the transport for a mode: next itself when live (or
+// mode is empty), or a recording or replaying transport using dir.
+func NewTransport(mode, dir string, next http.RoundTripper) (http.RoundTripper, error) {
+	switch mode {
+	case "", ModeLive:
+		return next, nil
+	case ModeRecord:
+		return &RecordTransport{Dir: dir, Next: next}, nil
+	case ModeReplay:
+		return &ReplayTransport{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown deps.dev mode %q, expected %s, %s or %s", mode, ModeLive, ModeRecord, ModeReplay)
+	}
+}
+
+// RecordTransport sends requests with Next and saves every response it
+// receives, errors included, as a fixture in Dir. A later recording of the
+// same request replaces the fixture.
+type RecordTransport struct {
+	Dir  string
+	Next http.RoundTripper
+}
+
+func (t *RecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f := Fixture{
+		Method:      req.Method,
+		URL:         requestKey(req),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if json.Valid(body) {
+		f.Body = body
+	} else {
+		f.Text = string(body)
+	}
+	if err := writeFixture(filepath.Join(t.Dir, fixtureName(req)), f); err != nil {
+		return nil, fmt.Errorf("failed to record %s: %w", f.URL, err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// ReplayTransport answers requests from the fixtures in Dir without using
+// the network. Requests without a fixture fail with ErrNoFixture.
+type ReplayTransport struct {
+	Dir string
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	data, err := os.ReadFile(filepath.Join(t.Dir, fixtureName(req)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoFixture, req.Method, requestKey(req))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fixture for %s: %w", requestKey(req), err)
+	}
+
+	body := []byte(f.Text)
+	if len(f.Body) > 0 {
+		body = f.Body
+	}
+	header := make(http.Header)
+	if f.ContentType != "" {
+		header.Set("Content-Type", f.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// requestKey identifies a request independently of the host, so fixtures
+// recorded against one base URL replay against another with the same path.
+func requestKey(req *http.Request) string {
+	key := req.URL.EscapedPath()
+	if req.URL.RawQuery != "" {
+		key += "?" + req.URL.RawQuery
+	}
+	return key
+}
+
+// fixtureName is a readable file name for a request, made unique by a hash
+// of its key: GET_systems_NPM_packages_react_versions_18.2.0-1a2b3c4d5e6f.json
+func fixtureName(req *http.Request) string {
+	key := requestKey(req)
+	sum := sha256.Sum256([]byte(req.Method + " " + key))
+
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimPrefix(key, "/"))
+	if len(slug) > 80 {
+		slug = slug[:80]
+	}
+	return req.Method + "_" + slug + "-" + hex.EncodeToString(sum[:6]) + ".json"
+}
+
+// writeFixture writes through a temporary file, so concurrent recordings of
+// the same request never leave a partial fixture behind.
+func writeFixture(path string, f Fixture) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fixture-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
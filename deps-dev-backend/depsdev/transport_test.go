@@ -0,0 +1,85 @@
+package depsdev
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/v3/systems/npm/packages/react/versions/18.2.0":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"relatedProjects": [{"projectKey": {"id": "github.com/facebook/react"}, "relationType": "SOURCE_REPO"}], "licenses": ["MIT"]}`)
+		case "/v3/projects/github.com%2Ffacebook%2Freact":
+			fmt.Fprint(w, `{"scorecard": {"overallScore": 8.1}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	recorder := &DepsDevClient{
+		BaseURL:    server.URL + "/v3",
+		HTTPClient: &http.Client{Transport: &RecordTransport{Dir: dir, Next: http.DefaultTransport}},
+	}
+	meta, err := recorder.GetPackageMetadata(context.Background(), VersionKey{System: "npm", Name: "react", Version: "18.2.0"})
+	assert.NoError(t, err)
+	recorded := recorder.GetScorecardData(context.Background(), meta)
+	assert.NoError(t, recorded.Err)
+	_, err = recorder.GetPackageMetadata(context.Background(), VersionKey{System: "npm", Name: "missing", Version: "1.0.0"})
+	assert.Error(t, err)
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	// Replay ignores the host, the recording server is gone
+	server.Close()
+	replayer := &DepsDevClient{
+		BaseURL:    "https://api.deps.dev/v3",
+		HTTPClient: &http.Client{Transport: &ReplayTransport{Dir: dir}},
+	}
+	replayed, err := replayer.GetPackageMetadata(context.Background(), VersionKey{System: "npm", Name: "react", Version: "18.2.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, meta, replayed)
+	assert.Equal(t, recorded, replayer.GetScorecardData(context.Background(), replayed))
+
+	_, err = replayer.GetPackageMetadata(context.Background(), VersionKey{System: "npm", Name: "missing", Version: "1.0.0"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "404 Not Found")
+
+	_, err = replayer.GetDependencyGraph(context.Background(), "npm", "react", "18.2.0")
+	assert.ErrorIs(t, err, ErrNoFixture)
+	assert.Contains(t, err.Error(), "/v3/systems/npm/packages/react/versions/18.2.0:dependencies")
+}
+
+func TestNewTransport(t *testing.T) {
+	next := http.DefaultTransport
+
+	tests := []struct {
+		mode     string
+		expected http.RoundTripper
+	}{
+		{"", next},
+		{ModeLive, next},
+		{ModeRecord, &RecordTransport{Dir: "fixtures", Next: next}},
+		{ModeReplay, &ReplayTransport{Dir: "fixtures"}},
+	}
+	for _, tt := range tests {
+		transport, err := NewTransport(tt.mode, "fixtures", next)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, transport)
+	}
+
+	_, err := NewTransport("mock", "fixtures", next)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown deps.dev mode "mock"`)
+}
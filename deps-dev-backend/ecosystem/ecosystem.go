@@ -0,0 +1,153 @@
+// Package ecosystem knows the package systems deps.dev covers and how each
+// spells its package keys, so the same package version is always stored
+// under the same system, name and version.
+package ecosystem
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Systems as deps.dev names them
+const (
+	NPM      = "NPM"
+	Go       = "GO"
+	Maven    = "MAVEN"
+	PyPI     = "PYPI"
+	NuGet    = "NUGET"
+	Cargo    = "CARGO"
+	RubyGems = "RUBYGEMS"
+)
+
+var ErrUnknownSystem = errors.New("unknown system")
+
+// Ecosystem holds the key rules of one deps.dev system.
+type Ecosystem struct {
+	// System is the canonical deps.dev name, e.g. "PYPI"
+	System string
+	// name and version canonicalize a trimmed, non-empty name or version,
+	// failing for ones the system cannot have
+	name    func(string) (string, error)
+	version func(string) (string, error)
+}
+
+var registry = map[string]Ecosystem{
+	NPM:      {System: NPM, name: npmName},
+	Go:       {System: Go, name: goModulePath, version: goVersion},
+	Maven:    {System: Maven, name: mavenName},
+	PyPI:     {System: PyPI, name: pypiName},
+	NuGet:    {System: NuGet},
+	Cargo:    {System: Cargo},
+	RubyGems: {System: RubyGems},
+}
+
+// Lookup returns the ecosystem of a system name in any case, e.g. "npm".
+func Lookup(system string) (Ecosystem, bool) {
+	e, ok := registry[strings.ToUpper(strings.TrimSpace(system))]
+	return e, ok
+}
+
+// Systems returns the canonical names of all systems, sorted.
+func Systems() []string {
+	systems := make([]string, 0, len(registry))
+	for system := range registry {
+		systems = append(systems, system)
+	}
+	sort.Strings(systems)
+	return systems
+}
+
+// Canonicalize returns the canonical key of a package version: the system
+// in upper case and the name and version spelled the way deps.dev stores
+// them. An empty version is left empty.
+func Canonicalize(system, name, version string) (string, string, string, error) {
+	e, ok := Lookup(system)
+	if !ok {
+		return "", "", "", fmt.Errorf("%w %q, expected one of %s", ErrUnknownSystem, system, strings.Join(Systems(), ", "))
+	}
+
+	name, err := e.Name(name)
+	if err != nil {
+		return "", "", "", err
+	}
+	version, err = e.Version(version)
+	if err != nil {
+		return "", "", "", err
+	}
+	return e.System, name, version, nil
+}
+
+// Name canonicalizes a package name of the ecosystem.
+func (e Ecosystem) Name(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("missing %s package name", e.System)
+	}
+	if e.name == nil {
+		return name, nil
+	}
+	return e.name(name)
+}
+
+// Version canonicalizes a version of the ecosystem's packages.
+func (e Ecosystem) Version(version string) (string, error) {
+	version = strings.TrimSpace(version)
+	if version == "" || e.version == nil {
+		return version, nil
+	}
+	return e.version(version)
+}
+
+// npmName accepts name and @scope/name.
+func npmName(name string) (string, error) {
+	scope, pkg, scoped := strings.Cut(name, "/")
+	if scoped {
+		if !strings.HasPrefix(scope, "@") || len(scope) == 1 || pkg == "" || strings.Contains(pkg, "/") {
+			return "", fmt.Errorf("invalid NPM package name %q, expected name or @scope/name", name)
+		}
+	} else if strings.HasPrefix(name, "@") {
+		return "", fmt.Errorf("invalid NPM package name %q, expected name or @scope/name", name)
+	}
+	return name, nil
+}
+
+// goModulePath accepts module paths like github.com/go-chi/chi/v5.
+func goModulePath(name string) (string, error) {
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.Contains(name, "//") ||
+		strings.ContainsAny(name, " \t") {
+		return "", fmt.Errorf("invalid GO module path %q", name)
+	}
+	return name, nil
+}
+
+// goVersion adds the v prefix of Go module versions, so 1.2.3 is v1.2.3.
+func goVersion(version string) (string, error) {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version, nil
+}
+
+// mavenName accepts groupId:artifactId.
+func mavenName(name string) (string, error) {
+	group, artifact, ok := strings.Cut(name, ":")
+	if !ok || group == "" || artifact == "" || strings.Contains(artifact, ":") {
+		return "", fmt.Errorf("invalid MAVEN package name %q, expected groupId:artifactId", name)
+	}
+	return name, nil
+}
+
+var pypiSeparators = regexp.MustCompile(`[-_.]+`)
+
+// NormalizePyPIName normalizes a distribution name as described in PEP 503,
+// so "Foo.Bar", "foo_bar" and "foo-bar" are stored as the same package.
+func NormalizePyPIName(name string) string {
+	return strings.ToLower(pypiSeparators.ReplaceAllString(name, "-"))
+}
+
+func pypiName(name string) (string, error) {
+	return NormalizePyPIName(name), nil
+}
@@ -0,0 +1,80 @@
+package ecosystem
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		system, name, version string
+		expected              []string
+	}{
+		{"npm", "react", "18.2.0", []string{NPM, "react", "18.2.0"}},
+		{" Npm ", " @babel/core ", " 7.22.0 ", []string{NPM, "@babel/core", "7.22.0"}},
+		{"go", "github.com/go-chi/chi/v5", "v5.0.10", []string{Go, "github.com/go-chi/chi/v5", "v5.0.10"}},
+		{"GO", "golang.org/x/mod", "0.12.0", []string{Go, "golang.org/x/mod", "v0.12.0"}},
+		{"maven", "org.slf4j:slf4j-api", "2.0.9", []string{Maven, "org.slf4j:slf4j-api", "2.0.9"}},
+		{"pypi", "Django_REST.framework", "3.14.0", []string{PyPI, "django-rest-framework", "3.14.0"}},
+		{"nuget", "Newtonsoft.Json", "13.0.3", []string{NuGet, "Newtonsoft.Json", "13.0.3"}},
+		{"cargo", "serde_json", "1.0.105", []string{Cargo, "serde_json", "1.0.105"}},
+		{"rubygems", "rails", "7.0.8", []string{RubyGems, "rails", "7.0.8"}},
+		{"npm", "react", "", []string{NPM, "react", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.system+" "+tt.name, func(t *testing.T) {
+			system, name, version, err := Canonicalize(tt.system, tt.name, tt.version)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, []string{system, name, version})
+		})
+	}
+}
+
+func TestCanonicalize_Errors(t *testing.T) {
+	tests := []struct {
+		system, name  string
+		expectedError string
+	}{
+		{"bower", "jquery", `unknown system "bower", expected one of CARGO, GO, MAVEN, NPM, NUGET, PYPI, RUBYGEMS`},
+		{"", "react", `unknown system ""`},
+		{"npm", " ", "missing NPM package name"},
+		{"npm", "@babel", `invalid NPM package name "@babel"`},
+		{"npm", "babel/core", `invalid NPM package name "babel/core"`},
+		{"npm", "@babel/core/extra", `invalid NPM package name "@babel/core/extra"`},
+		{"go", "/github.com/x/y", `invalid GO module path "/github.com/x/y"`},
+		{"go", "github.com//y", `invalid GO module path "github.com//y"`},
+		{"maven", "slf4j-api", `invalid MAVEN package name "slf4j-api", expected groupId:artifactId`},
+		{"maven", "org.slf4j:slf4j-api:jar", `invalid MAVEN package name "org.slf4j:slf4j-api:jar"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.system+" "+tt.name, func(t *testing.T) {
+			_, _, _, err := Canonicalize(tt.system, tt.name, "1.0.0")
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+func TestCanonicalize_UnknownSystemIsWrapped(t *testing.T) {
+	_, _, _, err := Canonicalize("bower", "jquery", "3.0.0")
+	assert.True(t, errors.Is(err, ErrUnknownSystem))
+}
+
+func TestLookup(t *testing.T) {
+	e, ok := Lookup("PyPI")
+	assert.True(t, ok)
+	assert.Equal(t, PyPI, e.System)
+
+	_, ok = Lookup("deb")
+	assert.False(t, ok)
+}
+
+func TestNormalizePyPIName(t *testing.T) {
+	for _, name := range []string{"Friendly-Bard", "friendly.bard", "friendly_bard", "FRIENDLY--._bard"} {
+		assert.Equal(t, "friendly-bard", NormalizePyPIName(name))
+	}
+}
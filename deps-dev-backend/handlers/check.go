@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"deps-dev/ecosystem"
+	"deps-dev/importers"
+	"deps-dev/policy"
+	"deps-dev/storage"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Check verdicts
+const (
+	VerdictPass = "PASS"
+	VerdictFail = "FAIL"
+)
+
+// CheckRequest names the package version to check, by key or package URL.
+type CheckRequest struct {
+	System  string `json:"system,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+type CheckResponse struct {
+	// Verdict is FAIL if any violation is not waived, PASS otherwise
+	Verdict    string                    `json:"verdict"`
+	Checked    int                       `json:"checked"`
+	Violations []storage.PolicyViolation `json:"violations"`
+	Waived     []storage.PolicyViolation `json:"waived"`
+	// Issues lists manifest entries that could not be checked
+	Issues []importers.Issue `json:"issues,omitempty"`
+}
+
+// Check resolves a package version or an uploaded manifest through deps.dev
+// and checks it against the active policy and waivers, without storing
+// anything. A package is checked as if it were added as a direct dependency,
+// together with its dependency graph. Manifests are uploaded as to the
+// /imports routes, naming the format with the manifest query parameter,
+// e.g. ?manifest=npm-lockfile.
+func (h *Handler) Check(w http.ResponseWriter, r *http.Request) {
+	var (
+		deps   []storage.Dependency
+		issues []importers.Issue
+		err    error
+	)
+
+	if name := r.URL.Query().Get("manifest"); name != "" {
+		m, ok := manifests[name]
+		if !ok {
+			http.Error(w, "unknown manifest type", http.StatusBadRequest)
+			return
+		}
+		result, ok := h.parseManifest(w, r, m)
+		if !ok {
+			return
+		}
+		issues = result.Issues
+
+		deps, err = h.DataManager.ResolveDependencies(r.Context(), result.Dependencies)
+		if err != nil {
+			h.Log.WithError(err).Errorf("resolving dependencies from %s", m.kind)
+			http.Error(w, "failed to resolve dependencies", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		dep, ok := decodeCheckRequest(w, r)
+		if !ok {
+			return
+		}
+
+		deps, err = h.DataManager.ResolvePackage(r.Context(), dep.System, dep.Name, dep.Version)
+		if err != nil {
+			h.Log.WithError(err).Error("resolving package to check")
+			http.Error(w, "failed to resolve package: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	p, _, err := h.Policies.Load(r.Context())
+	if err != nil {
+		h.Log.WithError(err).Error("loading policy")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	waivers, err := h.Store.ListWaivers(r.Context())
+	if err != nil {
+		h.Log.WithError(err).Error("listing waivers")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := checkDependencies(p, waivers, deps, time.Now())
+	resp.Issues = issues
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Log.WithError(err).Error("encoding check response")
+	}
+}
+
+func checkDependencies(p policy.Policy, waivers []storage.Waiver, deps []storage.Dependency, now time.Time) CheckResponse {
+	var found []storage.PolicyViolation
+	for _, dep := range deps {
+		found = append(found, p.Check(dep)...)
+	}
+	for i := range found {
+		found[i].DetectedAt = now.UTC()
+	}
+	policy.ApplyWaivers(found, waivers, now)
+
+	resp := CheckResponse{
+		Verdict:    VerdictPass,
+		Checked:    len(deps),
+		Violations: []storage.PolicyViolation{},
+		Waived:     []storage.PolicyViolation{},
+	}
+	for _, v := range found {
+		if v.WaiverID != nil {
+			resp.Waived = append(resp.Waived, v)
+		} else {
+			resp.Violations = append(resp.Violations, v)
+		}
+	}
+	if len(resp.Violations) > 0 {
+		resp.Verdict = VerdictFail
+	}
+	return resp
+}
+
+// decodeCheckRequest reads the canonical key of the package to check. On
+// failure it writes the error response and returns false.
+func decodeCheckRequest(w http.ResponseWriter, r *http.Request) (storage.Dependency, bool) {
+	var input CheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return storage.Dependency{}, false
+	}
+
+	dep := storage.Dependency{System: input.System, Name: input.Name, Version: input.Version, PURL: input.PURL}
+	if dep.PURL != "" {
+		if err := applyPURL(&dep); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return storage.Dependency{}, false
+		}
+	}
+
+	if dep.System == "" || dep.Name == "" || dep.Version == "" {
+		http.Error(w, "system, name, and version are required", http.StatusBadRequest)
+		return storage.Dependency{}, false
+	}
+
+	system, name, version, err := ecosystem.Canonicalize(dep.System, dep.Name, dep.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return storage.Dependency{}, false
+	}
+	return storage.Dependency{System: system, Name: name, Version: version}, true
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"deps-dev/importers"
+	"deps-dev/storage"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Datasets are bulk exports, far larger than manifests
+const maxDatasetSize = 1 << 30
+
+type DatasetImportResponse struct {
+	Packages int               `json:"packages"`
+	Scores   int               `json:"scores"`
+	Skipped  int               `json:"skipped"`
+	Issues   []importers.Issue `json:"issues"`
+}
+
+var datasetFormats = map[string]func(io.Reader, importers.DatasetSink) (*importers.Dataset, error){
+	"csv":       importers.ParseDatasetCSV,
+	"json":      importers.ParseDatasetJSON,
+	"scorecard": importers.ParseScorecardResults,
+}
+
+// ImportDataset loads bulk package metadata or OpenSSF Scorecard results for
+// the imported metadata provider. The format query parameter is csv or json
+// for a deps.dev dump, or scorecard. The dataset is stored all or nothing:
+// an upload that turns out to be invalid halfway through stores no rows.
+func (h *Handler) ImportDataset(w http.ResponseWriter, r *http.Request) {
+	parse, ok := datasetFormats[r.URL.Query().Get("format")]
+	if !ok {
+		http.Error(w, "format must be csv, json or scorecard", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxDatasetSize)
+
+	var (
+		dataset            *importers.Dataset
+		parseErr, stageErr error
+	)
+	err := h.Store.ImportDataset(r.Context(), func(stage storage.DatasetStager) error {
+		dataset, parseErr = parse(r.Body, func(packages []storage.DatasetPackage, scores []storage.ProjectScore) error {
+			stageErr = stage(packages, scores)
+			return stageErr
+		})
+		return parseErr
+	})
+	if parseErr != nil && stageErr == nil {
+		h.Log.WithError(parseErr).Warn("parsing uploaded dataset")
+		http.Error(w, "invalid dataset: "+parseErr.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.Log.WithError(err).Error("importing dataset")
+		http.Error(w, "failed to import dataset", http.StatusInternalServerError)
+		return
+	}
+	h.Log.Infof("Imported dataset with %d packages and %d scores", dataset.Packages, dataset.Scores)
+
+	resp := DatasetImportResponse{
+		Packages: dataset.Packages,
+		Scores:   dataset.Scores,
+		Skipped:  dataset.Skipped,
+		Issues:   dataset.Issues,
+	}
+	if resp.Issues == nil {
+		resp.Issues = []importers.Issue{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Log.WithError(err).Error("encoding dataset import response")
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"deps-dev/sbom"
+	"encoding/json"
+	"net/http"
+)
+
+// ExportSPDX renders the stored dependencies, filtered like
+// ListDependencies, as an SPDX document: JSON by default, or tag-value with
+// format=tag-value.
+func (h *Handler) ExportSPDX(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "tag-value" {
+		http.Error(w, "invalid format value", http.StatusBadRequest)
+		return
+	}
+
+	deps, ok := h.listFiltered(w, r)
+	if !ok {
+		return
+	}
+
+	doc := sbom.SPDX(deps, sbom.NewInfo())
+
+	if format == "tag-value" {
+		w.Header().Set("Content-Type", "text/spdx; charset=utf-8")
+		if err := doc.WriteTagValue(w); err != nil {
+			h.Log.WithError(err).Error("writing SPDX tag-value export")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/spdx+json")
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		h.Log.WithError(err).Error("encoding SPDX export")
+	}
+}
+
+// ExportCycloneDX renders the stored dependencies, filtered like
+// ListDependencies, as a CycloneDX JSON document.
+func (h *Handler) ExportCycloneDX(w http.ResponseWriter, r *http.Request) {
+	deps, ok := h.listFiltered(w, r)
+	if !ok {
+		return
+	}
+
+	bom := sbom.CycloneDX(deps, sbom.NewInfo())
+
+	w.Header().Set("Content-Type", "application/vnd.cyclonedx+json; version="+sbom.CycloneDXSpecVersion)
+	if err := json.NewEncoder(w).Encode(bom); err != nil {
+		h.Log.WithError(err).Error("encoding CycloneDX export")
+	}
+}
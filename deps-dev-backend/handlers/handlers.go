@@ -3,10 +3,14 @@ package handlers
 import (
 	"context"
 	"deps-dev/config"
+	"deps-dev/data"
+	"deps-dev/ecosystem"
 	"deps-dev/storage"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
@@ -14,22 +18,94 @@ import (
 
 type Storage interface {
 	ListDependenciesFiltered(ctx context.Context, name string, minScore *float64) ([]storage.Dependency, error)
+	ForEachDependency(ctx context.Context, name string, minScore *float64, fn func(storage.Dependency) error) error
 	GetDependency(ctx context.Context, system, name, version string) (storage.Dependency, error)
 	UpsertDependency(ctx context.Context, dep storage.Dependency) error
 	DeleteDependency(ctx context.Context, system, name, version string) error
+	ListFetchFailures(ctx context.Context) ([]storage.FetchFailure, error)
+	ListPolicyViolations(ctx context.Context, rule string) ([]storage.PolicyViolation, error)
+	ListWaivers(ctx context.Context) ([]storage.Waiver, error)
+	GetWaiver(ctx context.Context, id int64) (storage.Waiver, error)
+	CreateWaiver(ctx context.Context, w storage.Waiver) (storage.Waiver, error)
+	UpdateWaiver(ctx context.Context, w storage.Waiver) (storage.Waiver, error)
+	DeleteWaiver(ctx context.Context, id int64) error
+	ImportDataset(ctx context.Context, load func(stage storage.DatasetStager) error) error
+	ListRefreshSchedules(ctx context.Context) ([]storage.RefreshSchedule, error)
+	GetRefreshSchedule(ctx context.Context, id int64) (storage.RefreshSchedule, error)
+	CreateRefreshSchedule(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error)
+	UpdateRefreshSchedule(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error)
+	DeleteRefreshSchedule(ctx context.Context, id int64) error
 }
 
 type DataManager interface {
-	RefreshDependencies(ctx context.Context, system, name, version string) error
+	RefreshDependencies(ctx context.Context, system, name, version string, opts data.RefreshOptions) error
+	ImportDependencies(ctx context.Context, deps []storage.Dependency) (int, error)
+	ResolvePackage(ctx context.Context, system, name, version string) ([]storage.Dependency, error)
+	ResolveDependencies(ctx context.Context, deps []storage.Dependency) ([]storage.Dependency, error)
 }
 
+// DependencyRoute addresses a single dependency. The wildcard holds the
+// name and version, so names may contain slashes: npm scopes like
+// /dependencies/NPM/@babel/core/7.22.0 and Go module paths like
+// /dependencies/GO/github.com/go-chi/chi/v5/v5.0.10.
+const DependencyRoute = "/dependencies/{system}/*"
+
 type Handler struct {
 	Store       Storage
 	DataManager DataManager
+	Policies    Policies
+	Scheduler   Scheduler
 	Log         *logrus.Logger
+	// Root is the package refreshed by RefreshHandler, the default one when
+	// unset.
+	Root config.Package
 }
 
+// ListDependencies writes the dependencies matching the name and min_score
+// query parameters as a JSON array, or streams them as CSV or NDJSON when
+// asked for with format= or the Accept header.
 func (h *Handler) ListDependencies(w http.ResponseWriter, r *http.Request) {
+	format, ok := listFormat(r)
+	if !ok {
+		http.Error(w, "invalid format value", http.StatusBadRequest)
+		return
+	}
+	if format != formatJSON {
+		h.streamDependencies(w, r, format)
+		return
+	}
+
+	deps, ok := h.listFiltered(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(deps); err != nil {
+		h.Log.WithError(err).Error("encoding dependencies list response")
+	}
+}
+
+// listFiltered lists dependencies matching the name and min_score query
+// parameters. On failure it writes the error response and returns false.
+func (h *Handler) listFiltered(w http.ResponseWriter, r *http.Request) ([]storage.Dependency, bool) {
+	name, minScore, ok := listFilters(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	deps, err := h.Store.ListDependenciesFiltered(r.Context(), name, minScore)
+	if err != nil {
+		h.Log.WithError(err).Error("listing dependencies with filters")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return deps, true
+}
+
+// listFilters parses the name and min_score query parameters. On failure it
+// writes the error response and returns false.
+func listFilters(w http.ResponseWriter, r *http.Request) (string, *float64, bool) {
 	name := r.URL.Query().Get("name")
 	minScoreStr := r.URL.Query().Get("min_score")
 
@@ -39,33 +115,66 @@ func (h *Handler) ListDependencies(w http.ResponseWriter, r *http.Request) {
 			minScore = &score
 		} else {
 			http.Error(w, "invalid min_score value", http.StatusBadRequest)
-			return
+			return "", nil, false
 		}
 	}
+	return name, minScore, true
+}
 
-	deps, err := h.Store.ListDependenciesFiltered(r.Context(), name, minScore)
-	if err != nil {
-		h.Log.WithError(err).Error("listing dependencies with filters")
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+func (h *Handler) GetDependency(w http.ResponseWriter, r *http.Request) {
+	system, name, version, ok := dependencyKey(w, r)
+	if !ok {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(deps); err != nil {
-		h.Log.WithError(err).Error("encoding dependencies list response")
+	h.writeDependency(w, r, system, name, version)
+}
+
+// dependencyKey reads the dependency a request routed with DependencyRoute
+// addresses, canonicalized so "npm" finds packages stored under "NPM". On
+// failure it writes the error response and returns false.
+func dependencyKey(w http.ResponseWriter, r *http.Request) (string, string, string, bool) {
+	system, name, version := dependencyPath(r)
+	if system == "" || name == "" || version == "" {
+		http.Error(w, "missing path parameters", http.StatusBadRequest)
+		return "", "", "", false
 	}
+
+	system, name, version, err := ecosystem.Canonicalize(system, name, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", "", "", false
+	}
+	return system, name, version, true
 }
 
-func (h *Handler) GetDependency(w http.ResponseWriter, r *http.Request) {
+// dependencyPath reads the system, name and version from a request routed
+// with DependencyRoute. The version is the last path segment and the name
+// everything before it, with slashes written as is or encoded as %2F.
+func dependencyPath(r *http.Request) (string, string, string) {
 	system := chi.URLParam(r, "system")
-	name := chi.URLParam(r, "name")
-	version := chi.URLParam(r, "version")
+	rest := chi.URLParam(r, "*")
 
-	if system == "" || name == "" || version == "" {
-		http.Error(w, "missing path parameters", http.StatusBadRequest)
-		return
+	// chi matches against the escaped path when the URL has encoded
+	// characters a decoded path cannot represent, like %2F
+	if r.URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(system); err == nil {
+			system = unescaped
+		}
+		if unescaped, err := url.PathUnescape(rest); err == nil {
+			rest = unescaped
+		}
 	}
 
+	i := strings.LastIndex(rest, "/")
+	if i < 0 {
+		return system, "", ""
+	}
+	return system, rest[:i], rest[i+1:]
+}
+
+// writeDependency writes the stored dependency as JSON, or 404 if there is none.
+func (h *Handler) writeDependency(w http.ResponseWriter, r *http.Request, system, name, version string) {
 	dep, err := h.Store.GetDependency(r.Context(), system, name, version)
 	if err != nil {
 		h.Log.WithFields(logrus.Fields{
@@ -90,11 +199,26 @@ func (h *Handler) CreateDependency(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if dep.PURL != "" {
+		if err := applyPURL(&dep); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	if dep.System == "" || dep.Name == "" || dep.Version == "" {
 		http.Error(w, "system, name, and version are required", http.StatusBadRequest)
 		return
 	}
 
+	system, name, version, err := ecosystem.Canonicalize(dep.System, dep.Name, dep.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dep.System, dep.Name, dep.Version = system, name, version
+	setManualProvenance(&dep)
+
 	existing, err := h.Store.GetDependency(r.Context(), dep.System, dep.Name, dep.Version)
 	if err == nil && existing.Name != "" {
 		http.Error(w, "dependency already exists", http.StatusConflict)
@@ -106,10 +230,26 @@ func (h *Handler) CreateDependency(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to create dependency", http.StatusInternalServerError)
 		return
 	}
+	h.evaluatePolicies(r.Context())
 
 	w.WriteHeader(http.StatusCreated)
 }
 
+// setManualProvenance marks the values of a dependency sent to the API as
+// entered by hand, whatever provenance the client claims.
+func setManualProvenance(dep *storage.Dependency) {
+	dep.SourceRepoProvider, dep.LicenseProvider, dep.ScoreProvider = "", "", ""
+	if dep.SourceRepo != "" {
+		dep.SourceRepoProvider = storage.ProviderManual
+	}
+	if dep.License != "" {
+		dep.LicenseProvider = storage.ProviderManual
+	}
+	if dep.OpenSSFScore != nil {
+		dep.ScoreProvider = storage.ProviderManual
+	}
+}
+
 type DependencyUpdateRequest struct {
 	Relation     *string  `json:"relation,omitempty"`
 	SourceRepo   *string  `json:"source_repo,omitempty"`
@@ -117,9 +257,10 @@ type DependencyUpdateRequest struct {
 }
 
 func (h *Handler) UpdateDependency(w http.ResponseWriter, r *http.Request) {
-	system := chi.URLParam(r, "system")
-	name := chi.URLParam(r, "name")
-	version := chi.URLParam(r, "version")
+	system, name, version, ok := dependencyKey(w, r)
+	if !ok {
+		return
+	}
 
 	var input DependencyUpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -138,9 +279,11 @@ func (h *Handler) UpdateDependency(w http.ResponseWriter, r *http.Request) {
 	}
 	if input.SourceRepo != nil {
 		current.SourceRepo = *input.SourceRepo
+		current.SourceRepoProvider = storage.ProviderManual
 	}
 	if input.OpenSSFScore != nil {
 		current.OpenSSFScore = input.OpenSSFScore
+		current.ScoreProvider = storage.ProviderManual
 	}
 
 	if err := h.Store.UpsertDependency(r.Context(), current); err != nil {
@@ -148,17 +291,14 @@ func (h *Handler) UpdateDependency(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to update dependency", http.StatusInternalServerError)
 		return
 	}
+	h.evaluatePolicies(r.Context())
 
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) DeleteDependency(w http.ResponseWriter, r *http.Request) {
-	system := chi.URLParam(r, "system")
-	name := chi.URLParam(r, "name")
-	version := chi.URLParam(r, "version")
-
-	if system == "" || name == "" || version == "" {
-		http.Error(w, "missing path parameters", http.StatusBadRequest)
+	system, name, version, ok := dependencyKey(w, r)
+	if !ok {
 		return
 	}
 
@@ -167,12 +307,28 @@ func (h *Handler) DeleteDependency(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to delete dependency", http.StatusInternalServerError)
 		return
 	}
+	h.evaluatePolicies(r.Context())
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *Handler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
-	err := h.DataManager.RefreshDependencies(r.Context(), config.DefaultSystem, config.DefaultPackage, config.DefaultVersion)
+	var opts data.RefreshOptions
+	if forceStr := r.URL.Query().Get("force"); forceStr != "" {
+		force, err := strconv.ParseBool(forceStr)
+		if err != nil {
+			http.Error(w, "invalid force value", http.StatusBadRequest)
+			return
+		}
+		opts.Force = force
+	}
+
+	root := h.Root
+	if root.Name == "" {
+		root = config.Package{System: config.DefaultSystem, Name: config.DefaultPackage, Version: config.DefaultVersion}
+	}
+
+	err := h.DataManager.RefreshDependencies(r.Context(), root.System, root.Name, root.Version, opts)
 	if err != nil {
 		h.Log.WithError(err).Error("failed to refresh dependencies")
 		http.Error(w, "failed to refresh dependencies", http.StatusInternalServerError)
@@ -181,3 +337,17 @@ func (h *Handler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *Handler) ListFetchFailures(w http.ResponseWriter, r *http.Request) {
+	failures, err := h.Store.ListFetchFailures(r.Context())
+	if err != nil {
+		h.Log.WithError(err).Error("listing fetch failures")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(failures); err != nil {
+		h.Log.WithError(err).Error("encoding fetch failures response")
+	}
+}
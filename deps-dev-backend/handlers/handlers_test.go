@@ -3,13 +3,20 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"deps-dev/config"
+	"deps-dev/data"
+	"deps-dev/policy"
 	"deps-dev/storage"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
@@ -19,14 +26,43 @@ import (
 // Mock Implementations
 type mockStore struct {
 	ListFilteredFn func(ctx context.Context, name string, minScore *float64) ([]storage.Dependency, error)
+	ForEachFn      func(ctx context.Context, name string, minScore *float64, fn func(storage.Dependency) error) error
 	GetFn          func(context.Context, string, string, string) (storage.Dependency, error)
 	UpsertFn       func(context.Context, storage.Dependency) error
 	DeleteFn       func(context.Context, string, string, string) error
+	ListFailuresFn func(context.Context) ([]storage.FetchFailure, error)
+	ViolationsFn   func(ctx context.Context, rule string) ([]storage.PolicyViolation, error)
+	ListWaiversFn  func(context.Context) ([]storage.Waiver, error)
+	GetWaiverFn    func(context.Context, int64) (storage.Waiver, error)
+	CreateWaiverFn func(context.Context, storage.Waiver) (storage.Waiver, error)
+	UpdateWaiverFn func(context.Context, storage.Waiver) (storage.Waiver, error)
+	DeleteWaiverFn func(context.Context, int64) error
+	ImportDataFn   func(context.Context, []storage.DatasetPackage, []storage.ProjectScore) error
+	ListSchedFn    func(context.Context) ([]storage.RefreshSchedule, error)
+	GetSchedFn     func(context.Context, int64) (storage.RefreshSchedule, error)
+	CreateSchedFn  func(context.Context, storage.RefreshSchedule) (storage.RefreshSchedule, error)
+	UpdateSchedFn  func(context.Context, storage.RefreshSchedule) (storage.RefreshSchedule, error)
+	DeleteSchedFn  func(context.Context, int64) error
 }
 
 func (m *mockStore) ListDependenciesFiltered(ctx context.Context, name string, minScore *float64) ([]storage.Dependency, error) {
 	return m.ListFilteredFn(ctx, name, minScore)
 }
+func (m *mockStore) ForEachDependency(ctx context.Context, name string, minScore *float64, fn func(storage.Dependency) error) error {
+	if m.ForEachFn != nil {
+		return m.ForEachFn(ctx, name, minScore, fn)
+	}
+	deps, err := m.ListFilteredFn(ctx, name, minScore)
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		if err := fn(dep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 func (m *mockStore) GetDependency(ctx context.Context, system, name, version string) (storage.Dependency, error) {
 	return m.GetFn(ctx, system, name, version)
 }
@@ -36,13 +72,95 @@ func (m *mockStore) UpsertDependency(ctx context.Context, dep storage.Dependency
 func (m *mockStore) DeleteDependency(ctx context.Context, system, name, version string) error {
 	return m.DeleteFn(ctx, system, name, version)
 }
+func (m *mockStore) ListFetchFailures(ctx context.Context) ([]storage.FetchFailure, error) {
+	return m.ListFailuresFn(ctx)
+}
+func (m *mockStore) ListPolicyViolations(ctx context.Context, rule string) ([]storage.PolicyViolation, error) {
+	return m.ViolationsFn(ctx, rule)
+}
+func (m *mockStore) ListWaivers(ctx context.Context) ([]storage.Waiver, error) {
+	return m.ListWaiversFn(ctx)
+}
+func (m *mockStore) GetWaiver(ctx context.Context, id int64) (storage.Waiver, error) {
+	return m.GetWaiverFn(ctx, id)
+}
+func (m *mockStore) CreateWaiver(ctx context.Context, w storage.Waiver) (storage.Waiver, error) {
+	return m.CreateWaiverFn(ctx, w)
+}
+func (m *mockStore) UpdateWaiver(ctx context.Context, w storage.Waiver) (storage.Waiver, error) {
+	return m.UpdateWaiverFn(ctx, w)
+}
+func (m *mockStore) DeleteWaiver(ctx context.Context, id int64) error {
+	return m.DeleteWaiverFn(ctx, id)
+}
+func (m *mockStore) ImportDataset(ctx context.Context, load func(stage storage.DatasetStager) error) error {
+	return load(func(packages []storage.DatasetPackage, scores []storage.ProjectScore) error {
+		return m.ImportDataFn(ctx, packages, scores)
+	})
+}
+func (m *mockStore) ListRefreshSchedules(ctx context.Context) ([]storage.RefreshSchedule, error) {
+	return m.ListSchedFn(ctx)
+}
+func (m *mockStore) GetRefreshSchedule(ctx context.Context, id int64) (storage.RefreshSchedule, error) {
+	return m.GetSchedFn(ctx, id)
+}
+func (m *mockStore) CreateRefreshSchedule(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error) {
+	return m.CreateSchedFn(ctx, rs)
+}
+func (m *mockStore) UpdateRefreshSchedule(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error) {
+	return m.UpdateSchedFn(ctx, rs)
+}
+func (m *mockStore) DeleteRefreshSchedule(ctx context.Context, id int64) error {
+	return m.DeleteSchedFn(ctx, id)
+}
 
 type mockManager struct {
-	RefreshFn func(context.Context, string, string, string) error
+	RefreshFn func(context.Context, string, string, string, data.RefreshOptions) error
+	ImportFn  func(context.Context, []storage.Dependency) (int, error)
+	ResolveFn func(context.Context, string, string, string) ([]storage.Dependency, error)
+	// ResolveDepsFn resolves deps as they are if unset
+	ResolveDepsFn func(context.Context, []storage.Dependency) ([]storage.Dependency, error)
+}
+
+func (m *mockManager) RefreshDependencies(ctx context.Context, system, name, version string, opts data.RefreshOptions) error {
+	return m.RefreshFn(ctx, system, name, version, opts)
+}
+func (m *mockManager) ImportDependencies(ctx context.Context, deps []storage.Dependency) (int, error) {
+	return m.ImportFn(ctx, deps)
+}
+func (m *mockManager) ResolvePackage(ctx context.Context, system, name, version string) ([]storage.Dependency, error) {
+	return m.ResolveFn(ctx, system, name, version)
+}
+func (m *mockManager) ResolveDependencies(ctx context.Context, deps []storage.Dependency) ([]storage.Dependency, error) {
+	if m.ResolveDepsFn == nil {
+		return deps, nil
+	}
+	return m.ResolveDepsFn(ctx, deps)
+}
+
+type mockPolicies struct {
+	LoadFn     func(context.Context) (policy.Policy, string, error)
+	SaveFn     func(context.Context, []byte) (policy.Policy, error)
+	EvaluateFn func(context.Context) (int, error)
+}
+
+func (m *mockPolicies) Load(ctx context.Context) (policy.Policy, string, error) {
+	return m.LoadFn(ctx)
+}
+func (m *mockPolicies) Save(ctx context.Context, document []byte) (policy.Policy, error) {
+	return m.SaveFn(ctx, document)
+}
+func (m *mockPolicies) Evaluate(ctx context.Context) (int, error) {
+	return m.EvaluateFn(ctx)
+}
+
+type mockScheduler struct {
+	Reloads int
 }
 
-func (m *mockManager) RefreshDependencies(ctx context.Context, system, name, version string) error {
-	return m.RefreshFn(ctx, system, name, version)
+func (m *mockScheduler) Reload(ctx context.Context) error {
+	m.Reloads++
+	return nil
 }
 
 // Tests
@@ -136,6 +254,167 @@ func TestListDependencies(t *testing.T) {
 	}
 }
 
+func TestListDependencies_Formats(t *testing.T) {
+	fetched := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	deps := []storage.Dependency{
+		{System: "NPM", Name: "react", Version: "18.2.0", PURL: "pkg:npm/react@18.2.0", Relation: "DIRECT", SourceRepo: "github.com/facebook/react",
+			OpenSSFScore: float64Ptr(9.1), License: "MIT", MetadataFetchedAt: &fetched, ScoreFetchedAt: &fetched, Depth: 1},
+		{System: "NPM", Name: "left,pad", Version: "1.0.0"},
+	}
+	csvBody := "system,name,version,relation,source_repo,openssf_score,license,metadata_fetched_at,score_fetched_at,purl,depth\n" +
+		"NPM,react,18.2.0,DIRECT,github.com/facebook/react,9.1,MIT,2024-05-01T12:00:00Z,2024-05-01T12:00:00Z,pkg:npm/react@18.2.0,1\n" +
+		"NPM,\"left,pad\",1.0.0,,,,,,,,\n"
+	ndjsonBody := `{"system":"NPM","name":"react","version":"18.2.0","purl":"pkg:npm/react@18.2.0","relation":"DIRECT","source_repo":"github.com/facebook/react","openssf_score":9.1,"license":"MIT","depth":1,"metadata_fetched_at":"2024-05-01T12:00:00Z","score_fetched_at":"2024-05-01T12:00:00Z"}` + "\n" +
+		`{"system":"NPM","name":"left,pad","version":"1.0.0"}` + "\n"
+
+	tests := []struct {
+		name                string
+		url                 string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "csv via format",
+			url:                 "/dependencies?format=csv",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        csvBody,
+		},
+		{
+			name:                "csv via Accept",
+			url:                 "/dependencies",
+			accept:              "text/csv;q=0.9, */*",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        csvBody,
+		},
+		{
+			name:                "ndjson via Accept",
+			url:                 "/dependencies",
+			accept:              "application/x-ndjson",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        ndjsonBody,
+		},
+		{
+			name:                "format overrides Accept",
+			url:                 "/dependencies?format=ndjson",
+			accept:              "text/csv",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        ndjsonBody,
+		},
+		{
+			name:                "json when Accept has no streaming type",
+			url:                 "/dependencies",
+			accept:              "text/html, */*",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+		},
+		{
+			name:                "invalid format",
+			url:                 "/dependencies?format=xml",
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "invalid format value\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Store: &mockStore{
+					ListFilteredFn: func(ctx context.Context, name string, minScore *float64) ([]storage.Dependency, error) {
+						return deps, nil
+					},
+				},
+				Log: logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ListDependencies(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedContentType, rr.Header().Get("Content-Type"))
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestListDependencies_StreamErrors(t *testing.T) {
+	t.Run("empty list still writes the CSV header", func(t *testing.T) {
+		handler := &Handler{
+			Store: &mockStore{
+				ForEachFn: func(ctx context.Context, name string, minScore *float64, fn func(storage.Dependency) error) error {
+					assert.Equal(t, "react", name)
+					return nil
+				},
+			},
+			Log: logrus.New(),
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ListDependencies(rr, httptest.NewRequest(http.MethodGet, "/dependencies?format=csv&name=react", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "system,name,version,relation,source_repo,openssf_score,license,metadata_fetched_at,score_fetched_at,purl,depth\n", rr.Body.String())
+	})
+
+	t.Run("error before the first row", func(t *testing.T) {
+		handler := &Handler{
+			Store: &mockStore{
+				ForEachFn: func(ctx context.Context, name string, minScore *float64, fn func(storage.Dependency) error) error {
+					return errors.New("db error")
+				},
+			},
+			Log: logrus.New(),
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ListDependencies(rr, httptest.NewRequest(http.MethodGet, "/dependencies?format=ndjson", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Equal(t, "internal server error\n", rr.Body.String())
+	})
+
+	t.Run("error mid-stream truncates the output", func(t *testing.T) {
+		handler := &Handler{
+			Store: &mockStore{
+				ForEachFn: func(ctx context.Context, name string, minScore *float64, fn func(storage.Dependency) error) error {
+					assert.NoError(t, fn(storage.Dependency{System: "NPM", Name: "react", Version: "18.2.0"}))
+					return errors.New("db error")
+				},
+			},
+			Log: logrus.New(),
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ListDependencies(rr, httptest.NewRequest(http.MethodGet, "/dependencies?format=ndjson", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `{"system":"NPM","name":"react","version":"18.2.0"}`+"\n", rr.Body.String())
+	})
+
+	t.Run("invalid min_score", func(t *testing.T) {
+		handler := &Handler{Store: &mockStore{}, Log: logrus.New()}
+
+		rr := httptest.NewRecorder()
+		handler.ListDependencies(rr, httptest.NewRequest(http.MethodGet, "/dependencies?format=csv&min_score=x", nil))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "invalid min_score value\n", rr.Body.String())
+	})
+}
+
 func TestGetDependency(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -159,7 +438,15 @@ func TestGetDependency(t *testing.T) {
 				}, nil
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `{"system":"npm","name":"react","version":"18.2.0"}` + "\n",
+			expectedBody:   `{"system":"NPM","name":"react","version":"18.2.0"}` + "\n",
+		},
+		{
+			name:           "unknown system",
+			system:         "bower",
+			packageName:    "jquery",
+			version:        "3.0.0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown system \"bower\", expected one of CARGO, GO, MAVEN, NPM, NUGET, PYPI, RUBYGEMS\n",
 		},
 		{
 			name:           "missing path parameters",
@@ -194,7 +481,7 @@ func TestGetDependency(t *testing.T) {
 			}
 
 			r := chi.NewRouter()
-			r.Get("/dependencies/{system}/{name}/{version}", handler.GetDependency)
+			r.Get(DependencyRoute, handler.GetDependency)
 
 			url := fmt.Sprintf("/dependencies/%s/%s/%s", tt.system, tt.packageName, tt.version)
 			req := httptest.NewRequest(http.MethodGet, url, nil)
@@ -217,6 +504,7 @@ func TestCreateDependency(t *testing.T) {
 		upsertErr      error
 		expectedStatus int
 		expectedBody   string
+		evaluated      bool
 	}{
 		{
 			name:           "invalid JSON body",
@@ -257,6 +545,7 @@ func TestCreateDependency(t *testing.T) {
 			upsertErr:      nil,
 			expectedStatus: http.StatusCreated,
 			expectedBody:   "",
+			evaluated:      true,
 		},
 	}
 
@@ -274,16 +563,244 @@ func TestCreateDependency(t *testing.T) {
 				},
 			}
 
+			evaluated := false
 			handler := &Handler{
 				Store: store,
-				Log:   logrus.New(),
+				Policies: &mockPolicies{EvaluateFn: func(ctx context.Context) (int, error) {
+					evaluated = true
+					return 0, nil
+				}},
+				Log: logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/dependencies", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.CreateDependency(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+			assert.Equal(t, tt.evaluated, evaluated)
+		})
+	}
+}
+
+func TestCreateDependency_CanonicalKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		expectedKey    []string
+	}{
+		{
+			name:           "lower case system",
+			body:           `{ "system": "npm", "name": "react", "version": "18.2.0" }`,
+			expectedStatus: http.StatusCreated,
+			expectedKey:    []string{"NPM", "react", "18.2.0"},
+		},
+		{
+			name:           "pypi name normalized",
+			body:           `{ "system": "pypi", "name": "Django_REST.framework", "version": "3.14.0" }`,
+			expectedStatus: http.StatusCreated,
+			expectedKey:    []string{"PYPI", "django-rest-framework", "3.14.0"},
+		},
+		{
+			name:           "go version without v",
+			body:           `{ "system": "GO", "name": "golang.org/x/mod", "version": "0.12.0" }`,
+			expectedStatus: http.StatusCreated,
+			expectedKey:    []string{"GO", "golang.org/x/mod", "v0.12.0"},
+		},
+		{
+			name:           "maven name without group",
+			body:           `{ "system": "maven", "name": "slf4j-api", "version": "2.0.9" }`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid MAVEN package name \"slf4j-api\", expected groupId:artifactId\n",
+		},
+		{
+			name:           "unknown system",
+			body:           `{ "system": "bower", "name": "jquery", "version": "3.0.0" }`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown system \"bower\", expected one of CARGO, GO, MAVEN, NPM, NUGET, PYPI, RUBYGEMS\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var looked, upserted []string
+			store := &mockStore{
+				GetFn: func(ctx context.Context, system, name, version string) (storage.Dependency, error) {
+					looked = []string{system, name, version}
+					return storage.Dependency{}, errors.New("not found")
+				},
+				UpsertFn: func(ctx context.Context, dep storage.Dependency) error {
+					upserted = []string{dep.System, dep.Name, dep.Version}
+					return nil
+				},
+			}
+			handler := &Handler{Store: store, Log: logrus.New()}
+
+			rr := httptest.NewRecorder()
+			handler.CreateDependency(rr, httptest.NewRequest(http.MethodPost, "/dependencies", bytes.NewBufferString(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+			assert.Equal(t, tt.expectedKey, looked)
+			assert.Equal(t, tt.expectedKey, upserted)
+		})
+	}
+}
+
+func TestCreateDependency_ManualProvenance(t *testing.T) {
+	var upserted storage.Dependency
+	store := &mockStore{
+		GetFn: func(ctx context.Context, system, name, version string) (storage.Dependency, error) {
+			return storage.Dependency{}, errors.New("not found")
+		},
+		UpsertFn: func(ctx context.Context, dep storage.Dependency) error {
+			upserted = dep
+			return nil
+		},
+	}
+	handler := &Handler{Store: store, Log: logrus.New()}
+
+	body := `{"system": "npm", "name": "react", "version": "18.2.0", "source_repo": "github.com/facebook/react",
+		"source_repo_provider": "deps.dev", "license": "MIT", "license_provider": "deps.dev"}`
+	rr := httptest.NewRecorder()
+	handler.CreateDependency(rr, httptest.NewRequest(http.MethodPost, "/dependencies", bytes.NewBufferString(body)))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, storage.ProviderManual, upserted.SourceRepoProvider)
+	assert.Equal(t, storage.ProviderManual, upserted.LicenseProvider)
+	assert.Equal(t, "", upserted.ScoreProvider)
+}
+
+func TestCreateDependency_PURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		expectedKey    []string
+	}{
+		{
+			name:           "purl only",
+			body:           `{ "purl": "pkg:npm/%40babel/core@7.22.0", "relation": "DIRECT" }`,
+			expectedStatus: http.StatusCreated,
+			expectedKey:    []string{"NPM", "@babel/core", "7.22.0"},
+		},
+		{
+			name:           "purl agreeing with the fields",
+			body:           `{ "purl": "pkg:golang/github.com/go-chi/chi/v5@v5.0.10", "system": "go", "name": "github.com/go-chi/chi/v5" }`,
+			expectedStatus: http.StatusCreated,
+			expectedKey:    []string{"GO", "github.com/go-chi/chi/v5", "v5.0.10"},
+		},
+		{
+			name:           "purl agreeing with fields spelled differently",
+			body:           `{ "purl": "pkg:pypi/django-rest@3.14.0", "system": "pypi", "name": "Django_Rest" }`,
+			expectedStatus: http.StatusCreated,
+			expectedKey:    []string{"PYPI", "django-rest", "3.14.0"},
+		},
+		{
+			name:           "purl contradicting the fields",
+			body:           `{ "purl": "pkg:npm/react@18.2.0", "version": "17.0.0" }`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "purl does not match system, name and version\n",
+		},
+		{
+			name:           "purl without version",
+			body:           `{ "purl": "pkg:npm/react" }`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "purl must include a version\n",
+		},
+		{
+			name:           "unsupported purl type",
+			body:           `{ "purl": "pkg:deb/debian/curl@7.50.3-1" }`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "package type deb is not covered by deps.dev\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upserted *storage.Dependency
+			store := &mockStore{
+				GetFn: func(ctx context.Context, system, name, version string) (storage.Dependency, error) {
+					return storage.Dependency{}, errors.New("not found")
+				},
+				UpsertFn: func(ctx context.Context, dep storage.Dependency) error {
+					upserted = &dep
+					return nil
+				},
 			}
+			handler := &Handler{Store: store, Log: logrus.New()}
 
 			req := httptest.NewRequest(http.MethodPost, "/dependencies", bytes.NewBufferString(tt.body))
 			rr := httptest.NewRecorder()
 
 			handler.CreateDependency(rr, req)
 
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+			if tt.expectedKey != nil {
+				if assert.NotNil(t, upserted) {
+					assert.Equal(t, tt.expectedKey, []string{upserted.System, upserted.Name, upserted.Version})
+				}
+			} else {
+				assert.Nil(t, upserted)
+			}
+		})
+	}
+}
+
+func TestGetDependencyByPURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "scoped npm package",
+			url:            "/dependencies/purl?purl=" + url.QueryEscape("pkg:npm/%40babel/core@7.22.0"),
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"system":"NPM","name":"@babel/core","version":"7.22.0","purl":"pkg:npm/%40babel/core@7.22.0"}` + "\n",
+		},
+		{
+			name:           "not stored",
+			url:            "/dependencies/purl?purl=" + url.QueryEscape("pkg:npm/react@1.0.0"),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "dependency not found\n",
+		},
+		{
+			name:           "missing purl",
+			url:            "/dependencies/purl",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "missing purl query parameter\n",
+		},
+		{
+			name:           "invalid purl",
+			url:            "/dependencies/purl?purl=npm/react",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid package URL \"npm/react\": missing pkg: scheme\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStore{
+				GetFn: func(ctx context.Context, system, name, version string) (storage.Dependency, error) {
+					if system == "NPM" && name == "@babel/core" && version == "7.22.0" {
+						return storage.Dependency{System: system, Name: name, Version: version, PURL: "pkg:npm/%40babel/core@7.22.0"}, nil
+					}
+					return storage.Dependency{}, errors.New("not found")
+				},
+			}
+			handler := &Handler{Store: store, Log: logrus.New()}
+
+			rr := httptest.NewRecorder()
+			handler.GetDependencyByPURL(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
 			assert.Equal(t, tt.expectedStatus, rr.Code)
 			assert.Equal(t, tt.expectedBody, rr.Body.String())
 		})
@@ -301,6 +818,7 @@ func TestUpdateDependency(t *testing.T) {
 		upsertFn       func(ctx context.Context, dep storage.Dependency) error
 		expectedStatus int
 		expectedBody   string
+		evaluated      bool
 	}{
 		{
 			name:           "invalid JSON body",
@@ -352,10 +870,13 @@ func TestUpdateDependency(t *testing.T) {
 				assert.Equal(t, "https://github.com/facebook/react", dep.SourceRepo)
 				assert.NotNil(t, dep.OpenSSFScore)
 				assert.Equal(t, 7.5, *dep.OpenSSFScore)
+				assert.Equal(t, storage.ProviderManual, dep.SourceRepoProvider)
+				assert.Equal(t, storage.ProviderManual, dep.ScoreProvider)
 				return nil
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
+			evaluated:      true,
 		},
 	}
 
@@ -365,9 +886,14 @@ func TestUpdateDependency(t *testing.T) {
 				GetFn:    tt.getFn,
 				UpsertFn: tt.upsertFn,
 			}
+			evaluated := false
 			handler := &Handler{
 				Store: store,
-				Log:   logrus.New(),
+				Policies: &mockPolicies{EvaluateFn: func(ctx context.Context) (int, error) {
+					evaluated = true
+					return 0, nil
+				}},
+				Log: logrus.New(),
 			}
 
 			req := httptest.NewRequest(http.MethodPut, "/", bytes.NewBufferString(tt.body))
@@ -375,14 +901,14 @@ func TestUpdateDependency(t *testing.T) {
 
 			routeCtx := chi.NewRouteContext()
 			routeCtx.URLParams.Add("system", tt.system)
-			routeCtx.URLParams.Add("name", tt.packageName)
-			routeCtx.URLParams.Add("version", tt.version)
+			routeCtx.URLParams.Add("*", tt.packageName+"/"+tt.version)
 			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
 
 			handler.UpdateDependency(rr, req)
 
 			assert.Equal(t, tt.expectedStatus, rr.Code)
 			assert.Equal(t, tt.expectedBody, rr.Body.String())
+			assert.Equal(t, tt.evaluated, evaluated)
 		})
 	}
 }
@@ -396,6 +922,7 @@ func TestDeleteDependency(t *testing.T) {
 		deleteFn       func(ctx context.Context, system, name, version string) error
 		expectedStatus int
 		expectedBody   string
+		evaluated      bool
 	}{
 		{
 			name:           "missing path parameters",
@@ -426,6 +953,7 @@ func TestDeleteDependency(t *testing.T) {
 			},
 			expectedStatus: http.StatusNoContent,
 			expectedBody:   "",
+			evaluated:      true,
 		},
 	}
 
@@ -434,9 +962,14 @@ func TestDeleteDependency(t *testing.T) {
 			store := &mockStore{
 				DeleteFn: tt.deleteFn,
 			}
+			evaluated := false
 			handler := &Handler{
 				Store: store,
-				Log:   logrus.New(),
+				Policies: &mockPolicies{EvaluateFn: func(ctx context.Context) (int, error) {
+					evaluated = true
+					return 0, nil
+				}},
+				Log: logrus.New(),
 			}
 
 			req := httptest.NewRequest(http.MethodDelete, "/", nil)
@@ -444,61 +977,167 @@ func TestDeleteDependency(t *testing.T) {
 
 			routeCtx := chi.NewRouteContext()
 			routeCtx.URLParams.Add("system", tt.system)
-			routeCtx.URLParams.Add("name", tt.packageName)
-			routeCtx.URLParams.Add("version", tt.version)
+			routeCtx.URLParams.Add("*", tt.packageName+"/"+tt.version)
 			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
 
 			handler.DeleteDependency(rr, req)
 
 			assert.Equal(t, tt.expectedStatus, rr.Code)
 			assert.Equal(t, tt.expectedBody, rr.Body.String())
+			assert.Equal(t, tt.evaluated, evaluated)
 		})
 	}
 }
 
-func TestRefreshHandler(t *testing.T) {
+func TestDependencyRoute_SlashedNames(t *testing.T) {
 	tests := []struct {
-		name           string
-		refreshFn      func(ctx context.Context, system, name, version string) error
-		expectedStatus int
-		expectedBody   string
+		name    string
+		path    string
+		system  string
+		pkgName string
+		version string
 	}{
-		{
-			name: "refresh fails",
-			refreshFn: func(ctx context.Context, system, name, version string) error {
-				assert.Equal(t, config.DefaultSystem, system)
-				assert.Equal(t, config.DefaultPackage, name)
-				assert.Equal(t, config.DefaultVersion, version)
-				return errors.New("refresh error")
-			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   "failed to refresh dependencies\n",
-		},
-		{
-			name: "refresh succeeds",
-			refreshFn: func(ctx context.Context, system, name, version string) error {
-				assert.Equal(t, config.DefaultSystem, system)
-				assert.Equal(t, config.DefaultPackage, name)
-				assert.Equal(t, config.DefaultVersion, version)
-				return nil
-			},
-			expectedStatus: http.StatusOK,
-			expectedBody:   "",
-		},
+		{"plain name", "/dependencies/NPM/react/18.2.0", "NPM", "react", "18.2.0"},
+		{"scoped npm package", "/dependencies/NPM/@babel/core/7.22.0", "NPM", "@babel/core", "7.22.0"},
+		{"encoded npm scope", "/dependencies/NPM/%40babel%2Fcore/7.22.0", "NPM", "@babel/core", "7.22.0"},
+		{"go module path", "/dependencies/GO/github.com/go-chi/chi/v5/v5.0.10", "GO", "github.com/go-chi/chi/v5", "v5.0.10"},
+		{"encoded go module path", "/dependencies/GO/github.com%2Fgo-chi%2Fchi%2Fv5/v5.0.10", "GO", "github.com/go-chi/chi/v5", "v5.0.10"},
+		{"go version with build metadata", "/dependencies/GO/golang.org/x/mod/v0.12.0+incompatible", "GO", "golang.org/x/mod", "v0.12.0+incompatible"},
+		{"maven artifact", "/dependencies/MAVEN/org.slf4j:slf4j-api/2.0.9", "MAVEN", "org.slf4j:slf4j-api", "2.0.9"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			manager := &mockManager{
-				RefreshFn: tt.refreshFn,
-			}
-
-			handler := &Handler{
-				DataManager: manager,
-				Log:         logrus.New(),
+			var calls []string
+			record := func(op, system, name, version string) {
+				assert.Equal(t, tt.system, system)
+				assert.Equal(t, tt.pkgName, name)
+				assert.Equal(t, tt.version, version)
+				calls = append(calls, op)
 			}
-
-			req := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+			store := &mockStore{
+				GetFn: func(ctx context.Context, system, name, version string) (storage.Dependency, error) {
+					record("get", system, name, version)
+					return storage.Dependency{System: system, Name: name, Version: version}, nil
+				},
+				UpsertFn: func(ctx context.Context, dep storage.Dependency) error {
+					record("upsert", dep.System, dep.Name, dep.Version)
+					return nil
+				},
+				DeleteFn: func(ctx context.Context, system, name, version string) error {
+					record("delete", system, name, version)
+					return nil
+				},
+			}
+			handler := &Handler{Store: store, Log: logrus.New()}
+
+			r := chi.NewRouter()
+			r.Get(DependencyRoute, handler.GetDependency)
+			r.Put(DependencyRoute, handler.UpdateDependency)
+			r.Delete(DependencyRoute, handler.DeleteDependency)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			rr = httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, tt.path, bytes.NewBufferString(`{"relation":"DIRECT"}`)))
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			rr = httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, tt.path, nil))
+			assert.Equal(t, http.StatusNoContent, rr.Code)
+
+			assert.Equal(t, []string{"get", "get", "upsert", "delete"}, calls)
+		})
+	}
+}
+
+func TestDependencyRoute_MissingVersion(t *testing.T) {
+	handler := &Handler{Store: &mockStore{}, Log: logrus.New()}
+
+	r := chi.NewRouter()
+	r.Get(DependencyRoute, handler.GetDependency)
+	r.Delete(DependencyRoute, handler.DeleteDependency)
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		for _, path := range []string{"/dependencies/NPM/react", "/dependencies/NPM/react/"} {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, method+" "+path)
+			assert.Equal(t, "missing path parameters\n", rr.Body.String(), method+" "+path)
+		}
+	}
+}
+
+func TestRefreshHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		refreshFn      func(ctx context.Context, system, name, version string, opts data.RefreshOptions) error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "refresh fails",
+			url:  "/refresh",
+			refreshFn: func(ctx context.Context, system, name, version string, opts data.RefreshOptions) error {
+				assert.Equal(t, config.DefaultSystem, system)
+				assert.Equal(t, config.DefaultPackage, name)
+				assert.Equal(t, config.DefaultVersion, version)
+				return errors.New("refresh error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "failed to refresh dependencies\n",
+		},
+		{
+			name: "refresh succeeds",
+			url:  "/refresh",
+			refreshFn: func(ctx context.Context, system, name, version string, opts data.RefreshOptions) error {
+				assert.Equal(t, config.DefaultSystem, system)
+				assert.Equal(t, config.DefaultPackage, name)
+				assert.Equal(t, config.DefaultVersion, version)
+				assert.False(t, opts.Force)
+				return nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name: "forced refresh",
+			url:  "/refresh?force=true",
+			refreshFn: func(ctx context.Context, system, name, version string, opts data.RefreshOptions) error {
+				assert.True(t, opts.Force)
+				return nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name: "invalid force value",
+			url:  "/refresh?force=always",
+			refreshFn: func(ctx context.Context, system, name, version string, opts data.RefreshOptions) error {
+				t.Fatal("should not refresh on invalid input")
+				return nil
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid force value\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := &mockManager{
+				RefreshFn: tt.refreshFn,
+			}
+
+			handler := &Handler{
+				DataManager: manager,
+				Log:         logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, tt.url, nil)
 			rr := httptest.NewRecorder()
 
 			handler.RefreshHandler(rr, req)
@@ -509,6 +1148,1383 @@ func TestRefreshHandler(t *testing.T) {
 	}
 }
 
-func float64Ptr(f float64) *float64 {
-	return &f
+func TestListFetchFailures(t *testing.T) {
+	nextAttempt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		listFn         func(ctx context.Context) ([]storage.FetchFailure, error)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "lists outstanding failures",
+			listFn: func(ctx context.Context) ([]storage.FetchFailure, error) {
+				return []storage.FetchFailure{
+					{
+						ID:            1,
+						System:        "npm",
+						Name:          "loose-envify",
+						Version:       "1.4.0",
+						Stage:         storage.FailureStageMetadata,
+						Status:        storage.FailureStatusPending,
+						Attempts:      2,
+						LastError:     "timeout",
+						NextAttemptAt: nextAttempt,
+						CreatedAt:     nextAttempt,
+						UpdatedAt:     nextAttempt,
+					},
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `[{"id":1,"system":"npm","name":"loose-envify","version":"1.4.0","stage":"METADATA","status":"PENDING",` +
+				`"attempts":2,"last_error":"timeout","next_attempt_at":"2025-07-01T12:00:00Z",` +
+				`"created_at":"2025-07-01T12:00:00Z","updated_at":"2025-07-01T12:00:00Z"}]` + "\n",
+		},
+		{
+			name: "store error",
+			listFn: func(ctx context.Context) ([]storage.FetchFailure, error) {
+				return nil, errors.New("db error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "internal server error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Store: &mockStore{ListFailuresFn: tt.listFn},
+				Log:   logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/refresh/failures", nil)
+			rr := httptest.NewRecorder()
+
+			handler.ListFetchFailures(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestImportNpmLockfile(t *testing.T) {
+	lockfile := `{
+		"lockfileVersion": 3,
+		"packages": {
+			"": {"name": "app", "dependencies": {"react": "^18.2.0"}},
+			"node_modules/react": {"version": "18.2.0"},
+			"node_modules/loose-envify": {"version": "1.4.0"},
+			"node_modules/private": {"version": "1.0.0", "resolved": "file:../private"}
+		}
+	}`
+
+	tests := []struct {
+		name           string
+		body           string
+		importFn       func(ctx context.Context, deps []storage.Dependency) (int, error)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "imports parsed dependencies",
+			body: lockfile,
+			importFn: func(ctx context.Context, deps []storage.Dependency) (int, error) {
+				assert.Equal(t, []storage.Dependency{
+					{System: "NPM", Name: "loose-envify", Version: "1.4.0", Relation: "INDIRECT"},
+					{System: "NPM", Name: "react", Version: "18.2.0", Relation: "DIRECT", Depth: 1},
+				}, deps)
+				return 2, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"parsed":2,"imported":2,"issues":[` +
+				`{"entry":"node_modules/private","reason":"not installed from a registry: file:../private"}]}` + "\n",
+		},
+		{
+			name:           "invalid lockfile",
+			body:           `{"lockfileVersion": 1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid package-lock.json: unsupported lockfileVersion 1, regenerate it with npm 7 or newer\n",
+		},
+		{
+			name: "import fails",
+			body: lockfile,
+			importFn: func(ctx context.Context, deps []storage.Dependency) (int, error) {
+				return 0, errors.New("db error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "failed to import dependencies\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				DataManager: &mockManager{ImportFn: tt.importFn},
+				Log:         logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/imports/npm-lockfile", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.ImportNpmLockfile(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestImportGoModules(t *testing.T) {
+	gomod := "module example.com/app\n\nrequire github.com/pkg/errors v0.9.1\n"
+	gosum := "github.com/pkg/errors v0.9.1 h1:FEBLx1zS214owpjy7qsBeixbURkuhQAwrK5UwLGTwt4=\n" +
+		"golang.org/x/sys v0.1.0 h1:kunALQeHf1/185U1i0GOB/fy1IPRDDpuoOOqRReG57c=\n"
+
+	multipartBody := func(files map[string]string) (*bytes.Buffer, string) {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		for field, content := range files {
+			fw, err := mw.CreateFormFile(field, field)
+			assert.NoError(t, err)
+			fw.Write([]byte(content))
+		}
+		mw.Close()
+		return body, mw.FormDataContentType()
+	}
+
+	tests := []struct {
+		name           string
+		files          map[string]string
+		raw            string
+		expectedDeps   []storage.Dependency
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "raw go.mod",
+			raw:  gomod,
+			expectedDeps: []storage.Dependency{
+				{System: "GO", Name: "github.com/pkg/errors", Version: "v0.9.1", Relation: "DIRECT", Depth: 1},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"parsed":1,"imported":1,"issues":[]}` + "\n",
+		},
+		{
+			name:  "multipart with go.sum",
+			files: map[string]string{"go.mod": gomod, "go.sum": gosum},
+			expectedDeps: []storage.Dependency{
+				{System: "GO", Name: "github.com/pkg/errors", Version: "v0.9.1", Relation: "DIRECT", Depth: 1},
+				{System: "GO", Name: "golang.org/x/sys", Version: "v0.1.0", Relation: "INDIRECT"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"parsed":2,"imported":2,"issues":[]}` + "\n",
+		},
+		{
+			name:           "multipart without go.mod",
+			files:          map[string]string{"go.sum": gosum},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid go.mod: missing go.mod file\n",
+		},
+		{
+			name:           "invalid go.mod",
+			raw:            "go 1.21\n",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid go.mod: go.mod has no module directive\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				DataManager: &mockManager{ImportFn: func(ctx context.Context, deps []storage.Dependency) (int, error) {
+					assert.Equal(t, tt.expectedDeps, deps)
+					return len(deps), nil
+				}},
+				Log: logrus.New(),
+			}
+
+			var req *http.Request
+			if tt.files != nil {
+				body, contentType := multipartBody(tt.files)
+				req = httptest.NewRequest(http.MethodPost, "/imports/go-mod", body)
+				req.Header.Set("Content-Type", contentType)
+			} else {
+				req = httptest.NewRequest(http.MethodPost, "/imports/go-mod", bytes.NewBufferString(tt.raw))
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ImportGoModules(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestImportPythonFiles(t *testing.T) {
+	tests := []struct {
+		name           string
+		handle         func(h *Handler) http.HandlerFunc
+		body           string
+		expectedDeps   []storage.Dependency
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "requirements.txt with unpinned line",
+			handle: func(h *Handler) http.HandlerFunc { return h.ImportRequirements },
+			body:   "Flask==2.3.2\nrequests>=2\n",
+			expectedDeps: []storage.Dependency{
+				{System: "PYPI", Name: "flask", Version: "2.3.2", Relation: "DIRECT", Depth: 1},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"parsed":1,"imported":1,"issues":[` +
+				`{"line":2,"entry":"requests\u003e=2","reason":"not pinned to an exact version"}]}` + "\n",
+		},
+		{
+			name:   "poetry.lock",
+			handle: func(h *Handler) http.HandlerFunc { return h.ImportPoetryLock },
+			body:   "[[package]]\nname = \"Flask\"\nversion = \"2.3.2\"\n\n[metadata]\nlock-version = \"2.0\"\n",
+			expectedDeps: []storage.Dependency{
+				{System: "PYPI", Name: "flask", Version: "2.3.2", Relation: "DIRECT", Depth: 1},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"parsed":1,"imported":1,"issues":[]}` + "\n",
+		},
+		{
+			name:           "invalid uv.lock",
+			handle:         func(h *Handler) http.HandlerFunc { return h.ImportUvLock },
+			body:           "[[package]]\n",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid uv.lock: missing lockfile version, not a uv.lock\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				DataManager: &mockManager{ImportFn: func(ctx context.Context, deps []storage.Dependency) (int, error) {
+					assert.Equal(t, tt.expectedDeps, deps)
+					return len(deps), nil
+				}},
+				Log: logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/imports", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			tt.handle(handler)(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestImportMavenTree(t *testing.T) {
+	tree := "com.example:app:jar:1.0.0\n\\- junit:junit:jar:4.13.2:test\n   \\- org.hamcrest:hamcrest-core:jar:1.3:test\n"
+
+	handler := &Handler{
+		DataManager: &mockManager{ImportFn: func(ctx context.Context, deps []storage.Dependency) (int, error) {
+			return len(deps), nil
+		}},
+		Log: logrus.New(),
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/imports/maven-tree", bytes.NewBufferString(tree))
+	rr := httptest.NewRecorder()
+
+	handler.ImportMavenTree(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"parsed":2,"imported":2,"issues":[],"edges":[`+
+		`{"to":"MAVEN|junit:junit|4.13.2"},`+
+		`{"from":"MAVEN|junit:junit|4.13.2","to":"MAVEN|org.hamcrest:hamcrest-core|1.3"}]}`+"\n", rr.Body.String())
+}
+
+func TestImportDataset(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		body           string
+		importErr      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "CSV",
+			query:          "?format=csv",
+			body:           "system,name,source_repo,openssf_score\nnpm,react,github.com/facebook/react,6.8\nnpm,left-pad,,\n",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"packages":1,"scores":1,"skipped":1,"issues":[` +
+				`{"line":3,"entry":"npm/left-pad","reason":"no source_repo, license or openssf_score"}]}` + "\n",
+		},
+		{
+			name:           "Scorecard results",
+			query:          "?format=scorecard",
+			body:           `[{"repo": {"name": "github.com/facebook/react"}, "score": 6.8}]`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"packages":0,"scores":1,"skipped":0,"issues":[]}` + "\n",
+		},
+		{
+			name:           "missing format",
+			body:           "system,name\n",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "format must be csv, json or scorecard\n",
+		},
+		{
+			name:           "invalid dataset",
+			query:          "?format=csv",
+			body:           "package,version\n",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid dataset: missing system column, expected a header with system, name, version, source_repo, license, openssf_score\n",
+		},
+		{
+			name:           "storage error",
+			query:          "?format=json",
+			body:           `{"system": "npm", "name": "react", "license": "MIT"}`,
+			importErr:      errors.New("disk full"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "failed to import dataset\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStore{
+				ImportDataFn: func(ctx context.Context, packages []storage.DatasetPackage, scores []storage.ProjectScore) error {
+					return tt.importErr
+				},
+			}
+			handler := &Handler{Store: store, Log: logrus.New()}
+
+			rr := httptest.NewRecorder()
+			handler.ImportDataset(rr, httptest.NewRequest(http.MethodPost, "/imports/dataset"+tt.query, bytes.NewBufferString(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestImportSBOM(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "CycloneDX",
+			body: `{"bomFormat": "CycloneDX", "components": [
+				{"name": "express", "version": "4.18.2", "purl": "pkg:npm/express@4.18.2"},
+				{"name": "curl", "version": "7.88.1", "purl": "pkg:deb/debian/curl@7.88.1"}
+			]}`,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"parsed":1,"imported":1,"issues":[` +
+				`{"entry":"pkg:deb/debian/curl@7.88.1","reason":"package type deb is not covered by deps.dev"}]}` + "\n",
+		},
+		{
+			name:           "not an SBOM",
+			body:           `{"lockfileVersion": 3}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid SBOM: unknown SBOM format, expected CycloneDX or SPDX JSON\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				DataManager: &mockManager{ImportFn: func(ctx context.Context, deps []storage.Dependency) (int, error) {
+					assert.Equal(t, []storage.Dependency{
+						{System: "NPM", Name: "express", Version: "4.18.2", Relation: "DIRECT", Depth: 1},
+					}, deps)
+					return len(deps), nil
+				}},
+				Log: logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/imports/sbom", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.ImportSBOM(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestExportCycloneDX(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		listFn         func(ctx context.Context, name string, minScore *float64) ([]storage.Dependency, error)
+		expectedStatus int
+	}{
+		{
+			name:  "filters and exports",
+			query: "?name=react&min_score=5",
+			listFn: func(ctx context.Context, name string, minScore *float64) ([]storage.Dependency, error) {
+				assert.Equal(t, "react", name)
+				assert.Equal(t, 5.0, *minScore)
+				return []storage.Dependency{
+					{System: "NPM", Name: "react", Version: "18.2.0", Relation: "DIRECT", OpenSSFScore: float64Ptr(7.5)},
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid min_score",
+			query:          "?min_score=high",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "store error",
+			listFn: func(ctx context.Context, name string, minScore *float64) ([]storage.Dependency, error) {
+				return nil, errors.New("db error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Store: &mockStore{ListFilteredFn: tt.listFn},
+				Log:   logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/export/cyclonedx"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			handler.ExportCycloneDX(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "application/vnd.cyclonedx+json; version=1.5", rr.Header().Get("Content-Type"))
+			assert.Contains(t, rr.Body.String(), `"bomFormat":"CycloneDX"`)
+			assert.Contains(t, rr.Body.String(), `"purl":"pkg:npm/react@18.2.0"`)
+			assert.Contains(t, rr.Body.String(), `{"name":"openssf:scorecard:score","value":"7.5"}`)
+		})
+	}
+}
+
+func TestExportSPDX(t *testing.T) {
+	tests := []struct {
+		name                string
+		query               string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "JSON by default",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/spdx+json",
+			expectedBody:        `"spdxVersion":"SPDX-2.3"`,
+		},
+		{
+			name:                "tag-value",
+			query:               "?format=tag-value",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/spdx; charset=utf-8",
+			expectedBody:        "PackageLicenseDeclared: MIT\n",
+		},
+		{
+			name:           "unknown format",
+			query:          "?format=xml",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid format value\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Store: &mockStore{ListFilteredFn: func(ctx context.Context, name string, minScore *float64) ([]storage.Dependency, error) {
+					return []storage.Dependency{
+						{System: "NPM", Name: "react", Version: "18.2.0", Relation: "SELF", License: "MIT"},
+					}, nil
+				}},
+				Log: logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/export/spdx"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			handler.ExportSPDX(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.expectedBody)
+			if tt.expectedContentType != "" {
+				assert.Equal(t, tt.expectedContentType, rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func TestListViolations(t *testing.T) {
+	detected := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		url            string
+		listFn         func(ctx context.Context, rule string) ([]storage.PolicyViolation, error)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "all violations",
+			url:  "/violations",
+			listFn: func(ctx context.Context, rule string) ([]storage.PolicyViolation, error) {
+				assert.Equal(t, "", rule)
+				return []storage.PolicyViolation{
+					{
+						Rule:       "direct-min-score",
+						Dependency: storage.Dependency{System: "NPM", Name: "loose-envify", Version: "1.4.0", PURL: "pkg:npm/loose-envify@1.4.0"},
+						Reason:     "OpenSSF score 3.1 is below 5",
+						DetectedAt: detected,
+					},
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `[{"rule":"direct-min-score","dependency":{"system":"NPM","name":"loose-envify","version":"1.4.0",` +
+				`"purl":"pkg:npm/loose-envify@1.4.0"},"reason":"OpenSSF score 3.1 is below 5","detected_at":"2025-07-01T12:00:00Z"}]` + "\n",
+		},
+		{
+			name: "filter by rule",
+			url:  "/violations?rule=denied",
+			listFn: func(ctx context.Context, rule string) ([]storage.PolicyViolation, error) {
+				assert.Equal(t, "denied", rule)
+				return []storage.PolicyViolation{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "[]\n",
+		},
+		{
+			name: "store error",
+			url:  "/violations",
+			listFn: func(ctx context.Context, rule string) ([]storage.PolicyViolation, error) {
+				return nil, errors.New("db error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "internal server error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Store: &mockStore{
+					ViolationsFn: tt.listFn,
+					ListWaiversFn: func(ctx context.Context) ([]storage.Waiver, error) {
+						return nil, nil
+					},
+				},
+				Log: logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			handler.ListViolations(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestGetPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		loadFn         func(context.Context) (policy.Policy, string, error)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "policy from file",
+			loadFn: func(ctx context.Context) (policy.Policy, string, error) {
+				return policy.Policy{Rules: []policy.Rule{{Name: "repo", Type: policy.RuleRequireSourceRepo}}}, policy.SourceFile, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"source":"file","rules":[{"name":"repo","type":"require_source_repo"}]}` + "\n",
+		},
+		{
+			name: "no policy",
+			loadFn: func(ctx context.Context) (policy.Policy, string, error) {
+				return policy.Policy{}, policy.SourceDatabase, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"source":"database","rules":[]}` + "\n",
+		},
+		{
+			name: "invalid policy file",
+			loadFn: func(ctx context.Context) (policy.Policy, string, error) {
+				return policy.Policy{}, policy.SourceFile, errors.New("failed to decode policy")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "internal server error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Policies: &mockPolicies{LoadFn: tt.loadFn},
+				Log:      logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/policy", nil)
+			rr := httptest.NewRecorder()
+
+			handler.GetPolicy(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestUpdatePolicy(t *testing.T) {
+	const document = "rules:\n  - {name: repo, type: require_source_repo}\n"
+
+	tests := []struct {
+		name           string
+		saveFn         func(context.Context, []byte) (policy.Policy, error)
+		evaluateFn     func(context.Context) (int, error)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "saves and evaluates",
+			saveFn: func(ctx context.Context, doc []byte) (policy.Policy, error) {
+				assert.Equal(t, document, string(doc))
+				return policy.Parse(doc)
+			},
+			evaluateFn: func(ctx context.Context) (int, error) {
+				return 3, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"source":"database","rules":[{"name":"repo","type":"require_source_repo"}]}` + "\n",
+		},
+		{
+			name: "invalid policy",
+			saveFn: func(ctx context.Context, doc []byte) (policy.Policy, error) {
+				return policy.Policy{}, fmt.Errorf("%w: rule repo: unknown type", policy.ErrInvalid)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid policy: rule repo: unknown type\n",
+		},
+		{
+			name: "policy file in use",
+			saveFn: func(ctx context.Context, doc []byte) (policy.Policy, error) {
+				return policy.Policy{}, policy.ErrReadOnly
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "policy is loaded from POLICY_FILE and cannot be changed\n",
+		},
+		{
+			name: "store error",
+			saveFn: func(ctx context.Context, doc []byte) (policy.Policy, error) {
+				return policy.Policy{}, errors.New("db error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "failed to save policy\n",
+		},
+		{
+			name: "evaluation error",
+			saveFn: func(ctx context.Context, doc []byte) (policy.Policy, error) {
+				return policy.Parse(doc)
+			},
+			evaluateFn: func(ctx context.Context) (int, error) {
+				return 0, errors.New("db error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "failed to evaluate policy\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Policies: &mockPolicies{SaveFn: tt.saveFn, EvaluateFn: tt.evaluateFn},
+				Log:      logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/policy", bytes.NewBufferString(document))
+			rr := httptest.NewRecorder()
+
+			handler.UpdatePolicy(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestListViolations_Waivers(t *testing.T) {
+	violations := func(ctx context.Context, rule string) ([]storage.PolicyViolation, error) {
+		return []storage.PolicyViolation{
+			{Rule: "min-score", Dependency: storage.Dependency{System: "NPM", Name: "left-pad", Version: "1.3.0"}, Reason: "no OpenSSF score"},
+			{Rule: "min-score", Dependency: storage.Dependency{System: "NPM", Name: "is-odd", Version: "3.0.1"}, Reason: "no OpenSSF score"},
+			{Rule: "min-score", Dependency: storage.Dependency{System: "NPM", Name: "event-stream", Version: "3.3.6"}, Reason: "no OpenSSF score"},
+		}, nil
+	}
+	waivers := func(ctx context.Context) ([]storage.Waiver, error) {
+		return []storage.Waiver{
+			{ID: 7, Rule: "min-score", System: "NPM", Name: "left-pad", Version: "1.3.0", ExpiresAt: time.Now().Add(time.Hour)},
+			// Lapsed, so the violation resurfaces
+			{ID: 8, Rule: "min-score", System: "NPM", Name: "event-stream", ExpiresAt: time.Now().Add(-time.Hour)},
+		}, nil
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedNames  []string
+	}{
+		{"waived violations hidden", "/violations", http.StatusOK, []string{"is-odd", "event-stream"}},
+		{"waived violations included", "/violations?include_waived=true", http.StatusOK, []string{"left-pad", "is-odd", "event-stream"}},
+		{"invalid include_waived", "/violations?include_waived=maybe", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Store: &mockStore{ViolationsFn: violations, ListWaiversFn: waivers},
+				Log:   logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			handler.ListViolations(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var got []storage.PolicyViolation
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+			var names []string
+			for _, v := range got {
+				names = append(names, v.Dependency.Name)
+				if v.Dependency.Name == "left-pad" {
+					assert.Equal(t, int64(7), *v.WaiverID)
+				} else {
+					assert.Nil(t, v.WaiverID)
+				}
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func TestListWaivers(t *testing.T) {
+	active := storage.Waiver{ID: 1, Rule: "min-score", System: "NPM", Name: "left-pad", ExpiresAt: time.Now().Add(time.Hour)}
+	expired := storage.Waiver{ID: 2, Rule: "min-score", System: "NPM", Name: "is-odd", ExpiresAt: time.Now().Add(-time.Hour)}
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedIDs    map[int64]bool
+	}{
+		{"all", "/waivers", http.StatusOK, map[int64]bool{1: false, 2: true}},
+		{"active", "/waivers?status=active", http.StatusOK, map[int64]bool{1: false}},
+		{"expired", "/waivers?status=expired", http.StatusOK, map[int64]bool{2: true}},
+		{"invalid status", "/waivers?status=lapsed", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Store: &mockStore{ListWaiversFn: func(ctx context.Context) ([]storage.Waiver, error) {
+					return []storage.Waiver{active, expired}, nil
+				}},
+				Log: logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			handler.ListWaivers(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var got []WaiverResponse
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+			ids := make(map[int64]bool)
+			for _, w := range got {
+				ids[w.ID] = w.Expired
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+}
+
+// waiverPolicies serves a policy defining the rules waivers are created for.
+func waiverPolicies() *mockPolicies {
+	return &mockPolicies{LoadFn: func(ctx context.Context) (policy.Policy, string, error) {
+		return policy.Policy{Rules: []policy.Rule{{Name: "min-score"}, {Name: "denied"}}}, "database", nil
+	}}
+}
+
+func TestCreateWaiver(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		policies       *mockPolicies
+		expectedWaiver storage.Waiver
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "date expiry lasts through the day",
+			body: `{"rule": "min-score", "system": "npm", "name": "left-pad", "version": "1.3.0", "reason": "replacement scheduled",
+				"approved_by": "security-team", "ticket": "SEC-42", "expires_at": "2099-01-31"}`,
+			expectedWaiver: storage.Waiver{Rule: "min-score", System: "NPM", Name: "left-pad", Version: "1.3.0", Reason: "replacement scheduled",
+				ApprovedBy: "security-team", Ticket: "SEC-42", ExpiresAt: time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC)},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "canonical key and RFC 3339 expiry",
+			body: `{"rule": "denied", "system": "pypi", "name": "Request_s", "reason": "fork", "approved_by": "x",
+				"expires_at": "2099-01-31T12:00:00Z"}`,
+			expectedWaiver: storage.Waiver{Rule: "denied", System: "PYPI", Name: "request-s", Reason: "fork", ApprovedBy: "x",
+				ExpiresAt: time.Date(2099, 1, 31, 12, 0, 0, 0, time.UTC)},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "invalid JSON",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid JSON body\n",
+		},
+		{
+			name:           "missing approver",
+			body:           `{"rule": "min-score", "system": "npm", "name": "left-pad", "reason": "r", "expires_at": "2099-01-31"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "rule, system, name, reason, approved_by and expires_at are required\n",
+		},
+		{
+			name:           "rule not in the policy",
+			body:           `{"rule": "min-scroe", "system": "npm", "name": "left-pad", "reason": "r", "approved_by": "x", "expires_at": "2099-01-31"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown rule \"min-scroe\", expected one of denied, min-score\n",
+		},
+		{
+			name: "policy without rules",
+			body: `{"rule": "min-score", "system": "npm", "name": "left-pad", "reason": "r", "approved_by": "x", "expires_at": "2099-01-31"}`,
+			policies: &mockPolicies{LoadFn: func(ctx context.Context) (policy.Policy, string, error) {
+				return policy.Policy{}, "database", nil
+			}},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown rule \"min-score\", the policy has no rules\n",
+		},
+		{
+			name: "policy load error",
+			body: `{"rule": "min-score", "system": "npm", "name": "left-pad", "reason": "r", "approved_by": "x", "expires_at": "2099-01-31"}`,
+			policies: &mockPolicies{LoadFn: func(ctx context.Context) (policy.Policy, string, error) {
+				return policy.Policy{}, "", errors.New("db error")
+			}},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "internal server error\n",
+		},
+		{
+			name:           "unknown system",
+			body:           `{"rule": "min-score", "system": "bower", "name": "jquery", "reason": "r", "approved_by": "x", "expires_at": "2099-01-31"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown system \"bower\", expected one of CARGO, GO, MAVEN, NPM, NUGET, PYPI, RUBYGEMS\n",
+		},
+		{
+			name:           "invalid expiry",
+			body:           `{"rule": "min-score", "system": "npm", "name": "left-pad", "reason": "r", "approved_by": "x", "expires_at": "next year"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid expires_at value, expected an RFC 3339 time or YYYY-MM-DD date\n",
+		},
+		{
+			name:           "expiry in the past",
+			body:           `{"rule": "min-score", "system": "npm", "name": "left-pad", "reason": "r", "approved_by": "x", "expires_at": "2020-01-31"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "expires_at must be in the future\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := tt.policies
+			if policies == nil {
+				policies = waiverPolicies()
+			}
+			handler := &Handler{
+				Store: &mockStore{CreateWaiverFn: func(ctx context.Context, w storage.Waiver) (storage.Waiver, error) {
+					assert.True(t, tt.expectedWaiver.ExpiresAt.Equal(w.ExpiresAt))
+					w.ExpiresAt = tt.expectedWaiver.ExpiresAt
+					assert.Equal(t, tt.expectedWaiver, w)
+					w.ID = 1
+					return w, nil
+				}},
+				Policies: policies,
+				Log:      logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/waivers", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.CreateWaiver(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			} else {
+				var got WaiverResponse
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+				assert.Equal(t, int64(1), got.ID)
+				assert.False(t, got.Expired)
+			}
+		})
+	}
+}
+
+func TestWaiverByID(t *testing.T) {
+	body := `{"rule": "min-score", "system": "npm", "name": "left-pad", "reason": "r", "approved_by": "x", "expires_at": "2099-01-31"}`
+	unknownRule := `{"rule": "max-depth", "system": "npm", "name": "left-pad", "reason": "r", "approved_by": "x", "expires_at": "2099-01-31"}`
+	found := storage.Waiver{ID: 3, Rule: "min-score", System: "NPM", Name: "left-pad", ExpiresAt: time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC)}
+
+	store := &mockStore{
+		GetWaiverFn: func(ctx context.Context, id int64) (storage.Waiver, error) {
+			if id != 3 {
+				return storage.Waiver{}, sql.ErrNoRows
+			}
+			return found, nil
+		},
+		UpdateWaiverFn: func(ctx context.Context, w storage.Waiver) (storage.Waiver, error) {
+			if w.ID != 3 {
+				return storage.Waiver{}, sql.ErrNoRows
+			}
+			return w, nil
+		},
+		DeleteWaiverFn: func(ctx context.Context, id int64) error {
+			if id == 4 {
+				return errors.New("db error")
+			}
+			if id != 3 {
+				return sql.ErrNoRows
+			}
+			return nil
+		},
+	}
+	handler := &Handler{Store: store, Policies: waiverPolicies(), Log: logrus.New()}
+
+	tests := []struct {
+		name           string
+		method         string
+		id             string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"get", http.MethodGet, "3", "", http.StatusOK, ""},
+		{"get missing", http.MethodGet, "9", "", http.StatusNotFound, "waiver not found\n"},
+		{"get invalid id", http.MethodGet, "abc", "", http.StatusBadRequest, "invalid waiver id\n"},
+		{"update", http.MethodPut, "3", body, http.StatusOK, ""},
+		{"update missing", http.MethodPut, "9", body, http.StatusNotFound, "waiver not found\n"},
+		{"update unknown rule", http.MethodPut, "3", unknownRule, http.StatusBadRequest, "unknown rule \"max-depth\", expected one of denied, min-score\n"},
+		{"delete", http.MethodDelete, "3", "", http.StatusNoContent, ""},
+		{"delete missing", http.MethodDelete, "9", "", http.StatusNotFound, "waiver not found\n"},
+		{"delete error", http.MethodDelete, "4", "", http.StatusInternalServerError, "failed to delete waiver\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/waivers/"+tt.id, bytes.NewBufferString(tt.body))
+			routeCtx := chi.NewRouteContext()
+			routeCtx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+			rr := httptest.NewRecorder()
+
+			switch tt.method {
+			case http.MethodGet:
+				handler.GetWaiver(rr, req)
+			case http.MethodPut:
+				handler.UpdateWaiver(rr, req)
+			case http.MethodDelete:
+				handler.DeleteWaiver(rr, req)
+			}
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK {
+				var got WaiverResponse
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+				assert.Equal(t, int64(3), got.ID)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	const testPolicy = "rules:\n" +
+		"  - {name: direct-min-score, type: min_score, relation: DIRECT, min_score: 5}\n" +
+		"  - {name: denied, type: deny, packages: [{system: NPM, name: event-stream}]}\n"
+	p, err := policy.Parse([]byte(testPolicy))
+	assert.NoError(t, err)
+
+	resolved := func(ctx context.Context, system, name, version string) ([]storage.Dependency, error) {
+		assert.Equal(t, []string{"NPM", "left-pad", "1.3.0"}, []string{system, name, version})
+		return []storage.Dependency{
+			{System: "NPM", Name: "left-pad", Version: "1.3.0", Relation: "DIRECT", OpenSSFScore: float64Ptr(3.5), Depth: 1},
+			{System: "NPM", Name: "event-stream", Version: "3.3.6", Relation: "INDIRECT", Depth: 2},
+		}, nil
+	}
+	waiver := storage.Waiver{ID: 5, Rule: "denied", System: "NPM", Name: "event-stream", ExpiresAt: time.Now().Add(time.Hour)}
+
+	tests := []struct {
+		name            string
+		url             string
+		body            string
+		waivers         []storage.Waiver
+		resolveFn       func(context.Context, string, string, string) ([]storage.Dependency, error)
+		expectedStatus  int
+		expectedVerdict string
+		expectedRules   []string
+		expectedWaived  int
+		expectedBody    string
+		expectedChecked int
+	}{
+		{
+			name:            "failing package",
+			url:             "/check",
+			body:            `{"system": "npm", "name": "left-pad", "version": "1.3.0"}`,
+			resolveFn:       resolved,
+			expectedStatus:  http.StatusOK,
+			expectedVerdict: VerdictFail,
+			expectedRules:   []string{"direct-min-score", "denied"},
+			expectedChecked: 2,
+		},
+		{
+			name:            "waived violations by purl",
+			url:             "/check",
+			body:            `{"purl": "pkg:npm/left-pad@1.3.0"}`,
+			waivers:         []storage.Waiver{waiver, {ID: 6, Rule: "direct-min-score", System: "NPM", Name: "left-pad", ExpiresAt: time.Now().Add(time.Hour)}},
+			resolveFn:       resolved,
+			expectedStatus:  http.StatusOK,
+			expectedVerdict: VerdictPass,
+			expectedWaived:  2,
+			expectedChecked: 2,
+		},
+		{
+			name: "unscored direct dependency in a manifest",
+			url:  "/check?manifest=npm-lockfile",
+			body: `{"lockfileVersion": 3, "packages": {"": {"dependencies": {"react": "^18.2.0"}},
+				"node_modules/react": {"version": "18.2.0"}}}`,
+			expectedStatus:  http.StatusOK,
+			expectedVerdict: VerdictFail,
+			expectedRules:   []string{"direct-min-score"},
+			expectedChecked: 1,
+		},
+		{
+			name:           "unknown manifest",
+			url:            "/check?manifest=gemfile-lock",
+			body:           "",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown manifest type\n",
+		},
+		{
+			name:           "missing version",
+			url:            "/check",
+			body:           `{"system": "npm", "name": "left-pad"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "system, name, and version are required\n",
+		},
+		{
+			name: "resolve error",
+			url:  "/check",
+			body: `{"system": "npm", "name": "left-pad", "version": "1.3.0"}`,
+			resolveFn: func(ctx context.Context, system, name, version string) ([]storage.Dependency, error) {
+				return nil, errors.New("dependency graph request failed: 404 Not Found")
+			},
+			expectedStatus: http.StatusBadGateway,
+			expectedBody:   "failed to resolve package: dependency graph request failed: 404 Not Found\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Store: &mockStore{ListWaiversFn: func(ctx context.Context) ([]storage.Waiver, error) {
+					return tt.waivers, nil
+				}},
+				DataManager: &mockManager{ResolveFn: tt.resolveFn},
+				Policies: &mockPolicies{LoadFn: func(ctx context.Context) (policy.Policy, string, error) {
+					return p, policy.SourceDatabase, nil
+				}},
+				Log: logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.Check(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+				return
+			}
+
+			var resp CheckResponse
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, tt.expectedVerdict, resp.Verdict)
+			assert.Equal(t, tt.expectedChecked, resp.Checked)
+			var rules []string
+			for _, v := range resp.Violations {
+				rules = append(rules, v.Rule)
+			}
+			assert.Equal(t, tt.expectedRules, rules)
+			assert.Len(t, resp.Waived, tt.expectedWaived)
+		})
+	}
+}
+
+func TestCheck_NonCanonicalManifestKeys(t *testing.T) {
+	p, err := policy.Parse([]byte("rules:\n" +
+		"  - {name: denied, type: deny, packages: [{system: PYPI, name: zope.interface, version: '5.0'}, {system: GO, name: github.com/a/b, version: v1.2.3}]}\n"))
+	assert.NoError(t, err)
+
+	handler := &Handler{
+		Store: &mockStore{ListWaiversFn: func(ctx context.Context) ([]storage.Waiver, error) {
+			return nil, nil
+		}},
+		DataManager: &mockManager{},
+		Policies: &mockPolicies{LoadFn: func(ctx context.Context) (policy.Policy, string, error) {
+			return p, policy.SourceDatabase, nil
+		}},
+		Log: logrus.New(),
+	}
+
+	// Spelled as SBOM generators do, not as deps.dev does
+	body := `{"bomFormat": "CycloneDX", "specVersion": "1.5", "components": [
+		{"bom-ref": "a", "name": "Zope.Interface", "purl": "pkg:pypi/Zope.Interface@5.0"},
+		{"bom-ref": "b", "name": "b", "purl": "pkg:golang/github.com/a/b@1.2.3"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/check?manifest=sbom", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handler.Check(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp CheckResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, VerdictFail, resp.Verdict)
+	var denied []string
+	for _, v := range resp.Violations {
+		denied = append(denied, v.Dependency.Name+"@"+v.Dependency.Version)
+	}
+	assert.Equal(t, []string{"zope-interface@5.0", "github.com/a/b@v1.2.3"}, denied)
+}
+
+func TestCreateSchedule(t *testing.T) {
+	next := time.Date(2026, 10, 19, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		body             string
+		createErr        error
+		expectedSchedule storage.RefreshSchedule
+		expectedStatus   int
+		expectedBody     string
+	}{
+		{
+			name:             "hourly in UTC by default",
+			body:             `{"system": "npm", "name": "react", "version": "18.2.0", "cron": "@hourly"}`,
+			expectedSchedule: storage.RefreshSchedule{System: "NPM", Name: "react", Version: "18.2.0", Cron: "@hourly", Timezone: "UTC", Enabled: true},
+			expectedStatus:   http.StatusCreated,
+		},
+		{
+			name: "weekly in a timezone, disabled",
+			body: `{"system": "pypi", "name": "Request_s", "version": "0.1", "cron": "0 3 * * 1", "timezone": "Europe/Berlin", "enabled": false}`,
+			expectedSchedule: storage.RefreshSchedule{System: "PYPI", Name: "request-s", Version: "0.1", Cron: "0 3 * * 1",
+				Timezone: "Europe/Berlin"},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "missing version",
+			body:           `{"system": "npm", "name": "react", "cron": "@hourly"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "system, name, version and cron are required\n",
+		},
+		{
+			name:           "invalid cron",
+			body:           `{"system": "npm", "name": "react", "version": "18.2.0", "cron": "every hour"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid cron expression \"every hour\": expected exactly 5 fields, found 2: [every hour]\n",
+		},
+		{
+			name:           "timezone in cron",
+			body:           `{"system": "npm", "name": "react", "version": "18.2.0", "cron": "CRON_TZ=Asia/Tokyo 0 * * * *"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "cron expression must not contain a timezone, set timezone instead\n",
+		},
+		{
+			name:           "unknown timezone",
+			body:           `{"system": "npm", "name": "react", "version": "18.2.0", "cron": "@daily", "timezone": "Mars/Olympus"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown timezone \"Mars/Olympus\"\n",
+		},
+		{
+			name:             "already scheduled",
+			body:             `{"system": "npm", "name": "react", "version": "18.2.0", "cron": "@daily"}`,
+			createErr:        storage.ErrScheduleExists,
+			expectedSchedule: storage.RefreshSchedule{System: "NPM", Name: "react", Version: "18.2.0", Cron: "@daily", Timezone: "UTC", Enabled: true},
+			expectedStatus:   http.StatusConflict,
+			expectedBody:     "package already has a refresh schedule\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored storage.RefreshSchedule
+			scheduler := &mockScheduler{}
+			handler := &Handler{
+				Store: &mockStore{
+					CreateSchedFn: func(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error) {
+						assert.Equal(t, tt.expectedSchedule, rs)
+						rs.ID = 1
+						stored = rs
+						return rs, tt.createErr
+					},
+					// The reload stores the next run time
+					GetSchedFn: func(ctx context.Context, id int64) (storage.RefreshSchedule, error) {
+						stored.NextRunAt = &next
+						return stored, nil
+					},
+				},
+				Scheduler: scheduler,
+				Log:       logrus.New(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/schedules", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.CreateSchedule(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+				assert.Zero(t, scheduler.Reloads)
+				return
+			}
+
+			var got storage.RefreshSchedule
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+			assert.Equal(t, int64(1), got.ID)
+			assert.True(t, next.Equal(*got.NextRunAt))
+			assert.Equal(t, 1, scheduler.Reloads)
+		})
+	}
+}
+
+func TestScheduleByID(t *testing.T) {
+	body := `{"system": "npm", "name": "react", "version": "18.2.0", "cron": "0 */6 * * *", "timezone": "America/New_York"}`
+	found := storage.RefreshSchedule{ID: 3, System: "NPM", Name: "react", Version: "18.2.0", Cron: "0 0 * * *", Timezone: "UTC", Enabled: true}
+
+	store := &mockStore{
+		GetSchedFn: func(ctx context.Context, id int64) (storage.RefreshSchedule, error) {
+			if id != 3 {
+				return storage.RefreshSchedule{}, sql.ErrNoRows
+			}
+			return found, nil
+		},
+		UpdateSchedFn: func(ctx context.Context, rs storage.RefreshSchedule) (storage.RefreshSchedule, error) {
+			switch rs.ID {
+			case 3:
+				assert.Equal(t, "0 */6 * * *", rs.Cron)
+				assert.Equal(t, "America/New_York", rs.Timezone)
+				found = rs
+				return rs, nil
+			case 5:
+				return storage.RefreshSchedule{}, storage.ErrScheduleExists
+			}
+			return storage.RefreshSchedule{}, sql.ErrNoRows
+		},
+		DeleteSchedFn: func(ctx context.Context, id int64) error {
+			if id == 4 {
+				return errors.New("db error")
+			}
+			if id != 3 {
+				return sql.ErrNoRows
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name            string
+		method          string
+		id              string
+		body            string
+		expectedStatus  int
+		expectedBody    string
+		expectedReloads int
+	}{
+		{"get", http.MethodGet, "3", "", http.StatusOK, "", 0},
+		{"get missing", http.MethodGet, "9", "", http.StatusNotFound, "schedule not found\n", 0},
+		{"get invalid id", http.MethodGet, "0", "", http.StatusBadRequest, "invalid schedule id\n", 0},
+		{"update", http.MethodPut, "3", body, http.StatusOK, "", 1},
+		{"update missing", http.MethodPut, "9", body, http.StatusNotFound, "schedule not found\n", 0},
+		{"update to a scheduled package", http.MethodPut, "5", body, http.StatusConflict, "package already has a refresh schedule\n", 0},
+		{"delete", http.MethodDelete, "3", "", http.StatusNoContent, "", 1},
+		{"delete missing", http.MethodDelete, "9", "", http.StatusNotFound, "schedule not found\n", 0},
+		{"delete error", http.MethodDelete, "4", "", http.StatusInternalServerError, "failed to delete schedule\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler := &mockScheduler{}
+			handler := &Handler{Store: store, Scheduler: scheduler, Log: logrus.New()}
+
+			req := httptest.NewRequest(tt.method, "/schedules/"+tt.id, bytes.NewBufferString(tt.body))
+			routeCtx := chi.NewRouteContext()
+			routeCtx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+			rr := httptest.NewRecorder()
+
+			switch tt.method {
+			case http.MethodGet:
+				handler.GetSchedule(rr, req)
+			case http.MethodPut:
+				handler.UpdateSchedule(rr, req)
+			case http.MethodDelete:
+				handler.DeleteSchedule(rr, req)
+			}
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK {
+				var got storage.RefreshSchedule
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+				assert.Equal(t, int64(3), got.ID)
+			}
+			assert.Equal(t, tt.expectedReloads, scheduler.Reloads)
+		})
+	}
 }
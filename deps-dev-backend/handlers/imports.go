@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"deps-dev/importers"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+const maxImportSize = 50 << 20
+
+type ImportResponse struct {
+	Parsed   int               `json:"parsed"`
+	Imported int               `json:"imported"`
+	Issues   []importers.Issue `json:"issues"`
+	Edges    []importers.Edge  `json:"edges,omitempty"`
+}
+
+// manifest is a dependency source clients can upload, keyed in manifests by
+// its name in the /imports routes.
+type manifest struct {
+	kind  string
+	parse func(*http.Request) (*importers.Result, error)
+}
+
+var manifests = map[string]manifest{
+	"npm-lockfile":     {"package-lock.json", parseBody(importers.ParseNpmLockfile)},
+	"go-mod":           {"go.mod", parseGoModulesUpload},
+	"requirements-txt": {"requirements.txt", parseBody(importers.ParseRequirements)},
+	"poetry-lock":      {"poetry.lock", parseBody(importers.ParsePoetryLock)},
+	"uv-lock":          {"uv.lock", parseBody(importers.ParseUvLock)},
+	"cargo-lock":       {"Cargo.lock", parseBody(importers.ParseCargoLock)},
+	"maven-tree":       {"dependency tree", parseBody(importers.ParseMavenTree)},
+	"sbom":             {"SBOM", parseBody(importers.ParseSBOM)},
+}
+
+func parseBody(parse func(io.Reader) (*importers.Result, error)) func(*http.Request) (*importers.Result, error) {
+	return func(r *http.Request) (*importers.Result, error) {
+		return parse(r.Body)
+	}
+}
+
+func (h *Handler) ImportNpmLockfile(w http.ResponseWriter, r *http.Request) {
+	h.importDependencies(w, r, manifests["npm-lockfile"])
+}
+
+func (h *Handler) ImportRequirements(w http.ResponseWriter, r *http.Request) {
+	h.importDependencies(w, r, manifests["requirements-txt"])
+}
+
+func (h *Handler) ImportPoetryLock(w http.ResponseWriter, r *http.Request) {
+	h.importDependencies(w, r, manifests["poetry-lock"])
+}
+
+func (h *Handler) ImportUvLock(w http.ResponseWriter, r *http.Request) {
+	h.importDependencies(w, r, manifests["uv-lock"])
+}
+
+func (h *Handler) ImportCargoLock(w http.ResponseWriter, r *http.Request) {
+	h.importDependencies(w, r, manifests["cargo-lock"])
+}
+
+// ImportMavenTree accepts `mvn dependency:tree` output in text or JSON format.
+func (h *Handler) ImportMavenTree(w http.ResponseWriter, r *http.Request) {
+	h.importDependencies(w, r, manifests["maven-tree"])
+}
+
+// ImportSBOM accepts a CycloneDX or SPDX JSON document.
+func (h *Handler) ImportSBOM(w http.ResponseWriter, r *http.Request) {
+	h.importDependencies(w, r, manifests["sbom"])
+}
+
+// ImportGoModules accepts either a raw go.mod body or a multipart form with a
+// go.mod file and optional go.sum and graph (`go mod graph` output) files.
+func (h *Handler) ImportGoModules(w http.ResponseWriter, r *http.Request) {
+	h.importDependencies(w, r, manifests["go-mod"])
+}
+
+func parseGoModulesUpload(r *http.Request) (*importers.Result, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return importers.ParseGoModules(r.Body, nil, nil)
+	}
+
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		return nil, fmt.Errorf("failed to read multipart form: %w", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	gomod, err := formFile(r, "go.mod")
+	if err != nil {
+		return nil, err
+	}
+	if gomod == nil {
+		return nil, errors.New("missing go.mod file")
+	}
+	defer gomod.Close()
+
+	var optional []io.Reader
+	for _, field := range []string{"go.sum", "graph"} {
+		f, err := formFile(r, field)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			// A nil interface, not a typed nil, so the parser skips it
+			optional = append(optional, nil)
+			continue
+		}
+		defer f.Close()
+		optional = append(optional, f)
+	}
+
+	return importers.ParseGoModules(gomod, optional[0], optional[1])
+}
+
+// formFile returns the uploaded file for field, or nil if there is none.
+func formFile(r *http.Request, field string) (multipart.File, error) {
+	f, _, err := r.FormFile(field)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", field, err)
+	}
+	return f, nil
+}
+
+func (h *Handler) importDependencies(w http.ResponseWriter, r *http.Request, m manifest) {
+	result, ok := h.parseManifest(w, r, m)
+	if !ok {
+		return
+	}
+
+	imported, err := h.DataManager.ImportDependencies(r.Context(), result.Dependencies)
+	if err != nil {
+		h.Log.WithError(err).Errorf("importing dependencies from %s", m.kind)
+		http.Error(w, "failed to import dependencies", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ImportResponse{
+		Parsed:   len(result.Dependencies),
+		Imported: imported,
+		Issues:   result.Issues,
+		Edges:    result.Edges,
+	}
+	if resp.Issues == nil {
+		resp.Issues = []importers.Issue{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Log.WithError(err).Error("encoding import response")
+	}
+}
+
+// parseManifest parses an uploaded manifest, canonicalizes the keys of its
+// dependencies and sets their depths. On failure it writes the error
+// response and returns false.
+func (h *Handler) parseManifest(w http.ResponseWriter, r *http.Request, m manifest) (*importers.Result, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	result, err := m.parse(r)
+	if err != nil {
+		h.Log.WithError(err).Warnf("parsing uploaded %s", m.kind)
+		http.Error(w, "invalid "+m.kind+": "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	result.Canonicalize()
+	result.SetDepths()
+	return result, true
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"deps-dev/policy"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxPolicySize = 1 << 20
+
+type Policies interface {
+	Load(ctx context.Context) (policy.Policy, string, error)
+	Save(ctx context.Context, document []byte) (policy.Policy, error)
+	Evaluate(ctx context.Context) (int, error)
+}
+
+type PolicyResponse struct {
+	// Source is "file" when POLICY_FILE is set, "database" otherwise
+	Source string        `json:"source"`
+	Rules  []policy.Rule `json:"rules"`
+}
+
+// ListViolations writes the violations found by the last policy evaluation,
+// optionally limited to one rule with the rule query parameter. Violations
+// waived by an active waiver are left out unless include_waived is set.
+func (h *Handler) ListViolations(w http.ResponseWriter, r *http.Request) {
+	var includeWaived bool
+	if s := r.URL.Query().Get("include_waived"); s != "" {
+		var err error
+		if includeWaived, err = strconv.ParseBool(s); err != nil {
+			http.Error(w, "invalid include_waived value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	violations, err := h.Store.ListPolicyViolations(r.Context(), r.URL.Query().Get("rule"))
+	if err != nil {
+		h.Log.WithError(err).Error("listing policy violations")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Waivers are applied on read, so violations resurface as soon as their
+	// waiver expires rather than at the next evaluation
+	waivers, err := h.Store.ListWaivers(r.Context())
+	if err != nil {
+		h.Log.WithError(err).Error("listing waivers")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	policy.ApplyWaivers(violations, waivers, time.Now())
+
+	if !includeWaived {
+		unwaived := violations[:0]
+		for _, v := range violations {
+			if v.WaiverID == nil {
+				unwaived = append(unwaived, v)
+			}
+		}
+		violations = unwaived
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(violations); err != nil {
+		h.Log.WithError(err).Error("encoding policy violations response")
+	}
+}
+
+func (h *Handler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	p, source, err := h.Policies.Load(r.Context())
+	if err != nil {
+		h.Log.WithError(err).Error("loading policy")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writePolicy(w, p, source)
+}
+
+// UpdatePolicy replaces the policy stored in the database with a YAML or
+// JSON document and re-evaluates the stored dependencies against it.
+func (h *Handler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	document, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPolicySize))
+	if err != nil {
+		http.Error(w, "failed to read policy", http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.Policies.Save(r.Context(), document)
+	if errors.Is(err, policy.ErrReadOnly) {
+		http.Error(w, "policy is loaded from POLICY_FILE and cannot be changed", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, policy.ErrInvalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.Log.WithError(err).Error("saving policy")
+		http.Error(w, "failed to save policy", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := h.Policies.Evaluate(r.Context()); err != nil {
+		h.Log.WithError(err).Error("evaluating updated policy")
+		http.Error(w, "failed to evaluate policy", http.StatusInternalServerError)
+		return
+	}
+
+	h.writePolicy(w, p, policy.SourceDatabase)
+}
+
+// evaluatePolicies refreshes the stored violations after a manual change to
+// the dependencies. The change itself has already succeeded, so a failed
+// evaluation is only logged.
+func (h *Handler) evaluatePolicies(ctx context.Context) {
+	if h.Policies == nil {
+		return
+	}
+	if _, err := h.Policies.Evaluate(ctx); err != nil {
+		h.Log.WithError(err).Error("evaluating policy after dependency change")
+	}
+}
+
+func (h *Handler) writePolicy(w http.ResponseWriter, p policy.Policy, source string) {
+	rules := p.Rules
+	if rules == nil {
+		rules = []policy.Rule{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(PolicyResponse{Source: source, Rules: rules}); err != nil {
+		h.Log.WithError(err).Error("encoding policy response")
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"deps-dev/ecosystem"
+	"deps-dev/purl"
+	"deps-dev/storage"
+	"fmt"
+	"net/http"
+)
+
+// GetDependencyByPURL fetches a dependency addressed by the purl query
+// parameter, e.g. ?purl=pkg:npm/%40babel/core@7.22.0.
+func (h *Handler) GetDependencyByPURL(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("purl")
+	if raw == "" {
+		http.Error(w, "missing purl query parameter", http.StatusBadRequest)
+		return
+	}
+
+	var dep storage.Dependency
+	if err := setFromPURL(&dep, raw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.writeDependency(w, r, dep.System, dep.Name, dep.Version)
+}
+
+// applyPURL fills in the system, name and version of dep from its package
+// URL. Fields the client set as well must agree with it.
+func applyPURL(dep *storage.Dependency) error {
+	var fromPURL storage.Dependency
+	if err := setFromPURL(&fromPURL, dep.PURL); err != nil {
+		return err
+	}
+
+	if dep.System != "" || dep.Name != "" || dep.Version != "" {
+		system, name, version := fromPURL.System, fromPURL.Name, fromPURL.Version
+		if dep.System != "" {
+			system = dep.System
+		}
+		if dep.Name != "" {
+			name = dep.Name
+		}
+		if dep.Version != "" {
+			version = dep.Version
+		}
+
+		system, name, version, err := ecosystem.Canonicalize(system, name, version)
+		if err != nil || system != fromPURL.System || name != fromPURL.Name || version != fromPURL.Version {
+			return fmt.Errorf("purl does not match system, name and version")
+		}
+	}
+
+	dep.System, dep.Name, dep.Version = fromPURL.System, fromPURL.Name, fromPURL.Version
+	return nil
+}
+
+// setFromPURL sets the canonical system, name and version of dep from a
+// package URL of a type deps.dev covers. The version is required.
+func setFromPURL(dep *storage.Dependency, raw string) error {
+	p, err := purl.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	system, name, ok := p.DepsDev()
+	if !ok {
+		return fmt.Errorf("package type %s is not covered by deps.dev", p.Type)
+	}
+	if p.Version == "" {
+		return fmt.Errorf("purl must include a version")
+	}
+
+	dep.System, dep.Name, dep.Version, err = ecosystem.Canonicalize(system, name, p.Version)
+	return err
+}
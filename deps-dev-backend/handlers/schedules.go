@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"deps-dev/data"
+	"deps-dev/ecosystem"
+	"deps-dev/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Scheduler runs the stored refresh schedules and is reloaded after every
+// change to them.
+type Scheduler interface {
+	Reload(ctx context.Context) error
+}
+
+// ScheduleRequest creates or replaces a refresh schedule. Cron is a standard
+// 5-field expression or a descriptor like @hourly or @weekly, evaluated in
+// Timezone (UTC by default). Schedules are enabled unless Enabled is false.
+type ScheduleRequest struct {
+	System   string `json:"system"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone,omitempty"`
+	Enabled  *bool  `json:"enabled,omitempty"`
+}
+
+func (h *Handler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.Store.ListRefreshSchedules(r.Context())
+	if err != nil {
+		h.Log.WithError(err).Error("listing refresh schedules")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(schedules); err != nil {
+		h.Log.WithError(err).Error("encoding schedules response")
+	}
+}
+
+func (h *Handler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := scheduleID(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.Store.GetRefreshSchedule(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.WithError(err).Error("fetching refresh schedule")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeSchedule(w, http.StatusOK, schedule)
+}
+
+func (h *Handler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := decodeSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.Store.CreateRefreshSchedule(r.Context(), schedule)
+	if errors.Is(err, storage.ErrScheduleExists) {
+		http.Error(w, "package already has a refresh schedule", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.Log.WithError(err).Error("creating refresh schedule")
+		http.Error(w, "failed to create schedule", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeSchedule(w, http.StatusCreated, h.reloadSchedule(r.Context(), created))
+}
+
+func (h *Handler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := scheduleID(w, r)
+	if !ok {
+		return
+	}
+	schedule, ok := decodeSchedule(w, r)
+	if !ok {
+		return
+	}
+	schedule.ID = id
+
+	updated, err := h.Store.UpdateRefreshSchedule(r.Context(), schedule)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrScheduleExists) {
+		http.Error(w, "package already has a refresh schedule", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.Log.WithError(err).Error("updating refresh schedule")
+		http.Error(w, "failed to update schedule", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeSchedule(w, http.StatusOK, h.reloadSchedule(r.Context(), updated))
+}
+
+func (h *Handler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := scheduleID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.Store.DeleteRefreshSchedule(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.WithError(err).Error("deleting refresh schedule")
+		http.Error(w, "failed to delete schedule", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Scheduler.Reload(r.Context()); err != nil {
+		h.Log.WithError(err).Error("reloading refresh schedules")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadSchedule applies the stored schedules to the scheduler and returns
+// schedule with its next run time. The change is already stored, so a failed
+// reload is only logged; the scheduler picks it up on the next reload.
+func (h *Handler) reloadSchedule(ctx context.Context, schedule storage.RefreshSchedule) storage.RefreshSchedule {
+	if err := h.Scheduler.Reload(ctx); err != nil {
+		h.Log.WithError(err).Error("reloading refresh schedules")
+		return schedule
+	}
+
+	reloaded, err := h.Store.GetRefreshSchedule(ctx, schedule.ID)
+	if err != nil {
+		h.Log.WithError(err).Error("fetching refresh schedule")
+		return schedule
+	}
+	return reloaded
+}
+
+func (h *Handler) writeSchedule(w http.ResponseWriter, status int, schedule storage.RefreshSchedule) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(schedule); err != nil {
+		h.Log.WithError(err).Error("encoding schedule response")
+	}
+}
+
+// scheduleID reads the id path parameter. On failure it writes the error
+// response and returns false.
+func scheduleID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeSchedule reads and validates a ScheduleRequest body, canonicalizing
+// the package key. On failure it writes the error response and returns false.
+func decodeSchedule(w http.ResponseWriter, r *http.Request) (storage.RefreshSchedule, bool) {
+	var input ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return storage.RefreshSchedule{}, false
+	}
+
+	if input.System == "" || input.Name == "" || input.Version == "" || input.Cron == "" {
+		http.Error(w, "system, name, version and cron are required", http.StatusBadRequest)
+		return storage.RefreshSchedule{}, false
+	}
+
+	system, name, version, err := ecosystem.Canonicalize(input.System, input.Name, input.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return storage.RefreshSchedule{}, false
+	}
+
+	if input.Timezone == "" {
+		input.Timezone = "UTC"
+	}
+	if _, err := data.ParseSchedule(input.Cron, input.Timezone); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return storage.RefreshSchedule{}, false
+	}
+
+	return storage.RefreshSchedule{
+		System:   system,
+		Name:     name,
+		Version:  version,
+		Cron:     input.Cron,
+		Timezone: input.Timezone,
+		Enabled:  input.Enabled == nil || *input.Enabled,
+	}, true
+}
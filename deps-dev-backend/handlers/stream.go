@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"deps-dev/storage"
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+var formatContentTypes = map[string]string{
+	formatCSV:    "text/csv; charset=utf-8",
+	formatNDJSON: "application/x-ndjson",
+}
+
+// Column order of CSV exports. Append new columns at the end so
+// spreadsheets built on earlier exports keep working.
+var csvColumns = []string{
+	"system",
+	"name",
+	"version",
+	"relation",
+	"source_repo",
+	"openssf_score",
+	"license",
+	"metadata_fetched_at",
+	"score_fetched_at",
+	"purl",
+	"depth",
+}
+
+// listFormat picks the list format from the format query parameter, falling
+// back to the first CSV or NDJSON media type in the Accept header and then
+// to JSON. It returns false for an unknown format value.
+func listFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+	case formatJSON, formatCSV, formatNDJSON:
+		return format, true
+	default:
+		return "", false
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return formatCSV, true
+		case "application/x-ndjson":
+			return formatNDJSON, true
+		case "application/json":
+			return formatJSON, true
+		}
+	}
+	return formatJSON, true
+}
+
+// streamDependencies writes the filtered dependencies as CSV or NDJSON
+// while reading them, so large lists are never held in memory. Errors after
+// the first row can only be logged, as the status has already been sent.
+func (h *Handler) streamDependencies(w http.ResponseWriter, r *http.Request, format string) {
+	name, minScore, ok := listFilters(w, r)
+	if !ok {
+		return
+	}
+
+	var write func(storage.Dependency) error
+	var flush func() error
+	start := func() error { return nil }
+
+	switch format {
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		start = func() error { return cw.Write(csvColumns) }
+		write = func(dep storage.Dependency) error { return cw.Write(csvRecord(dep)) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case formatNDJSON:
+		enc := json.NewEncoder(w)
+		write = func(dep storage.Dependency) error { return enc.Encode(dep) }
+		flush = func() error { return nil }
+	}
+
+	started := false
+	begin := func() error {
+		started = true
+		w.Header().Set("Content-Type", formatContentTypes[format])
+		if format == formatCSV {
+			w.Header().Set("Content-Disposition", `attachment; filename="dependencies.csv"`)
+		}
+		return start()
+	}
+
+	err := h.Store.ForEachDependency(r.Context(), name, minScore, func(dep storage.Dependency) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		return write(dep)
+	})
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil {
+		err = flush()
+	}
+
+	if err != nil {
+		if !started {
+			h.Log.WithError(err).Error("listing dependencies with filters")
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.Log.WithError(err).WithField("format", format).Error("streaming dependencies list")
+	}
+}
+
+func csvRecord(dep storage.Dependency) []string {
+	var score, depth string
+	if dep.OpenSSFScore != nil {
+		score = strconv.FormatFloat(*dep.OpenSSFScore, 'f', -1, 64)
+	}
+	// Depth 0 is unknown
+	if dep.Depth > 0 {
+		depth = strconv.Itoa(dep.Depth)
+	}
+	return []string{
+		dep.System,
+		dep.Name,
+		dep.Version,
+		dep.Relation,
+		dep.SourceRepo,
+		score,
+		dep.License,
+		csvTime(dep.MetadataFetchedAt),
+		csvTime(dep.ScoreFetchedAt),
+		dep.PURL,
+		depth,
+	}
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
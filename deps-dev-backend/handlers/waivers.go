@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"database/sql"
+	"deps-dev/ecosystem"
+	"deps-dev/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WaiverRequest creates or replaces a waiver. ExpiresAt is an RFC 3339 time,
+// or a date like 2027-01-31 the waiver lasts through (until midnight UTC).
+type WaiverRequest struct {
+	Rule       string `json:"rule"`
+	System     string `json:"system"`
+	Name       string `json:"name"`
+	Version    string `json:"version,omitempty"`
+	Reason     string `json:"reason"`
+	ApprovedBy string `json:"approved_by"`
+	Ticket     string `json:"ticket,omitempty"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+type WaiverResponse struct {
+	storage.Waiver
+	Expired bool `json:"expired"`
+}
+
+// ListWaivers writes all waivers, or only active or expired ones with the
+// status query parameter.
+func (h *Handler) ListWaivers(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "active" && status != "expired" {
+		http.Error(w, "invalid status value", http.StatusBadRequest)
+		return
+	}
+
+	waivers, err := h.Store.ListWaivers(r.Context())
+	if err != nil {
+		h.Log.WithError(err).Error("listing waivers")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	list := []WaiverResponse{}
+	for _, waiver := range waivers {
+		expired := !waiver.Active(now)
+		if (status == "active" && expired) || (status == "expired" && !expired) {
+			continue
+		}
+		list = append(list, WaiverResponse{Waiver: waiver, Expired: expired})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		h.Log.WithError(err).Error("encoding waivers response")
+	}
+}
+
+func (h *Handler) GetWaiver(w http.ResponseWriter, r *http.Request) {
+	id, ok := waiverID(w, r)
+	if !ok {
+		return
+	}
+
+	waiver, err := h.Store.GetWaiver(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "waiver not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.WithError(err).Error("fetching waiver")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeWaiver(w, http.StatusOK, waiver)
+}
+
+func (h *Handler) CreateWaiver(w http.ResponseWriter, r *http.Request) {
+	waiver, ok := h.decodeWaiver(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.Store.CreateWaiver(r.Context(), waiver)
+	if err != nil {
+		h.Log.WithError(err).Error("creating waiver")
+		http.Error(w, "failed to create waiver", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeWaiver(w, http.StatusCreated, created)
+}
+
+func (h *Handler) UpdateWaiver(w http.ResponseWriter, r *http.Request) {
+	id, ok := waiverID(w, r)
+	if !ok {
+		return
+	}
+	waiver, ok := h.decodeWaiver(w, r)
+	if !ok {
+		return
+	}
+	waiver.ID = id
+
+	updated, err := h.Store.UpdateWaiver(r.Context(), waiver)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "waiver not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.WithError(err).Error("updating waiver")
+		http.Error(w, "failed to update waiver", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeWaiver(w, http.StatusOK, updated)
+}
+
+func (h *Handler) DeleteWaiver(w http.ResponseWriter, r *http.Request) {
+	id, ok := waiverID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.Store.DeleteWaiver(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "waiver not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.WithError(err).Error("deleting waiver")
+		http.Error(w, "failed to delete waiver", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) writeWaiver(w http.ResponseWriter, status int, waiver storage.Waiver) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	resp := WaiverResponse{Waiver: waiver, Expired: !waiver.Active(time.Now())}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Log.WithError(err).Error("encoding waiver response")
+	}
+}
+
+// waiverID reads the id path parameter. On failure it writes the error
+// response and returns false.
+func waiverID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		http.Error(w, "invalid waiver id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeWaiver reads and validates a WaiverRequest body, canonicalizing the
+// package key. On failure it writes the error response and returns false.
+func (h *Handler) decodeWaiver(w http.ResponseWriter, r *http.Request) (storage.Waiver, bool) {
+	var input WaiverRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return storage.Waiver{}, false
+	}
+
+	if input.Rule == "" || input.System == "" || input.Name == "" || input.Reason == "" ||
+		input.ApprovedBy == "" || input.ExpiresAt == "" {
+		http.Error(w, "rule, system, name, reason, approved_by and expires_at are required", http.StatusBadRequest)
+		return storage.Waiver{}, false
+	}
+	if !h.checkWaiverRule(w, r, input.Rule) {
+		return storage.Waiver{}, false
+	}
+
+	system, name, version, err := ecosystem.Canonicalize(input.System, input.Name, input.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return storage.Waiver{}, false
+	}
+
+	expiresAt, err := parseExpiry(input.ExpiresAt)
+	if err != nil {
+		http.Error(w, "invalid expires_at value, expected an RFC 3339 time or YYYY-MM-DD date", http.StatusBadRequest)
+		return storage.Waiver{}, false
+	}
+	if !expiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return storage.Waiver{}, false
+	}
+
+	return storage.Waiver{
+		Rule:       input.Rule,
+		System:     system,
+		Name:       name,
+		Version:    version,
+		Reason:     input.Reason,
+		ApprovedBy: input.ApprovedBy,
+		Ticket:     input.Ticket,
+		ExpiresAt:  expiresAt,
+	}, true
+}
+
+// checkWaiverRule rejects rules the loaded policy does not define, as a
+// waiver for one would never apply. On failure it writes the error response
+// and returns false.
+func (h *Handler) checkWaiverRule(w http.ResponseWriter, r *http.Request, rule string) bool {
+	p, _, err := h.Policies.Load(r.Context())
+	if err != nil {
+		h.Log.WithError(err).Error("loading policy")
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	names := make([]string, 0, len(p.Rules))
+	for _, defined := range p.Rules {
+		if defined.Name == rule {
+			return true
+		}
+		names = append(names, defined.Name)
+	}
+	if len(names) == 0 {
+		http.Error(w, fmt.Sprintf("unknown rule %q, the policy has no rules", rule), http.StatusBadRequest)
+		return false
+	}
+	sort.Strings(names)
+	http.Error(w, fmt.Sprintf("unknown rule %q, expected one of %s", rule, strings.Join(names, ", ")), http.StatusBadRequest)
+	return false
+}
+
+// parseExpiry parses an RFC 3339 time, or a date lasting until the end of
+// that day in UTC.
+func parseExpiry(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,120 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Sources of crates published to crates.io, through the git and sparse index
+var cratesIOSources = map[string]bool{
+	"registry+https://github.com/rust-lang/crates.io-index": true,
+	"sparse+https://index.crates.io/":                       true,
+}
+
+type cargoPackage struct {
+	name    string
+	version string
+	source  string
+	deps    []string
+}
+
+// ParseCargoLock reads a Cargo.lock. Packages without a source are the
+// workspace's own crates and are left out; crates they depend on are DIRECT,
+// the rest INDIRECT. Crates not published to crates.io are reported as
+// issues.
+func ParseCargoLock(r io.Reader) (*Result, error) {
+	var lock struct {
+		Package []struct {
+			Name         string   `toml:"name"`
+			Version      string   `toml:"version"`
+			Source       string   `toml:"source"`
+			Dependencies []string `toml:"dependencies"`
+		} `toml:"package"`
+	}
+	if _, err := toml.NewDecoder(r).Decode(&lock); err != nil {
+		return nil, fmt.Errorf("failed to parse Cargo.lock: %w", err)
+	}
+	if len(lock.Package) == 0 {
+		return nil, fmt.Errorf("no [[package]] entries, not a Cargo.lock")
+	}
+
+	packages := make([]cargoPackage, 0, len(lock.Package))
+	byName := make(map[string][]int)
+	for _, p := range lock.Package {
+		pkg := cargoPackage{name: p.Name, version: p.Version, source: p.Source, deps: p.Dependencies}
+		byName[pkg.name] = append(byName[pkg.name], len(packages))
+		packages = append(packages, pkg)
+	}
+
+	// resolve finds the package a dependency entry refers to: "name" when only
+	// one version is locked, otherwise "name version" or "name version (source)".
+	resolve := func(ref string) (cargoPackage, bool) {
+		name, rest, _ := strings.Cut(ref, " ")
+		version, source, _ := strings.Cut(rest, " ")
+		source = strings.TrimSuffix(strings.TrimPrefix(source, "("), ")")
+
+		for _, i := range byName[name] {
+			pkg := packages[i]
+			if (version == "" || pkg.version == version) && (source == "" || pkg.source == source) {
+				return pkg, true
+			}
+		}
+		return cargoPackage{}, false
+	}
+
+	dependency := func(pkg cargoPackage) storage.Dependency {
+		if pkg.source == "" {
+			// Workspace crates are the project itself
+			return storage.Dependency{}
+		}
+		return storage.Dependency{System: SystemCargo, Name: pkg.name, Version: pkg.version}
+	}
+
+	direct := make(map[string]bool)
+	for _, pkg := range packages {
+		if pkg.source != "" {
+			continue
+		}
+		for _, ref := range pkg.deps {
+			if dep, ok := resolve(ref); ok {
+				direct[dep.name+" "+dep.version] = true
+			}
+		}
+	}
+
+	result := &Result{}
+	seen := make(map[string]int)
+	for _, pkg := range packages {
+		if pkg.source != "" {
+			entry := pkg.name + " " + pkg.version
+			if !cratesIOSources[pkg.source] {
+				result.skip(0, entry, "not published to crates.io: "+pkg.source)
+				continue
+			}
+
+			dep := dependency(pkg)
+			dep.Relation = RelationIndirect
+			if direct[entry] {
+				dep.Relation = RelationDirect
+			}
+			result.add(seen, dep)
+		}
+
+		for _, ref := range pkg.deps {
+			target, ok := resolve(ref)
+			if !ok {
+				result.skip(0, pkg.name+" "+pkg.version, "unknown dependency "+ref)
+				continue
+			}
+			if target.source != "" && cratesIOSources[target.source] && (pkg.source == "" || cratesIOSources[pkg.source]) {
+				result.link(dependency(pkg), dependency(target))
+			}
+		}
+	}
+
+	return result, nil
+}
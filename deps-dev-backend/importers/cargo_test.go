@@ -0,0 +1,65 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCargoLock(t *testing.T) {
+	f, err := os.Open("testdata/Cargo.lock")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParseCargoLock(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "CARGO", Name: "proc-macro2", Version: "1.0.66", Relation: "INDIRECT"},
+		{System: "CARGO", Name: "serde", Version: "1.0.188", Relation: "DIRECT"},
+		{System: "CARGO", Name: "syn", Version: "1.0.109", Relation: "INDIRECT"},
+		{System: "CARGO", Name: "syn", Version: "2.0.29", Relation: "DIRECT"},
+	}, result.Dependencies)
+
+	assert.Equal(t, []Edge{
+		{To: "CARGO|serde|1.0.188"},
+		{To: "CARGO|syn|2.0.29"},
+		{From: "CARGO|syn|1.0.109", To: "CARGO|proc-macro2|1.0.66"},
+		{From: "CARGO|syn|2.0.29", To: "CARGO|proc-macro2|1.0.66"},
+	}, result.Edges)
+
+	assert.Equal(t, []Issue{
+		{Entry: "internal-macros 0.1.0", Reason: "not published to crates.io: git+https://github.com/acme/internal-macros?tag=v0.1.0#1a2b3c4d"},
+	}, result.Issues)
+}
+
+func TestParseCargoLock_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "invalid TOML",
+			input:         "[[package]\n",
+			expectedError: "failed to parse Cargo.lock",
+		},
+		{
+			name:          "no packages",
+			input:         "version = 3\n",
+			expectedError: "not a Cargo.lock",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseCargoLock(strings.NewReader(tt.input))
+			assert.Nil(t, result)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
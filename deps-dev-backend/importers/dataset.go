@@ -0,0 +1,333 @@
+package importers
+
+import (
+	"bufio"
+	"bytes"
+	"deps-dev/ecosystem"
+	"deps-dev/storage"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// datasetBatchSize is how many packages or scores are collected before they
+// are handed to the sink.
+const datasetBatchSize = 1000
+
+// maxDatasetIssues bounds the issues listed for a dataset, which can have
+// millions of unusable rows.
+const maxDatasetIssues = 1000
+
+// DatasetSink stores a batch of parsed dataset rows.
+type DatasetSink func(packages []storage.DatasetPackage, scores []storage.ProjectScore) error
+
+// Dataset summarizes bulk package metadata and project scores, loaded for
+// offline lookups when deps.dev cannot be reached. The rows themselves are
+// handed to a DatasetSink in batches as they are read, so a dump never has
+// to fit in memory.
+type Dataset struct {
+	// Packages and Scores count the rows handed to the sink.
+	Packages int
+	Scores   int
+	// Issues lists the first rows that were skipped, Skipped counts all of
+	// them.
+	Issues  []Issue
+	Skipped int
+
+	sink     DatasetSink
+	packages []storage.DatasetPackage
+	scores   []storage.ProjectScore
+}
+
+// DatasetRow is a row of a deps.dev dump, as CSV with these column names as
+// header or as JSON. A row with a source repo and score records both the
+// package metadata and the project score.
+type DatasetRow struct {
+	System       string   `json:"system"`
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	SourceRepo   string   `json:"source_repo"`
+	License      string   `json:"license"`
+	OpenSSFScore *float64 `json:"openssf_score"`
+}
+
+var datasetColumns = []string{"system", "name", "version", "source_repo", "license", "openssf_score"}
+
+// ParseDatasetCSV reads a deps.dev dump as CSV. The header names the columns,
+// in any order; system and name are required, the others optional.
+func ParseDatasetCSV(r io.Reader, sink DatasetSink) (*Dataset, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"system", "name"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column, expected a header with %s", required, strings.Join(datasetColumns, ", "))
+		}
+	}
+
+	d := &Dataset{sink: sink}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				d.skip(parseErr.Line, "", parseErr.Err.Error())
+				continue
+			}
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := DatasetRow{
+			System:     field("system"),
+			Name:       field("name"),
+			Version:    field("version"),
+			SourceRepo: field("source_repo"),
+			License:    field("license"),
+		}
+		if s := field("openssf_score"); s != "" {
+			score, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				d.skip(line, row.entry(), "invalid openssf_score "+strconv.Quote(s))
+				continue
+			}
+			row.OpenSSFScore = &score
+		}
+		if err := d.addRow(line, row); err != nil {
+			return nil, err
+		}
+	}
+	return d, d.flush()
+}
+
+// ParseDatasetJSON reads a deps.dev dump as a JSON array of rows, or as one
+// row per line (JSON Lines).
+func ParseDatasetJSON(r io.Reader, sink DatasetSink) (*Dataset, error) {
+	d := &Dataset{sink: sink}
+	err := decodeRecords(r, func(line int, raw json.RawMessage) error {
+		var row DatasetRow
+		if err := json.Unmarshal(raw, &row); err != nil {
+			d.skip(line, "", "invalid row: "+err.Error())
+			return nil
+		}
+		return d.addRow(line, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, d.flush()
+}
+
+// scorecardResult holds the fields used from an OpenSSF Scorecard result,
+// as written by `scorecard --format json` and the public BigQuery export.
+type scorecardResult struct {
+	Repo struct {
+		Name string `json:"name"`
+	} `json:"repo"`
+	Score *float64 `json:"score"`
+}
+
+// ParseScorecardResults reads OpenSSF Scorecard results, as a JSON array or
+// one result per line. Only the repository and overall score are kept.
+func ParseScorecardResults(r io.Reader, sink DatasetSink) (*Dataset, error) {
+	d := &Dataset{sink: sink}
+	err := decodeRecords(r, func(line int, raw json.RawMessage) error {
+		var result scorecardResult
+		if err := json.Unmarshal(raw, &result); err != nil {
+			d.skip(line, "", "invalid result: "+err.Error())
+			return nil
+		}
+
+		repo := projectID(result.Repo.Name)
+		switch {
+		case repo == "":
+			d.skip(line, "", "missing repo.name")
+		case result.Score == nil || *result.Score < 0:
+			// Scorecard reports -1 when no check could be run
+			d.skip(line, repo, "no score")
+		case *result.Score > 10:
+			d.skip(line, repo, "score must be between 0 and 10")
+		default:
+			d.scores = append(d.scores, storage.ProjectScore{ProjectID: repo, OpenSSFScore: *result.Score})
+		}
+		return d.flushFull()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, d.flush()
+}
+
+func (d *Dataset) addRow(line int, row DatasetRow) error {
+	if row.System == "" || row.Name == "" {
+		d.skip(line, row.entry(), "system and name are required")
+		return nil
+	}
+	system, name, version, err := ecosystem.Canonicalize(row.System, row.Name, row.Version)
+	if err != nil {
+		d.skip(line, row.entry(), err.Error())
+		return nil
+	}
+
+	repo := projectID(row.SourceRepo)
+	if row.OpenSSFScore != nil {
+		if repo == "" {
+			d.skip(line, row.entry(), "openssf_score without source_repo")
+			return nil
+		}
+		if *row.OpenSSFScore < 0 || *row.OpenSSFScore > 10 {
+			d.skip(line, row.entry(), "openssf_score must be between 0 and 10")
+			return nil
+		}
+		d.scores = append(d.scores, storage.ProjectScore{ProjectID: repo, OpenSSFScore: *row.OpenSSFScore})
+	}
+
+	if repo == "" && row.License == "" {
+		if row.OpenSSFScore == nil {
+			d.skip(line, row.entry(), "no source_repo, license or openssf_score")
+		}
+		return d.flushFull()
+	}
+	d.packages = append(d.packages, storage.DatasetPackage{
+		System:     system,
+		Name:       name,
+		Version:    version,
+		SourceRepo: repo,
+		License:    row.License,
+	})
+	return d.flushFull()
+}
+
+// flushFull hands the collected rows to the sink once a batch is full.
+func (d *Dataset) flushFull() error {
+	if len(d.packages) < datasetBatchSize && len(d.scores) < datasetBatchSize {
+		return nil
+	}
+	return d.flush()
+}
+
+func (d *Dataset) flush() error {
+	if len(d.packages) == 0 && len(d.scores) == 0 {
+		return nil
+	}
+	if err := d.sink(d.packages, d.scores); err != nil {
+		return err
+	}
+	d.Packages += len(d.packages)
+	d.Scores += len(d.scores)
+	d.packages, d.scores = nil, nil
+	return nil
+}
+
+func (d *Dataset) skip(line int, entry, reason string) {
+	d.Skipped++
+	if len(d.Issues) < maxDatasetIssues {
+		d.Issues = append(d.Issues, Issue{Line: line, Entry: entry, Reason: reason})
+	}
+}
+
+func (row DatasetRow) entry() string {
+	if row.Version == "" {
+		return row.System + "/" + row.Name
+	}
+	return row.System + "/" + row.Name + "@" + row.Version
+}
+
+// projectID turns a repository URL into a deps.dev project ID like
+// github.com/facebook/react.
+func projectID(repo string) string {
+	repo = strings.TrimSpace(repo)
+	for _, prefix := range []string{"https://", "http://"} {
+		repo = strings.TrimPrefix(repo, prefix)
+	}
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git"))
+}
+
+// maxRecordSize bounds a single JSON Lines record; Scorecard results with
+// check details run to a few hundred kilobytes.
+const maxRecordSize = 16 << 20
+
+// decodeRecords calls fn with every record of a JSON array, or of a JSON
+// Lines stream with the line number of each record. Records of an array
+// have line 0. An error from fn stops decoding and is returned as is.
+func decodeRecords(r io.Reader, fn func(line int, raw json.RawMessage) error) error {
+	br := bufio.NewReader(r)
+	first, err := firstByte(br)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if first == '[' {
+		dec := json.NewDecoder(br)
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("failed to decode JSON: %w", err)
+		}
+		for dec.More() {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return fmt.Errorf("failed to decode JSON: %w", err)
+			}
+			if err := fn(0, raw); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("failed to decode JSON: %w", err)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64<<10), maxRecordSize)
+	for line := 1; scanner.Scan(); line++ {
+		record := bytes.TrimSpace(scanner.Bytes())
+		if len(record) == 0 {
+			continue
+		}
+		if err := fn(line, json.RawMessage(record)); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read JSON Lines: %w", err)
+	}
+	return nil
+}
+
+// firstByte peeks at the first non-space byte of br without consuming it.
+func firstByte(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, br.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collector is a DatasetSink keeping every batch it is handed.
+type collector struct {
+	Packages []storage.DatasetPackage
+	Scores   []storage.ProjectScore
+	Batches  int
+}
+
+func (c *collector) store(packages []storage.DatasetPackage, scores []storage.ProjectScore) error {
+	c.Packages = append(c.Packages, packages...)
+	c.Scores = append(c.Scores, scores...)
+	c.Batches++
+	return nil
+}
+
+func TestParseDatasetCSV(t *testing.T) {
+	input := `Name,System,Version,Source_Repo,License,OpenSSF_Score
+react,npm,,https://github.com/facebook/react.git,MIT,6.8
+react,npm,0.14.0,,BSD-3-Clause,
+Django_REST,pypi,3.14.0,github.com/encode/django-rest-framework,,
+left-pad,npm,1.3.0,,,
+jquery,bower,3.0.0,,MIT,
+is-odd,npm,3.0.1,github.com/jonschlinkert/is-odd,,ten
+is-even,npm,1.0.0,,,4.0
+"broken,npm
+`
+	c := &collector{}
+	d, err := ParseDatasetCSV(strings.NewReader(input), c.store)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.DatasetPackage{
+		{System: "NPM", Name: "react", SourceRepo: "github.com/facebook/react", License: "MIT"},
+		{System: "NPM", Name: "react", Version: "0.14.0", License: "BSD-3-Clause"},
+		{System: "PYPI", Name: "django-rest", Version: "3.14.0", SourceRepo: "github.com/encode/django-rest-framework"},
+	}, c.Packages)
+	assert.Equal(t, []storage.ProjectScore{{ProjectID: "github.com/facebook/react", OpenSSFScore: 6.8}}, c.Scores)
+	assert.Equal(t, 3, d.Packages)
+	assert.Equal(t, 1, d.Scores)
+	assert.Equal(t, []Issue{
+		{Line: 5, Entry: "npm/left-pad@1.3.0", Reason: "no source_repo, license or openssf_score"},
+		{Line: 6, Entry: "bower/jquery@3.0.0", Reason: `unknown system "bower", expected one of CARGO, GO, MAVEN, NPM, NUGET, PYPI, RUBYGEMS`},
+		{Line: 7, Entry: "npm/is-odd@3.0.1", Reason: `invalid openssf_score "ten"`},
+		{Line: 8, Entry: "npm/is-even@1.0.0", Reason: "openssf_score without source_repo"},
+		{Line: 9, Reason: `extraneous or missing " in quoted-field`},
+	}, d.Issues)
+}
+
+func TestParseDatasetCSV_MissingColumn(t *testing.T) {
+	c := &collector{}
+	_, err := ParseDatasetCSV(strings.NewReader("package,version\nreact,18.2.0\n"), c.store)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "missing system column")
+}
+
+func TestParseDatasetJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"array", `[
+			{"system": "npm", "name": "react", "source_repo": "github.com/facebook/react", "openssf_score": 6.8},
+			{"system": "npm", "name": "left-pad"}
+		]`},
+		{"lines", `{"system": "npm", "name": "react", "source_repo": "github.com/facebook/react", "openssf_score": 6.8}
+
+{"system": "npm", "name": "left-pad"}
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &collector{}
+			d, err := ParseDatasetJSON(strings.NewReader(tt.input), c.store)
+			assert.NoError(t, err)
+			assert.Equal(t, []storage.DatasetPackage{{System: "NPM", Name: "react", SourceRepo: "github.com/facebook/react"}}, c.Packages)
+			assert.Equal(t, []storage.ProjectScore{{ProjectID: "github.com/facebook/react", OpenSSFScore: 6.8}}, c.Scores)
+			if assert.Len(t, d.Issues, 1) {
+				assert.Equal(t, "npm/left-pad", d.Issues[0].Entry)
+			}
+		})
+	}
+
+	_, err := ParseDatasetJSON(strings.NewReader(`[{"system": "npm"`), (&collector{}).store)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to decode JSON")
+}
+
+func TestParseScorecardResults(t *testing.T) {
+	input := `{"date":"2026-10-12","repo":{"name":"github.com/ossf/Scorecard","commit":"c0ffee"},"score":8.2,"checks":[{"name":"Maintained","score":10}]}
+{"date":"2026-10-12","repo":{"name":"github.com/x/unscored"},"score":-1}
+{"date":"2026-10-12","score":5}
+not json
+`
+	c := &collector{}
+	d, err := ParseScorecardResults(strings.NewReader(input), c.store)
+	assert.NoError(t, err)
+	assert.Empty(t, c.Packages)
+	assert.Equal(t, []storage.ProjectScore{{ProjectID: "github.com/ossf/scorecard", OpenSSFScore: 8.2}}, c.Scores)
+	if assert.Len(t, d.Issues, 3) {
+		assert.Equal(t, Issue{Line: 2, Entry: "github.com/x/unscored", Reason: "no score"}, d.Issues[0])
+		assert.Equal(t, Issue{Line: 3, Reason: "missing repo.name"}, d.Issues[1])
+		assert.Equal(t, 4, d.Issues[2].Line)
+		assert.Contains(t, d.Issues[2].Reason, "invalid result")
+	}
+}
+
+func TestParseDataset_Batches(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("system,name,version,license\n")
+	for i := 0; i < 2*datasetBatchSize+5; i++ {
+		fmt.Fprintf(&input, "npm,pkg-%d,1.0.0,MIT\n", i)
+	}
+
+	c := &collector{}
+	d, err := ParseDatasetCSV(strings.NewReader(input.String()), c.store)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, c.Batches)
+	assert.Len(t, c.Packages, 2*datasetBatchSize+5)
+	assert.Equal(t, 2*datasetBatchSize+5, d.Packages)
+
+	t.Run("sink errors stop parsing", func(t *testing.T) {
+		calls := 0
+		_, err := ParseDatasetCSV(strings.NewReader(input.String()), func(packages []storage.DatasetPackage, scores []storage.ProjectScore) error {
+			calls++
+			return errors.New("disk full")
+		})
+		assert.EqualError(t, err, "disk full")
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestParseDataset_CapsIssues(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("system,name\n")
+	for i := 0; i < maxDatasetIssues+10; i++ {
+		fmt.Fprintf(&input, "npm,pkg-%d\n", i)
+	}
+
+	d, err := ParseDatasetCSV(strings.NewReader(input.String()), (&collector{}).store)
+	assert.NoError(t, err)
+	assert.Len(t, d.Issues, maxDatasetIssues)
+	assert.Equal(t, maxDatasetIssues+10, d.Skipped)
+}
@@ -0,0 +1,261 @@
+package importers
+
+import (
+	"bufio"
+	"deps-dev/storage"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+type goRequire struct {
+	version  string
+	indirect bool
+	line     int
+}
+
+type goReplace struct {
+	path    string
+	version string
+}
+
+type goModFile struct {
+	module   string
+	requires map[string]goRequire
+	// replacements keyed by module path, or path@version for version-specific ones
+	replaces map[string]goReplace
+}
+
+// goModule is a module path and version as `go mod graph` lists them. The
+// main module has no version.
+type goModule struct {
+	path    string
+	version string
+}
+
+// ParseGoModules reads a go.mod and optionally a go.sum and the output of
+// `go mod graph`. Requirements marked `// indirect` are INDIRECT, the others
+// DIRECT. The graph and go.sum add modules missing from go.mod as INDIRECT,
+// at the version selected by the graph or the highest one in go.sum, and the
+// graph's requirements between selected versions become edges. Replacements
+// are applied; modules replaced by local directories are reported as issues.
+func ParseGoModules(gomod, gosum, graph io.Reader) (*Result, error) {
+	mod, err := parseGoMod(gomod)
+	if err != nil {
+		return nil, err
+	}
+
+	// Selected version per module path, starting from go.mod
+	selected := make(map[string]string)
+	for path, req := range mod.requires {
+		selected[path] = req.version
+	}
+
+	var requirements [][2]goModule
+	if graph != nil {
+		requirements, err = parseGoModGraph(graph)
+		if err != nil {
+			return nil, err
+		}
+
+		// Minimal version selection picks the highest version required
+		versions := make(map[string]string)
+		for _, req := range requirements {
+			for _, m := range req {
+				if m.version == "" || m.path == mod.module {
+					continue
+				}
+				if current, ok := versions[m.path]; !ok || semver.Compare(m.version, current) > 0 {
+					versions[m.path] = m.version
+				}
+			}
+		}
+		for path, version := range versions {
+			if _, ok := mod.requires[path]; !ok {
+				selected[path] = version
+			}
+		}
+	}
+
+	if gosum != nil {
+		versions, err := parseGoSum(gosum)
+		if err != nil {
+			return nil, err
+		}
+		for path, version := range versions {
+			if _, ok := selected[path]; !ok && path != mod.module {
+				selected[path] = version
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(selected))
+	for path := range selected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	result := &Result{}
+	seen := make(map[string]int)
+	modules := make(map[string]storage.Dependency)
+	for _, path := range paths {
+		version := selected[path]
+		req, required := mod.requires[path]
+
+		relation := RelationIndirect
+		if required && !req.indirect {
+			relation = RelationDirect
+		}
+
+		name := path
+		if rep, ok := mod.replacement(path, version); ok {
+			if rep.version == "" {
+				result.skip(req.line, path+" "+version, "replaced by local directory "+rep.path)
+				continue
+			}
+			name, version = rep.path, rep.version
+		}
+
+		dep := storage.Dependency{
+			System:   SystemGo,
+			Name:     name,
+			Version:  version,
+			Relation: relation,
+		}
+		modules[path] = dep
+		result.add(seen, dep)
+	}
+
+	// Requirements of versions that were not selected do not count
+	for _, req := range requirements {
+		from, to := req[0], req[1]
+		var parent storage.Dependency
+		if from.version != "" {
+			if selected[from.path] != from.version {
+				continue
+			}
+			var ok bool
+			if parent, ok = modules[from.path]; !ok {
+				continue
+			}
+		}
+		if child, ok := modules[to.path]; ok && to.version != "" {
+			result.link(parent, child)
+		}
+	}
+
+	return result, nil
+}
+
+func (m *goModFile) replacement(path, version string) (goReplace, bool) {
+	if rep, ok := m.replaces[path+"@"+version]; ok {
+		return rep, true
+	}
+	rep, ok := m.replaces[path]
+	return rep, ok
+}
+
+func parseGoMod(r io.Reader) (*goModFile, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	file, err := modfile.Parse("go.mod", content, nil)
+	if err != nil {
+		return nil, err
+	}
+	if file.Module == nil {
+		return nil, fmt.Errorf("go.mod has no module directive")
+	}
+
+	mod := &goModFile{
+		module:   file.Module.Mod.Path,
+		requires: make(map[string]goRequire),
+		replaces: make(map[string]goReplace),
+	}
+	for _, req := range file.Require {
+		mod.requires[req.Mod.Path] = goRequire{
+			version:  req.Mod.Version,
+			indirect: req.Indirect,
+			line:     req.Syntax.Start.Line,
+		}
+	}
+	for _, rep := range file.Replace {
+		key := rep.Old.Path
+		if rep.Old.Version != "" {
+			key += "@" + rep.Old.Version
+		}
+		mod.replaces[key] = goReplace{path: rep.New.Path, version: rep.New.Version}
+	}
+
+	return mod, nil
+}
+
+// parseGoModGraph returns the requirements listed in `go mod graph` output,
+// leaving out those of the go and toolchain versions.
+func parseGoModGraph(r io.Reader) ([][2]goModule, error) {
+	var requirements [][2]goModule
+
+	lineNum := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("go mod graph line %d: expected two modules", lineNum)
+		}
+
+		var req [2]goModule
+		for i, mv := range fields {
+			req[i].path, req[i].version, _ = strings.Cut(mv, "@")
+		}
+		if req[1].path == "go" || req[1].path == "toolchain" {
+			continue
+		}
+		requirements = append(requirements, req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read go mod graph: %w", err)
+	}
+
+	return requirements, nil
+}
+
+// parseGoSum returns the highest version per module that has a module zip
+// hash, skipping entries that only cover a go.mod file.
+func parseGoSum(r io.Reader) (map[string]string, error) {
+	versions := make(map[string]string)
+
+	lineNum := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("go.sum line %d: expected module, version and hash", lineNum)
+		}
+
+		path, version := fields[0], fields[1]
+		if strings.HasSuffix(version, "/go.mod") {
+			continue
+		}
+		if current, ok := versions[path]; !ok || semver.Compare(version, current) > 0 {
+			versions[path] = version
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read go.sum: %w", err)
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,150 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGoModules(t *testing.T) {
+	gomod, err := os.Open("testdata/go.mod")
+	assert.NoError(t, err)
+	defer gomod.Close()
+
+	result, err := ParseGoModules(gomod, nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "GO", Name: "github.com/davecgh/go-spew", Version: "v1.1.2-0.20180830191138-d8f796af33cc", Relation: "INDIRECT"},
+		{System: "GO", Name: "github.com/go-chi/chi/v5", Version: "v5.0.10", Relation: "DIRECT"},
+		{System: "GO", Name: "github.com/sirupsen/logrus", Version: "v1.9.3", Relation: "DIRECT"},
+		{System: "GO", Name: "github.com/stretchr/testify", Version: "v1.8.4", Relation: "DIRECT"},
+		{System: "GO", Name: "golang.org/x/sys", Version: "v0.0.0-20220715151400-c0bba94af5f8", Relation: "INDIRECT"},
+	}, result.Dependencies)
+
+	assert.Equal(t, []Issue{
+		{Line: 8, Entry: "example.com/internal/tools v0.1.0", Reason: "replaced by local directory ../tools"},
+	}, result.Issues)
+}
+
+func TestParseGoModules_GoSum(t *testing.T) {
+	gomod, err := os.Open("testdata/go.mod")
+	assert.NoError(t, err)
+	defer gomod.Close()
+	gosum, err := os.Open("testdata/go.sum")
+	assert.NoError(t, err)
+	defer gosum.Close()
+
+	result, err := ParseGoModules(gomod, gosum, nil)
+	assert.NoError(t, err)
+
+	indirect := map[string]string{}
+	for _, dep := range result.Dependencies {
+		if dep.Relation == RelationIndirect {
+			indirect[dep.Name] = dep.Version
+		}
+	}
+	// objx only has a go.mod hash, so it was never downloaded
+	assert.Equal(t, map[string]string{
+		"github.com/davecgh/go-spew":    "v1.1.2-0.20180830191138-d8f796af33cc",
+		"github.com/pmezard/go-difflib": "v1.0.0",
+		"golang.org/x/sys":              "v0.0.0-20220715151400-c0bba94af5f8",
+		"gopkg.in/yaml.v3":              "v3.0.1",
+	}, indirect)
+}
+
+func TestParseGoModules_Graph(t *testing.T) {
+	gomod := `module example.com/app
+
+require github.com/stretchr/testify v1.8.4
+`
+	graph := `example.com/app go@1.21
+example.com/app github.com/stretchr/testify@v1.8.4
+github.com/stretchr/testify@v1.8.4 go@1.20
+github.com/stretchr/testify@v1.8.4 github.com/stretchr/objx@v0.5.0
+github.com/stretchr/testify@v1.8.4 gopkg.in/yaml.v3@v3.0.1
+github.com/stretchr/objx@v0.5.0 github.com/stretchr/testify@v1.8.0
+github.com/stretchr/objx@v0.5.0 gopkg.in/yaml.v3@v3.0.0-20200313102051-9f266ea9e77c
+`
+
+	result, err := ParseGoModules(strings.NewReader(gomod), nil, strings.NewReader(graph))
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Dependency{
+		{System: "GO", Name: "github.com/stretchr/objx", Version: "v0.5.0", Relation: "INDIRECT"},
+		{System: "GO", Name: "github.com/stretchr/testify", Version: "v1.8.4", Relation: "DIRECT"},
+		{System: "GO", Name: "gopkg.in/yaml.v3", Version: "v3.0.1", Relation: "INDIRECT"},
+	}, result.Dependencies)
+
+	// Requirements resolve to the selected versions
+	assert.Equal(t, []Edge{
+		{To: "GO|github.com/stretchr/testify|v1.8.4"},
+		{From: "GO|github.com/stretchr/testify|v1.8.4", To: "GO|github.com/stretchr/objx|v0.5.0"},
+		{From: "GO|github.com/stretchr/testify|v1.8.4", To: "GO|gopkg.in/yaml.v3|v3.0.1"},
+		{From: "GO|github.com/stretchr/objx|v0.5.0", To: "GO|github.com/stretchr/testify|v1.8.4"},
+		{From: "GO|github.com/stretchr/objx|v0.5.0", To: "GO|gopkg.in/yaml.v3|v3.0.1"},
+	}, result.Edges)
+
+	result.SetDepths()
+	depths := map[string]int{}
+	for _, dep := range result.Dependencies {
+		depths[dep.Name] = dep.Depth
+	}
+	assert.Equal(t, map[string]int{
+		"github.com/stretchr/objx":    2,
+		"github.com/stretchr/testify": 1,
+		"gopkg.in/yaml.v3":            2,
+	}, depths)
+}
+
+func TestParseGoModules_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		gomod         string
+		gosum         string
+		graph         string
+		expectedError string
+	}{
+		{
+			name:          "missing module directive",
+			gomod:         "go 1.21\n",
+			expectedError: "go.mod has no module directive",
+		},
+		{
+			name:          "malformed require",
+			gomod:         "module example.com/app\n\nrequire (\n\tgithub.com/pkg/errors\n)\n",
+			expectedError: "go.mod:4:2: usage: require module/path v1.2.3",
+		},
+		{
+			name:          "malformed go.sum",
+			gomod:         "module example.com/app\n",
+			gosum:         "github.com/pkg/errors v0.9.1\n",
+			expectedError: "go.sum line 1",
+		},
+		{
+			name:          "malformed graph",
+			gomod:         "module example.com/app\n",
+			graph:         "example.com/app\n",
+			expectedError: "go mod graph line 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseGoModules(strings.NewReader(tt.gomod), optionalReader(tt.gosum), optionalReader(tt.graph))
+			assert.Nil(t, result)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+func optionalReader(s string) io.Reader {
+	if s == "" {
+		return nil
+	}
+	return strings.NewReader(s)
+}
@@ -0,0 +1,165 @@
+package importers
+
+import (
+	"deps-dev/ecosystem"
+	"deps-dev/storage"
+	"strings"
+)
+
+// deps.dev system names
+const (
+	SystemNPM   = ecosystem.NPM
+	SystemGo    = ecosystem.Go
+	SystemPyPI  = ecosystem.PyPI
+	SystemCargo = ecosystem.Cargo
+	SystemMaven = ecosystem.Maven
+)
+
+const (
+	RelationDirect   = "DIRECT"
+	RelationIndirect = "INDIRECT"
+)
+
+// Result is what an importer parsed from a manifest or lockfile.
+type Result struct {
+	Dependencies []storage.Dependency
+	// Issues lists entries that were skipped, so a partially usable file can
+	// still be imported.
+	Issues []Issue
+	// Edges is the dependency graph, for importers whose input records it.
+	Edges []Edge
+
+	linked map[Edge]bool
+}
+
+// Edge says that From requires To, both given as storage.DependencyKey.
+// From is empty for requirements of the imported project itself.
+type Edge struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+}
+
+type Issue struct {
+	Line   int    `json:"line,omitempty"`
+	Entry  string `json:"entry"`
+	Reason string `json:"reason"`
+}
+
+// add records a dependency once per system, name and version. A package
+// reached both directly and transitively is kept as DIRECT.
+func (r *Result) add(seen map[string]int, dep storage.Dependency) {
+	key := storage.DependencyKey(dep.System, dep.Name, dep.Version)
+	if i, ok := seen[key]; ok {
+		if dep.Relation == RelationDirect {
+			r.Dependencies[i].Relation = RelationDirect
+		}
+		return
+	}
+	seen[key] = len(r.Dependencies)
+	r.Dependencies = append(r.Dependencies, dep)
+}
+
+func (r *Result) link(from, to storage.Dependency) {
+	edge := Edge{To: storage.DependencyKey(to.System, to.Name, to.Version)}
+	if from.Name != "" {
+		edge.From = storage.DependencyKey(from.System, from.Name, from.Version)
+	}
+	r.addEdge(edge)
+}
+
+func (r *Result) addEdge(edge Edge) {
+	if r.linked == nil {
+		r.linked = make(map[Edge]bool)
+	}
+	if !r.linked[edge] {
+		r.linked[edge] = true
+		r.Edges = append(r.Edges, edge)
+	}
+}
+
+// Canonicalize rewrites the dependency keys and edges the way
+// ecosystem.Canonicalize spells them, merging dependencies that turn out to
+// be the same package version. Dependencies with invalid keys are skipped
+// as issues.
+func (r *Result) Canonicalize() {
+	keys := make(map[string]string)
+	seen := make(map[string]int)
+	deps := r.Dependencies
+	r.Dependencies = nil
+	for _, dep := range deps {
+		key := storage.DependencyKey(dep.System, dep.Name, dep.Version)
+		system, name, version, err := ecosystem.Canonicalize(dep.System, dep.Name, dep.Version)
+		if err != nil {
+			r.skip(0, dep.System+"/"+dep.Name+"@"+dep.Version, err.Error())
+			continue
+		}
+		dep.System, dep.Name, dep.Version = system, name, version
+		keys[key] = storage.DependencyKey(system, name, version)
+		r.add(seen, dep)
+	}
+
+	// Edges may also pass through nodes that are not dependencies, like the
+	// modules of a Maven build
+	canonicalKey := func(key string) (string, bool) {
+		if canonical, ok := keys[key]; ok {
+			return canonical, true
+		}
+		parts := strings.SplitN(key, "|", 3)
+		if len(parts) != 3 {
+			return "", false
+		}
+		system, name, version, err := ecosystem.Canonicalize(parts[0], parts[1], parts[2])
+		if err != nil {
+			return "", false
+		}
+		return storage.DependencyKey(system, name, version), true
+	}
+
+	edges := r.Edges
+	r.Edges, r.linked = nil, nil
+	for _, e := range edges {
+		to, ok := canonicalKey(e.To)
+		from, fromOK := "", true
+		if e.From != "" {
+			from, fromOK = canonicalKey(e.From)
+		}
+		if ok && fromOK {
+			r.addEdge(Edge{From: from, To: to})
+		}
+	}
+}
+
+// SetDepths sets the depth of every dependency from the edges: 1 for what
+// the project requires, 2 for their requirements and so on. Dependencies
+// the edges do not reach keep depth 1 if DIRECT and 0 (unknown) otherwise.
+func (r *Result) SetDepths() {
+	children := make(map[string][]string)
+	for _, e := range r.Edges {
+		children[e.From] = append(children[e.From], e.To)
+	}
+
+	depths := map[string]int{"": 0}
+	queue := []string{""}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		for _, child := range children[key] {
+			if _, ok := depths[child]; !ok {
+				depths[child] = depths[key] + 1
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	for i, dep := range r.Dependencies {
+		if depth, ok := depths[storage.DependencyKey(dep.System, dep.Name, dep.Version)]; ok {
+			r.Dependencies[i].Depth = depth
+		} else if dep.Relation == RelationDirect {
+			r.Dependencies[i].Depth = 1
+		}
+	}
+}
+
+func (r *Result) skip(line int, entry, reason string) {
+	r.Issues = append(r.Issues, Issue{Line: line, Entry: entry, Reason: reason})
+}
@@ -0,0 +1,74 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResult_SetDepths(t *testing.T) {
+	dep := func(name, relation string) storage.Dependency {
+		return storage.Dependency{System: SystemCargo, Name: name, Version: "1.0.0", Relation: relation}
+	}
+
+	result := &Result{}
+	seen := make(map[string]int)
+	for _, d := range []storage.Dependency{
+		dep("serde", RelationDirect),
+		dep("serde_derive", RelationIndirect),
+		dep("syn", RelationIndirect),
+		dep("log", RelationDirect),
+		dep("orphan", RelationIndirect),
+	} {
+		result.add(seen, d)
+	}
+	result.link(storage.Dependency{}, dep("serde", ""))
+	result.link(dep("serde", ""), dep("serde_derive", ""))
+	result.link(dep("serde_derive", ""), dep("syn", ""))
+	// The shorter path through serde wins
+	result.link(dep("serde", ""), dep("syn", ""))
+
+	result.SetDepths()
+
+	depths := make(map[string]int)
+	for _, d := range result.Dependencies {
+		depths[d.Name] = d.Depth
+	}
+	// log has no edges but is DIRECT; orphan is unknown
+	assert.Equal(t, map[string]int{"serde": 1, "serde_derive": 2, "syn": 2, "log": 1, "orphan": 0}, depths)
+}
+
+func TestResult_Canonicalize(t *testing.T) {
+	zope := storage.Dependency{System: "pypi", Name: "Zope.Interface", Version: "5.0", Relation: RelationIndirect}
+	result := &Result{}
+	seen := make(map[string]int)
+	for _, d := range []storage.Dependency{
+		{System: "golang", Name: "github.com/a/b", Version: "1.2.3", Relation: RelationDirect},
+		{System: "go", Name: "github.com/a/b", Version: "1.2.3", Relation: RelationDirect},
+		zope,
+		{System: SystemPyPI, Name: "zope-interface", Version: "5.0", Relation: RelationDirect},
+		{System: SystemNPM, Name: "@scope", Version: "1.0.0", Relation: RelationDirect},
+	} {
+		result.add(seen, d)
+	}
+	result.link(storage.Dependency{}, storage.Dependency{System: "go", Name: "github.com/a/b", Version: "1.2.3"})
+	result.link(storage.Dependency{System: "go", Name: "github.com/a/b", Version: "1.2.3"}, zope)
+
+	result.Canonicalize()
+
+	// Spellings of the same package version are merged, DIRECT winning
+	assert.Equal(t, []storage.Dependency{
+		{System: SystemGo, Name: "github.com/a/b", Version: "v1.2.3", Relation: RelationDirect},
+		{System: SystemPyPI, Name: "zope-interface", Version: "5.0", Relation: RelationDirect},
+	}, result.Dependencies)
+	if assert.Len(t, result.Issues, 2) {
+		// golang is the package URL type, not a system
+		assert.Equal(t, "golang/github.com/a/b@1.2.3", result.Issues[0].Entry)
+		assert.Equal(t, "NPM/@scope@1.0.0", result.Issues[1].Entry)
+	}
+	assert.Equal(t, []Edge{
+		{To: "GO|github.com/a/b|v1.2.3"},
+		{From: "GO|github.com/a/b|v1.2.3", To: "PYPI|zope-interface|5.0"},
+	}, result.Edges)
+}
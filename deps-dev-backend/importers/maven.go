@@ -0,0 +1,201 @@
+package importers
+
+import (
+	"bufio"
+	"bytes"
+	"deps-dev/storage"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type mavenNode struct {
+	GroupID    string      `json:"groupId"`
+	ArtifactID string      `json:"artifactId"`
+	Version    string      `json:"version"`
+	Children   []mavenNode `json:"children"`
+}
+
+// ParseMavenTree reads the output of `mvn dependency:tree`, either the
+// default text format (with or without the [INFO] log prefix, one tree per
+// module) or -DoutputType=json. The modules themselves are left out of the
+// dependencies but kept in the edges; their children are DIRECT, everything
+// below INDIRECT. Artifacts are named group:artifact as on deps.dev.
+func ParseMavenTree(r io.Reader) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dependency tree: %w", err)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return parseMavenJSON(data)
+	}
+	return parseMavenText(data)
+}
+
+// parseMavenJSON reads one tree per module; a reactor build prints them one
+// after another.
+func parseMavenJSON(data []byte) (*Result, error) {
+	var roots []mavenNode
+	modules := make(map[string]bool)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var root mavenNode
+		if err := decoder.Decode(&root); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode dependency tree: %w", err)
+		}
+		if root.ArtifactID == "" {
+			return nil, fmt.Errorf("missing root artifact, not a dependency tree")
+		}
+		roots = append(roots, root)
+		modules[root.GroupID+":"+root.ArtifactID] = true
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("missing root artifact, not a dependency tree")
+	}
+
+	result := &Result{}
+	seen := make(map[string]int)
+
+	var walk func(parent storage.Dependency, nodes []mavenNode, depth int)
+	walk = func(parent storage.Dependency, nodes []mavenNode, depth int) {
+		for _, node := range nodes {
+			dep := mavenDependency(node.GroupID, node.ArtifactID, node.Version, depth)
+			// Other modules are part of the project, see parseMavenText
+			if !modules[dep.Name] {
+				result.add(seen, dep)
+			}
+			result.link(parent, dep)
+			walk(dep, node.Children, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(storage.Dependency{}, root.Children, 1)
+	}
+
+	return result, nil
+}
+
+func parseMavenText(data []byte) (*Result, error) {
+	// Modules of the build, which other modules may depend on whether their
+	// own tree comes before or after
+	modules := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if module, ok := parseMavenModuleLine(scanner.Text()); ok {
+			modules[module.GroupID+":"+module.ArtifactID] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dependency tree: %w", err)
+	}
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("no module found, not a dependency tree")
+	}
+
+	result := &Result{}
+	seen := make(map[string]int)
+
+	// Ancestors of the current line, indexed by depth; [0] is the module
+	var path []storage.Dependency
+
+	lineNum := 0
+	scanner = bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if _, ok := parseMavenModuleLine(line); ok {
+			// A module line starts a new tree
+			path = append(path[:0], storage.Dependency{})
+			continue
+		}
+
+		depth, coords := splitMavenTreeLine(strings.TrimPrefix(line, "[INFO] "))
+		if depth == 0 || len(path) == 0 {
+			// Build log output
+			continue
+		}
+		if depth > len(path) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", lineNum)
+		}
+
+		artifact, ok := parseMavenCoordinates(coords)
+		if !ok {
+			result.skip(lineNum, strings.TrimSpace(coords), "unparseable artifact coordinates")
+			continue
+		}
+
+		dep := mavenDependency(artifact.GroupID, artifact.ArtifactID, artifact.Version, depth)
+		// Another module is part of the project, so it is left out, but its
+		// dependencies hang off it to keep their depth
+		if !modules[dep.Name] {
+			result.add(seen, dep)
+		}
+		result.link(path[depth-1], dep)
+
+		path = append(path[:depth], dep)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dependency tree: %w", err)
+	}
+
+	return result, nil
+}
+
+// parseMavenModuleLine parses the unindented line that starts the tree of a
+// module, e.g. "com.example:app:jar:1.0".
+func parseMavenModuleLine(line string) (mavenNode, bool) {
+	depth, coords := splitMavenTreeLine(strings.TrimPrefix(line, "[INFO] "))
+	if depth != 0 || strings.Contains(coords, " ") {
+		return mavenNode{}, false
+	}
+	return parseMavenCoordinates(coords)
+}
+
+// splitMavenTreeLine splits a tree line into its depth and the coordinates,
+// e.g. "|  \- g:a:jar:1.0:compile" is at depth 2. Lines without a branch
+// marker have depth 0.
+func splitMavenTreeLine(line string) (int, string) {
+	for _, marker := range []string{"+- ", "\\- "} {
+		if i := strings.Index(line, marker); i >= 0 && strings.Trim(line[:i], "| ") == "" {
+			return i/3 + 1, line[i+len(marker):]
+		}
+	}
+	return 0, line
+}
+
+// parseMavenCoordinates parses groupId:artifactId:type[:classifier]:version[:scope],
+// ignoring notes the plugin appends such as " (optional)".
+func parseMavenCoordinates(coords string) (mavenNode, bool) {
+	coords, _, _ = strings.Cut(strings.TrimSpace(coords), " ")
+	parts := strings.Split(coords, ":")
+	for _, part := range parts {
+		if part == "" {
+			return mavenNode{}, false
+		}
+	}
+
+	switch len(parts) {
+	case 4, 5: // g:a:type:version, with the scope on dependencies
+		return mavenNode{GroupID: parts[0], ArtifactID: parts[1], Version: parts[3]}, true
+	case 6: // g:a:type:classifier:version:scope
+		return mavenNode{GroupID: parts[0], ArtifactID: parts[1], Version: parts[4]}, true
+	}
+	return mavenNode{}, false
+}
+
+func mavenDependency(groupID, artifactID, version string, depth int) storage.Dependency {
+	relation := RelationIndirect
+	if depth == 1 {
+		relation = RelationDirect
+	}
+	return storage.Dependency{
+		System:   SystemMaven,
+		Name:     groupID + ":" + artifactID,
+		Version:  version,
+		Relation: relation,
+	}
+}
@@ -0,0 +1,168 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMavenTree_Text(t *testing.T) {
+	f, err := os.Open("testdata/dependency-tree.txt")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParseMavenTree(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "MAVEN", Name: "org.springframework:spring-core", Version: "5.3.29", Relation: "DIRECT"},
+		{System: "MAVEN", Name: "org.springframework:spring-jcl", Version: "5.3.29", Relation: "INDIRECT"},
+		{System: "MAVEN", Name: "io.netty:netty-transport-native-epoll", Version: "4.1.97.Final", Relation: "DIRECT"},
+		{System: "MAVEN", Name: "com.google.guava:guava", Version: "32.1.2-jre", Relation: "DIRECT"},
+		{System: "MAVEN", Name: "com.google.guava:failureaccess", Version: "1.0.1", Relation: "INDIRECT"},
+		{System: "MAVEN", Name: "junit:junit", Version: "4.13.2", Relation: "DIRECT"},
+		{System: "MAVEN", Name: "org.hamcrest:hamcrest-core", Version: "1.3", Relation: "INDIRECT"},
+	}, result.Dependencies)
+
+	assert.Equal(t, []Edge{
+		{To: "MAVEN|org.springframework:spring-core|5.3.29"},
+		{From: "MAVEN|org.springframework:spring-core|5.3.29", To: "MAVEN|org.springframework:spring-jcl|5.3.29"},
+		{To: "MAVEN|io.netty:netty-transport-native-epoll|4.1.97.Final"},
+		{To: "MAVEN|com.google.guava:guava|32.1.2-jre"},
+		{From: "MAVEN|com.google.guava:guava|32.1.2-jre", To: "MAVEN|com.google.guava:failureaccess|1.0.1"},
+		{To: "MAVEN|junit:junit|4.13.2"},
+		{From: "MAVEN|junit:junit|4.13.2", To: "MAVEN|org.hamcrest:hamcrest-core|1.3"},
+		{To: "MAVEN|com.example:app|1.0.0"},
+		{From: "MAVEN|com.example:app|1.0.0", To: "MAVEN|org.springframework:spring-core|5.3.29"},
+	}, result.Edges)
+	assert.Empty(t, result.Issues)
+}
+
+func TestParseMavenTree_SiblingModuleDepths(t *testing.T) {
+	api := `com.example:api:jar:1.0.0
+\- com.google.guava:guava:jar:32.1.2-jre:compile
+`
+	worker := `com.example:worker:jar:1.0.0
++- com.example:api:jar:1.0.0:compile
+|  \- org.slf4j:slf4j-api:jar:2.0.9:compile
+\- junit:junit:jar:4.13.2:test
+`
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "sibling tree first", input: api + "\n" + worker},
+		{name: "sibling tree last", input: worker + "\n" + api},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseMavenTree(strings.NewReader(tt.input))
+			assert.NoError(t, err)
+			result.Canonicalize()
+			result.SetDepths()
+
+			// The sibling module is left out, but what it pulls in is one level down
+			assert.ElementsMatch(t, []storage.Dependency{
+				{System: "MAVEN", Name: "com.google.guava:guava", Version: "32.1.2-jre", Relation: "DIRECT", Depth: 1},
+				{System: "MAVEN", Name: "org.slf4j:slf4j-api", Version: "2.0.9", Relation: "INDIRECT", Depth: 2},
+				{System: "MAVEN", Name: "junit:junit", Version: "4.13.2", Relation: "DIRECT", Depth: 1},
+			}, result.Dependencies)
+		})
+	}
+}
+
+func TestParseMavenTree_JSON(t *testing.T) {
+	input := `{
+		"groupId": "com.example", "artifactId": "app", "version": "1.0.0", "type": "jar", "scope": "",
+		"children": [
+			{
+				"groupId": "org.springframework", "artifactId": "spring-core", "version": "5.3.29", "scope": "compile",
+				"children": [
+					{"groupId": "org.springframework", "artifactId": "spring-jcl", "version": "5.3.29", "scope": "compile"}
+				]
+			},
+			{"groupId": "org.springframework", "artifactId": "spring-jcl", "version": "5.3.29", "scope": "compile"}
+		]
+	}`
+
+	result, err := ParseMavenTree(strings.NewReader(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "MAVEN", Name: "org.springframework:spring-core", Version: "5.3.29", Relation: "DIRECT"},
+		{System: "MAVEN", Name: "org.springframework:spring-jcl", Version: "5.3.29", Relation: "DIRECT"},
+	}, result.Dependencies)
+	assert.Equal(t, []Edge{
+		{To: "MAVEN|org.springframework:spring-core|5.3.29"},
+		{From: "MAVEN|org.springframework:spring-core|5.3.29", To: "MAVEN|org.springframework:spring-jcl|5.3.29"},
+		{To: "MAVEN|org.springframework:spring-jcl|5.3.29"},
+	}, result.Edges)
+}
+
+func TestParseMavenTree_JSONReactor(t *testing.T) {
+	// One document per module, the worker depending on its sibling api
+	input := `{
+		"groupId": "com.example", "artifactId": "worker", "version": "1.0.0",
+		"children": [
+			{
+				"groupId": "com.example", "artifactId": "api", "version": "1.0.0", "scope": "compile",
+				"children": [
+					{"groupId": "org.slf4j", "artifactId": "slf4j-api", "version": "2.0.9", "scope": "compile"}
+				]
+			}
+		]
+	}
+	{
+		"groupId": "com.example", "artifactId": "api", "version": "1.0.0",
+		"children": [
+			{"groupId": "com.google.guava", "artifactId": "guava", "version": "32.1.2-jre", "scope": "compile"}
+		]
+	}`
+
+	result, err := ParseMavenTree(strings.NewReader(input))
+	assert.NoError(t, err)
+	result.Canonicalize()
+	result.SetDepths()
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "MAVEN", Name: "org.slf4j:slf4j-api", Version: "2.0.9", Relation: "INDIRECT", Depth: 2},
+		{System: "MAVEN", Name: "com.google.guava:guava", Version: "32.1.2-jre", Relation: "DIRECT", Depth: 1},
+	}, result.Dependencies)
+}
+
+func TestParseMavenTree_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "invalid JSON",
+			input:         `{"groupId": `,
+			expectedError: "failed to decode dependency tree",
+		},
+		{
+			name:          "build log without tree",
+			input:         "[INFO] BUILD FAILURE\n",
+			expectedError: "no module found, not a dependency tree",
+		},
+		{
+			name:          "skipped level",
+			input:         "com.example:app:jar:1.0.0\n|  \\- junit:junit:jar:4.13.2:test\n",
+			expectedError: "line 2: unexpected indentation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseMavenTree(strings.NewReader(tt.input))
+			assert.Nil(t, result)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type npmLockfile struct {
+	LockfileVersion int                   `json:"lockfileVersion"`
+	Packages        map[string]npmPackage `json:"packages"`
+}
+
+type npmPackage struct {
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	Resolved             string            `json:"resolved"`
+	Link                 bool              `json:"link"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+}
+
+const nodeModules = "node_modules/"
+
+// ParseNpmLockfile reads a package-lock.json (lockfileVersion 2 or 3).
+// Packages installed at the top level and declared by the root project or a
+// workspace are DIRECT, everything else is INDIRECT. The root project itself
+// is not a published package and is left out.
+func ParseNpmLockfile(r io.Reader) (*Result, error) {
+	var lock npmLockfile
+	if err := json.NewDecoder(r).Decode(&lock); err != nil {
+		return nil, fmt.Errorf("failed to decode package-lock.json: %w", err)
+	}
+	if lock.LockfileVersion < 2 || lock.Packages == nil {
+		return nil, fmt.Errorf("unsupported lockfileVersion %d, regenerate it with npm 7 or newer", lock.LockfileVersion)
+	}
+
+	// Names declared by the root project and workspaces, i.e. paths outside node_modules
+	declared := make(map[string]bool)
+	for path, pkg := range lock.Packages {
+		if strings.Contains(path, nodeModules) {
+			continue
+		}
+		for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies, pkg.OptionalDependencies, pkg.PeerDependencies} {
+			for name := range deps {
+				declared[name] = true
+			}
+		}
+	}
+
+	// Map iteration order is random, keep the output stable
+	paths := make([]string, 0, len(lock.Packages))
+	for path := range lock.Packages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	result := &Result{}
+	seen := make(map[string]int)
+	for _, path := range paths {
+		pkg := lock.Packages[path]
+
+		idx := strings.LastIndex(path, nodeModules)
+		if idx < 0 || pkg.Link {
+			// Root project, workspace sources and symlinks to them
+			continue
+		}
+		installedAs := path[idx+len(nodeModules):]
+
+		if pkg.Version == "" {
+			result.skip(0, path, "missing version")
+			continue
+		}
+		if pkg.Resolved != "" && !strings.HasPrefix(pkg.Resolved, "http") {
+			result.skip(0, path, "not installed from a registry: "+pkg.Resolved)
+			continue
+		}
+
+		// Aliased installs (npm:real-name@version) record the real name separately
+		name := pkg.Name
+		if name == "" {
+			name = installedAs
+		}
+
+		relation := RelationIndirect
+		if path == nodeModules+installedAs && declared[installedAs] {
+			relation = RelationDirect
+		}
+
+		result.add(seen, storage.Dependency{
+			System:   SystemNPM,
+			Name:     name,
+			Version:  pkg.Version,
+			Relation: relation,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNpmLockfile(t *testing.T) {
+	f, err := os.Open("testdata/package-lock.json")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParseNpmLockfile(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "NPM", Name: "@babel/core", Version: "7.22.0", Relation: "DIRECT"},
+		{System: "NPM", Name: "clsx", Version: "2.0.0", Relation: "DIRECT"},
+		{System: "NPM", Name: "js-tokens", Version: "4.0.0", Relation: "INDIRECT"},
+		{System: "NPM", Name: "react", Version: "17.0.2", Relation: "DIRECT"},
+		{System: "NPM", Name: "loose-envify", Version: "1.4.0", Relation: "INDIRECT"},
+		{System: "NPM", Name: "react", Version: "18.2.0", Relation: "DIRECT"},
+		{System: "NPM", Name: "js-tokens", Version: "3.0.2", Relation: "INDIRECT"},
+		{System: "NPM", Name: "typescript", Version: "5.1.6", Relation: "DIRECT"},
+	}, result.Dependencies)
+
+	assert.Len(t, result.Issues, 1)
+	assert.Equal(t, "node_modules/private-utils", result.Issues[0].Entry)
+}
+
+func TestParseNpmLockfile_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "invalid JSON",
+			input:         `{"lockfileVersion": 3, "packages": [`,
+			expectedError: "failed to decode package-lock.json",
+		},
+		{
+			name:          "lockfile v1",
+			input:         `{"lockfileVersion": 1, "dependencies": {"react": {"version": "18.2.0"}}}`,
+			expectedError: "unsupported lockfileVersion 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseNpmLockfile(strings.NewReader(tt.input))
+			assert.Nil(t, result)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+func TestParseNpmLockfile_DirectWinsOverIndirect(t *testing.T) {
+	input := `{
+		"lockfileVersion": 2,
+		"packages": {
+			"": {"dependencies": {"ms": "^2.1.0"}},
+			"node_modules/debug/node_modules/ms": {"version": "2.1.2"},
+			"node_modules/ms": {"version": "2.1.2"}
+		}
+	}`
+
+	result, err := ParseNpmLockfile(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Dependency{
+		{System: "NPM", Name: "ms", Version: "2.1.2", Relation: "DIRECT"},
+	}, result.Dependencies)
+}
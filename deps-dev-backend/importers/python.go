@@ -0,0 +1,406 @@
+package importers
+
+import (
+	"bufio"
+	"deps-dev/ecosystem"
+	"deps-dev/storage"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+var (
+	// Distribution name, optional extras, then the version specifiers
+	requirementPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*(.*)$`)
+)
+
+type requirement struct {
+	name    string
+	version string
+	line    int
+	// via lists pip-compile's "# via" annotations for the requirement
+	via []string
+}
+
+// ParseRequirements reads a pinned requirements.txt, e.g. the output of
+// pip-compile or pip freeze. Only exact (== or ===) pins are imported,
+// other requirements are reported as issues. If the file carries
+// pip-compile "# via" annotations, only requirements pulled in by an input
+// file (-r) are DIRECT; otherwise every requirement is.
+func ParseRequirements(r io.Reader) (*Result, error) {
+	result := &Result{}
+
+	var (
+		requirements []*requirement
+		current      *requirement
+		annotated    bool
+		inVia        bool
+	)
+
+	via := func(comment string) {
+		comment = strings.TrimSpace(comment)
+		switch {
+		case current == nil:
+		case comment == "via":
+			inVia, annotated = true, true
+		case strings.HasPrefix(comment, "via "):
+			inVia, annotated = false, true
+			current.via = append(current.via, strings.TrimSpace(strings.TrimPrefix(comment, "via ")))
+		case inVia && comment != "":
+			current.via = append(current.via, comment)
+		default:
+			inVia = false
+		}
+	}
+
+	lines, err := requirementLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range lines {
+		text, comment := splitRequirementComment(l.text)
+		text = strings.TrimSpace(text)
+
+		if text == "" {
+			if comment != "" || l.comment {
+				via(comment)
+			}
+			continue
+		}
+		inVia = false
+
+		if strings.HasPrefix(text, "-") {
+			current = nil
+			if reason := requirementOptionIssue(text); reason != "" {
+				result.skip(l.number, text, reason)
+			}
+			continue
+		}
+
+		req, reason := parseRequirement(text)
+		if reason != "" {
+			current = nil
+			result.skip(l.number, text, reason)
+			continue
+		}
+
+		req.line = l.number
+		requirements = append(requirements, req)
+		current = req
+		if comment != "" {
+			via(comment)
+		}
+	}
+
+	seen := make(map[string]int)
+	for _, req := range requirements {
+		relation := RelationDirect
+		if annotated && !viaInputFile(req.via) {
+			relation = RelationIndirect
+		}
+
+		result.add(seen, storage.Dependency{
+			System:   SystemPyPI,
+			Name:     req.name,
+			Version:  req.version,
+			Relation: relation,
+		})
+	}
+
+	return result, nil
+}
+
+type requirementLine struct {
+	number int
+	text   string
+	// comment is set for lines that only hold a comment
+	comment bool
+}
+
+// requirementLines joins lines continued with a trailing backslash, keeping
+// the number of the first one.
+func requirementLines(r io.Reader) ([]requirementLine, error) {
+	var (
+		lines   []requirementLine
+		pending *requirementLine
+		number  int
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		number++
+		text := scanner.Text()
+
+		if pending == nil {
+			pending = &requirementLine{
+				number:  number,
+				comment: strings.HasPrefix(strings.TrimSpace(text), "#"),
+			}
+		}
+
+		if strings.HasSuffix(text, `\`) && !pending.comment {
+			pending.text += strings.TrimSuffix(text, `\`) + " "
+			continue
+		}
+		pending.text += text
+		lines = append(lines, *pending)
+		pending = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read requirements: %w", err)
+	}
+	if pending != nil {
+		lines = append(lines, *pending)
+	}
+
+	return lines, nil
+}
+
+// splitRequirementComment cuts a comment off a line. As in pip, "#" only
+// starts a comment at the beginning of a line or after whitespace, so URL
+// fragments are kept.
+func splitRequirementComment(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i], line[i+1:]
+		}
+	}
+	return line, ""
+}
+
+// requirementOptionIssue explains why an option line was not imported, or
+// returns "" for options that do not add packages (index URLs, hashes,
+// constraint files and the like).
+func requirementOptionIssue(option string) string {
+	flag, _, _ := strings.Cut(strings.Fields(option)[0], "=")
+	switch flag {
+	case "-r", "--requirement":
+		return "nested requirements files are not followed, upload them separately"
+	case "-e", "--editable":
+		return "editable installs are not published packages"
+	}
+	return ""
+}
+
+func parseRequirement(text string) (*requirement, string) {
+	// Per-requirement options such as --hash come after the specifier
+	if i := strings.Index(text, " --"); i >= 0 {
+		text = text[:i]
+	}
+	spec, _, _ := strings.Cut(text, ";")
+	spec = strings.TrimSpace(spec)
+
+	if strings.Contains(spec, "://") || strings.Contains(spec, " @ ") || strings.HasPrefix(spec, ".") || strings.HasPrefix(spec, "/") {
+		return nil, "not installed from a registry"
+	}
+
+	m := requirementPattern.FindStringSubmatch(spec)
+	if m == nil {
+		return nil, "unparseable requirement"
+	}
+	name, specifiers := m[1], strings.TrimSpace(m[2])
+
+	var version string
+	switch {
+	case strings.HasPrefix(specifiers, "==="):
+		version = strings.TrimSpace(specifiers[3:])
+	case strings.HasPrefix(specifiers, "=="):
+		version = strings.TrimSpace(specifiers[2:])
+	}
+	if version == "" || strings.ContainsAny(version, ",*<>=!~ ") {
+		return nil, "not pinned to an exact version"
+	}
+
+	return &requirement{name: ecosystem.NormalizePyPIName(name), version: version}, ""
+}
+
+// viaInputFile reports whether pip-compile attributed a requirement to an
+// input file, i.e. it was declared by the project rather than pulled in.
+func viaInputFile(via []string) bool {
+	for _, v := range via {
+		if strings.HasPrefix(v, "-r ") {
+			return true
+		}
+	}
+	return false
+}
+
+type poetryLock struct {
+	Package []struct {
+		Name         string         `toml:"name"`
+		Version      string         `toml:"version"`
+		Dependencies map[string]any `toml:"dependencies"`
+		Source       struct {
+			Type string `toml:"type"`
+			URL  string `toml:"url"`
+		} `toml:"source"`
+	} `toml:"package"`
+}
+
+// ParsePoetryLock reads a poetry.lock. The lockfile does not record what the
+// project declares, so packages no other locked package depends on are
+// DIRECT and the rest INDIRECT.
+func ParsePoetryLock(r io.Reader) (*Result, error) {
+	var lock poetryLock
+	meta, err := toml.NewDecoder(r).Decode(&lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse poetry.lock: %w", err)
+	}
+	if !meta.IsDefined("metadata") {
+		return nil, fmt.Errorf("missing [metadata] table, not a poetry.lock")
+	}
+
+	required := make(map[string]bool)
+	for _, pkg := range lock.Package {
+		for name := range pkg.Dependencies {
+			required[ecosystem.NormalizePyPIName(name)] = true
+		}
+	}
+
+	result := &Result{}
+	seen := make(map[string]int)
+	for _, pkg := range lock.Package {
+		name, version := ecosystem.NormalizePyPIName(pkg.Name), pkg.Version
+		entry := strings.TrimSpace(name + " " + version)
+		if name == "" || version == "" {
+			result.skip(0, entry, "missing name or version")
+			continue
+		}
+
+		// Packages from the default or a private index have no source or a "legacy" one
+		if sourceType := pkg.Source.Type; sourceType != "" && sourceType != "legacy" {
+			result.skip(0, entry, "not installed from a registry: "+sourceType+" "+pkg.Source.URL)
+			continue
+		}
+
+		relation := RelationDirect
+		if required[name] {
+			relation = RelationIndirect
+		}
+
+		result.add(seen, storage.Dependency{
+			System:   SystemPyPI,
+			Name:     name,
+			Version:  version,
+			Relation: relation,
+		})
+	}
+
+	return result, nil
+}
+
+type uvLock struct {
+	Package []uvPackage `toml:"package"`
+}
+
+type uvPackage struct {
+	Name                 string                    `toml:"name"`
+	Version              string                    `toml:"version"`
+	Source               uvSource                  `toml:"source"`
+	Dependencies         []uvDependency            `toml:"dependencies"`
+	OptionalDependencies map[string][]uvDependency `toml:"optional-dependencies"`
+	DevDependencies      map[string][]uvDependency `toml:"dev-dependencies"`
+}
+
+type uvSource struct {
+	Registry  string `toml:"registry"`
+	Editable  string `toml:"editable"`
+	Virtual   string `toml:"virtual"`
+	Git       string `toml:"git"`
+	URL       string `toml:"url"`
+	Path      string `toml:"path"`
+	Directory string `toml:"directory"`
+}
+
+type uvDependency struct {
+	Name string `toml:"name"`
+}
+
+// ParseUvLock reads a uv.lock. Packages required by a workspace member
+// (the project itself, in any of its dependency groups) are DIRECT, the
+// rest INDIRECT. Workspace members are not published and are left out.
+func ParseUvLock(r io.Reader) (*Result, error) {
+	var lock uvLock
+	meta, err := toml.NewDecoder(r).Decode(&lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse uv.lock: %w", err)
+	}
+	if !meta.IsDefined("version") {
+		return nil, fmt.Errorf("missing lockfile version, not a uv.lock")
+	}
+
+	direct := make(map[string]bool)
+	for _, pkg := range lock.Package {
+		if !pkg.isWorkspaceMember() {
+			continue
+		}
+		for _, dep := range pkg.allDependencies() {
+			direct[ecosystem.NormalizePyPIName(dep.Name)] = true
+		}
+	}
+
+	result := &Result{}
+	seen := make(map[string]int)
+	for _, pkg := range lock.Package {
+		if pkg.isWorkspaceMember() {
+			continue
+		}
+
+		name, version := ecosystem.NormalizePyPIName(pkg.Name), pkg.Version
+		entry := strings.TrimSpace(name + " " + version)
+		if name == "" || version == "" {
+			result.skip(0, entry, "missing name or version")
+			continue
+		}
+
+		if pkg.Source.Registry == "" {
+			result.skip(0, entry, "not installed from a registry: "+pkg.Source.describe())
+			continue
+		}
+
+		relation := RelationIndirect
+		if direct[name] {
+			relation = RelationDirect
+		}
+
+		result.add(seen, storage.Dependency{
+			System:   SystemPyPI,
+			Name:     name,
+			Version:  version,
+			Relation: relation,
+		})
+	}
+
+	return result, nil
+}
+
+func (p uvPackage) isWorkspaceMember() bool {
+	return p.Source.Editable != "" || p.Source.Virtual != ""
+}
+
+// allDependencies lists the dependencies of a package across its main,
+// optional and development groups.
+func (p uvPackage) allDependencies() []uvDependency {
+	deps := append([]uvDependency(nil), p.Dependencies...)
+	for _, groups := range []map[string][]uvDependency{p.OptionalDependencies, p.DevDependencies} {
+		for _, group := range groups {
+			deps = append(deps, group...)
+		}
+	}
+	return deps
+}
+
+func (s uvSource) describe() string {
+	for _, location := range []struct{ kind, value string }{
+		{"git", s.Git}, {"url", s.URL}, {"path", s.Path}, {"directory", s.Directory},
+	} {
+		if location.value != "" {
+			return location.kind + " " + location.value
+		}
+	}
+	return "unknown source"
+}
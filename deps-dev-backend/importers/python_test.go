@@ -0,0 +1,126 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRequirements(t *testing.T) {
+	f, err := os.Open("testdata/requirements.txt")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParseRequirements(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "PYPI", Name: "certifi", Version: "2023.7.22", Relation: "INDIRECT"},
+		{System: "PYPI", Name: "charset-normalizer", Version: "3.2.0", Relation: "INDIRECT"},
+		{System: "PYPI", Name: "flask", Version: "2.3.2", Relation: "DIRECT"},
+		{System: "PYPI", Name: "idna", Version: "3.4", Relation: "INDIRECT"},
+		{System: "PYPI", Name: "requests", Version: "2.31.0", Relation: "DIRECT"},
+		{System: "PYPI", Name: "zope-interface", Version: "6.0", Relation: "DIRECT"},
+	}, result.Dependencies)
+
+	assert.Equal(t, []Issue{
+		{Line: 23, Entry: "Django>=4.2", Reason: "not pinned to an exact version"},
+		{Line: 24, Entry: "-e ./libs/shared", Reason: "editable installs are not published packages"},
+		{Line: 25, Entry: "-r dev-requirements.txt", Reason: "nested requirements files are not followed, upload them separately"},
+		{Line: 26, Entry: "private-lib @ git+https://github.com/acme/private-lib.git@v1.0.0", Reason: "not installed from a registry"},
+		{Line: 27, Entry: "numpy==1.25.*", Reason: "not pinned to an exact version"},
+	}, result.Issues)
+}
+
+func TestParseRequirements_WithoutAnnotations(t *testing.T) {
+	input := "requests==2.31.0\nurllib3===2.0.4\n\nsix\n"
+
+	result, err := ParseRequirements(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Dependency{
+		{System: "PYPI", Name: "requests", Version: "2.31.0", Relation: "DIRECT"},
+		{System: "PYPI", Name: "urllib3", Version: "2.0.4", Relation: "DIRECT"},
+	}, result.Dependencies)
+	assert.Equal(t, []Issue{
+		{Line: 4, Entry: "six", Reason: "not pinned to an exact version"},
+	}, result.Issues)
+}
+
+func TestParsePoetryLock(t *testing.T) {
+	f, err := os.Open("testdata/poetry.lock")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParsePoetryLock(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "PYPI", Name: "certifi", Version: "2023.7.22", Relation: "INDIRECT"},
+		{System: "PYPI", Name: "flask", Version: "2.3.2", Relation: "DIRECT"},
+		{System: "PYPI", Name: "requests", Version: "2.31.0", Relation: "DIRECT"},
+		{System: "PYPI", Name: "werkzeug", Version: "2.3.6", Relation: "INDIRECT"},
+	}, result.Dependencies)
+
+	assert.Equal(t, []Issue{
+		{Entry: "shared-utils 0.1.0", Reason: "not installed from a registry: directory ../shared-utils"},
+	}, result.Issues)
+}
+
+func TestParseUvLock(t *testing.T) {
+	f, err := os.Open("testdata/uv.lock")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParseUvLock(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "PYPI", Name: "certifi", Version: "2024.2.2", Relation: "INDIRECT"},
+		{System: "PYPI", Name: "pytest", Version: "8.1.1", Relation: "DIRECT"},
+		{System: "PYPI", Name: "requests", Version: "2.31.0", Relation: "DIRECT"},
+	}, result.Dependencies)
+
+	assert.Equal(t, []Issue{
+		{Entry: "rich 13.7.1", Reason: "not installed from a registry: git https://github.com/Textualize/rich?tag=v13.7.1#a1b2c3"},
+	}, result.Issues)
+}
+
+func TestParsePythonLockfiles_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		parse         func(string) (*Result, error)
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "poetry.lock with invalid TOML",
+			parse:         func(s string) (*Result, error) { return ParsePoetryLock(strings.NewReader(s)) },
+			input:         "[[package]]\nname = \"requests\nversion = \"2.31.0\"\n",
+			expectedError: "failed to parse poetry.lock: toml: line 2",
+		},
+		{
+			name:          "poetry.lock without metadata",
+			parse:         func(s string) (*Result, error) { return ParsePoetryLock(strings.NewReader(s)) },
+			input:         "[[package]]\nname = \"requests\"\n",
+			expectedError: "not a poetry.lock",
+		},
+		{
+			name:          "uv.lock without version",
+			parse:         func(s string) (*Result, error) { return ParseUvLock(strings.NewReader(s)) },
+			input:         "[[package]]\nname = \"requests\"\n",
+			expectedError: "not a uv.lock",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.parse(tt.input)
+			assert.Nil(t, result)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
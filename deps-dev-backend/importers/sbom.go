@@ -0,0 +1,245 @@
+package importers
+
+import (
+	"bytes"
+	"deps-dev/purl"
+	"deps-dev/storage"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type cycloneDXDocument struct {
+	BOMFormat string `json:"bomFormat"`
+	Metadata  struct {
+		Component *cycloneDXComponent `json:"component"`
+	} `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+	Dependencies []struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn"`
+	} `json:"dependencies"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef     string               `json:"bom-ref"`
+	Name       string               `json:"name"`
+	Version    string               `json:"version"`
+	PURL       string               `json:"purl"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string   `json:"spdxVersion"`
+	DocumentDescribes []string `json:"documentDescribes"`
+	Packages          []struct {
+		SPDXID       string `json:"SPDXID"`
+		Name         string `json:"name"`
+		VersionInfo  string `json:"versionInfo"`
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+	Relationships []struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	} `json:"relationships"`
+}
+
+// sbomPackage is a package found in an SBOM, keyed by its document reference.
+type sbomPackage struct {
+	ref     string
+	entry   string
+	purl    string
+	version string
+}
+
+// ParseSBOM reads a CycloneDX or SPDX JSON document, e.g. one syft produced
+// for a container image. Packages are mapped to deps.dev systems through
+// their package URLs; packages without one or of a type deps.dev does not
+// cover (OS packages, binaries) are reported as issues. Packages the
+// document's subject depends on are DIRECT, the rest INDIRECT; if the
+// document does not record what the subject depends on, all are DIRECT.
+func ParseSBOM(r io.Reader) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM: %w", err)
+	}
+
+	var format struct {
+		BOMFormat   string `json:"bomFormat"`
+		SPDXVersion string `json:"spdxVersion"`
+	}
+	if err := json.Unmarshal(data, &format); err != nil {
+		return nil, fmt.Errorf("failed to decode SBOM: %w", err)
+	}
+
+	switch {
+	case format.BOMFormat == "CycloneDX":
+		return parseCycloneDX(data)
+	case format.SPDXVersion != "":
+		return parseSPDX(data)
+	}
+	return nil, fmt.Errorf("unknown SBOM format, expected CycloneDX or SPDX JSON")
+}
+
+func parseCycloneDX(data []byte) (*Result, error) {
+	var doc cycloneDXDocument
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode CycloneDX document: %w", err)
+	}
+
+	var packages []sbomPackage
+	var collect func(components []cycloneDXComponent)
+	collect = func(components []cycloneDXComponent) {
+		for _, c := range components {
+			packages = append(packages, sbomPackage{
+				ref:     c.BOMRef,
+				entry:   sbomEntry(c.Name, c.Version),
+				purl:    c.PURL,
+				version: c.Version,
+			})
+			collect(c.Components)
+		}
+	}
+	collect(doc.Components)
+
+	edges := make(map[string][]string)
+	for _, dep := range doc.Dependencies {
+		edges[dep.Ref] = append(edges[dep.Ref], dep.DependsOn...)
+	}
+
+	var roots []string
+	if doc.Metadata.Component != nil {
+		roots = append(roots, doc.Metadata.Component.BOMRef)
+	}
+
+	return sbomResult(packages, roots, edges), nil
+}
+
+func parseSPDX(data []byte) (*Result, error) {
+	var doc spdxDocument
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode SPDX document: %w", err)
+	}
+
+	roots := append([]string{}, doc.DocumentDescribes...)
+	edges := make(map[string][]string)
+	for _, rel := range doc.Relationships {
+		switch rel.RelationshipType {
+		case "DESCRIBES":
+			if rel.SPDXElementID == "SPDXRef-DOCUMENT" {
+				roots = append(roots, rel.RelatedSPDXElement)
+			}
+		case "DEPENDS_ON", "CONTAINS":
+			edges[rel.SPDXElementID] = append(edges[rel.SPDXElementID], rel.RelatedSPDXElement)
+		case "DEPENDENCY_OF":
+			edges[rel.RelatedSPDXElement] = append(edges[rel.RelatedSPDXElement], rel.SPDXElementID)
+		}
+	}
+
+	isRoot := make(map[string]bool)
+	for _, root := range roots {
+		isRoot[root] = true
+	}
+
+	var packages []sbomPackage
+	for _, p := range doc.Packages {
+		if isRoot[p.SPDXID] {
+			// The subject of the document, e.g. the scanned image
+			continue
+		}
+
+		pkg := sbomPackage{ref: p.SPDXID, entry: sbomEntry(p.Name, p.VersionInfo), version: p.VersionInfo}
+		for _, ref := range p.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				pkg.purl = ref.ReferenceLocator
+				break
+			}
+		}
+		packages = append(packages, pkg)
+	}
+
+	return sbomResult(packages, roots, edges), nil
+}
+
+// sbomResult maps SBOM packages to dependencies and their references to
+// edges of the dependency graph.
+func sbomResult(packages []sbomPackage, roots []string, edges map[string][]string) *Result {
+	result := &Result{}
+	seen := make(map[string]int)
+
+	direct := make(map[string]bool)
+	for _, root := range roots {
+		for _, ref := range edges[root] {
+			direct[ref] = true
+		}
+	}
+
+	deps := make(map[string]storage.Dependency)
+	for _, pkg := range packages {
+		if pkg.purl == "" {
+			result.skip(0, pkg.entry, "no package URL")
+			continue
+		}
+
+		p, err := purl.Parse(pkg.purl)
+		if err != nil {
+			result.skip(0, pkg.entry, err.Error())
+			continue
+		}
+		system, name, ok := p.DepsDev()
+		if !ok {
+			result.skip(0, pkg.purl, "package type "+p.Type+" is not covered by deps.dev")
+			continue
+		}
+
+		version := p.Version
+		if version == "" {
+			version = pkg.version
+		}
+		if version == "" {
+			result.skip(0, pkg.purl, "missing version")
+			continue
+		}
+
+		dep := storage.Dependency{System: system, Name: name, Version: version, Relation: RelationIndirect}
+		if len(direct) == 0 || direct[pkg.ref] {
+			dep.Relation = RelationDirect
+		}
+		result.add(seen, dep)
+		if pkg.ref != "" {
+			deps[pkg.ref] = dep
+		}
+	}
+
+	for _, root := range roots {
+		for _, ref := range edges[root] {
+			if to, ok := deps[ref]; ok {
+				result.link(storage.Dependency{}, to)
+			}
+		}
+	}
+	for _, pkg := range packages {
+		from, ok := deps[pkg.ref]
+		if !ok {
+			continue
+		}
+		for _, ref := range edges[pkg.ref] {
+			if to, ok := deps[ref]; ok {
+				result.link(from, to)
+			}
+		}
+	}
+
+	return result
+}
+
+func sbomEntry(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + "@" + version
+}
@@ -0,0 +1,107 @@
+package importers
+
+import (
+	"deps-dev/storage"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSBOM_CycloneDX(t *testing.T) {
+	f, err := os.Open("testdata/bom.cdx.json")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParseSBOM(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "NPM", Name: "express", Version: "4.18.2", Relation: "DIRECT"},
+		{System: "NPM", Name: "@types/node", Version: "20.4.0", Relation: "INDIRECT"},
+		{System: "MAVEN", Name: "org.springframework:spring-core", Version: "5.3.29", Relation: "DIRECT"},
+		{System: "MAVEN", Name: "org.springframework:spring-jcl", Version: "5.3.29", Relation: "INDIRECT"},
+	}, result.Dependencies)
+
+	assert.Equal(t, []Edge{
+		{To: "NPM|express|4.18.2"},
+		{To: "MAVEN|org.springframework:spring-core|5.3.29"},
+		{From: "MAVEN|org.springframework:spring-core|5.3.29", To: "MAVEN|org.springframework:spring-jcl|5.3.29"},
+	}, result.Edges)
+
+	assert.Equal(t, []Issue{
+		{Entry: "pkg:deb/debian/curl@7.88.1-10?arch=amd64", Reason: "package type deb is not covered by deps.dev"},
+		{Entry: "node@20.4.0", Reason: "no package URL"},
+	}, result.Issues)
+}
+
+func TestParseSBOM_SPDX(t *testing.T) {
+	f, err := os.Open("testdata/sbom.spdx.json")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParseSBOM(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []storage.Dependency{
+		{System: "PYPI", Name: "requests", Version: "2.31.0", Relation: "DIRECT"},
+		{System: "PYPI", Name: "urllib3", Version: "2.0.4", Relation: "INDIRECT"},
+	}, result.Dependencies)
+	assert.Equal(t, []Edge{
+		{To: "PYPI|requests|2.31.0"},
+		{From: "PYPI|requests|2.31.0", To: "PYPI|urllib3|2.0.4"},
+	}, result.Edges)
+	assert.Equal(t, []Issue{
+		{Entry: "libssl.so.3", Reason: "no package URL"},
+	}, result.Issues)
+}
+
+func TestParseSBOM_WithoutDependencyInfo(t *testing.T) {
+	input := `{"bomFormat": "CycloneDX", "components": [
+		{"name": "react", "version": "18.2.0", "purl": "pkg:npm/react@18.2.0"},
+		{"name": "left-pad", "purl": "pkg:npm/left-pad"}
+	]}`
+
+	result, err := ParseSBOM(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Dependency{
+		{System: "NPM", Name: "react", Version: "18.2.0", Relation: "DIRECT"},
+	}, result.Dependencies)
+	assert.Equal(t, []Issue{
+		{Entry: "pkg:npm/left-pad", Reason: "missing version"},
+	}, result.Issues)
+}
+
+func TestParseSBOM_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "invalid JSON",
+			input:         `{"bomFormat": `,
+			expectedError: "failed to decode SBOM",
+		},
+		{
+			name:          "unknown format",
+			input:         `{"lockfileVersion": 3}`,
+			expectedError: "unknown SBOM format",
+		},
+		{
+			name:          "CycloneDX with wrong types",
+			input:         `{"bomFormat": "CycloneDX", "components": {}}`,
+			expectedError: "failed to decode CycloneDX document",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseSBOM(strings.NewReader(tt.input))
+			assert.Nil(t, result)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+module example.com/app
+
+go 1.21
+
+require (
+	github.com/go-chi/chi/v5 v5.0.10
+	github.com/sirupsen/logrus v1.9.3
+	example.com/internal/tools v0.1.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect; used by logrus
+)
+
+require github.com/stretchr/testify v1.8.4
+
+replace example.com/internal/tools => ../tools
+
+replace github.com/davecgh/go-spew v1.1.1 => github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc
+
+exclude github.com/sirupsen/logrus v1.9.0
+
+retract (
+	v1.0.0 // published by mistake
+)
@@ -2,15 +2,21 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"deps-dev/config"
 	"deps-dev/data"
 	"deps-dev/depsdev"
 	"deps-dev/handlers"
+	"deps-dev/policy"
+	"deps-dev/providers"
 	"deps-dev/storage"
 
 	"github.com/go-chi/chi/middleware"
@@ -18,63 +24,125 @@ import (
 	"github.com/go-chi/cors"
 
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
 )
 
-func main() {
-	logger := logrus.New()
-	logger.SetOutput(os.Stdout)
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp:   true,
-		TimestampFormat: "2006-01-02 15:04:05",
-		ForceColors:     true,
-		DisableQuote:    true,
-		PadLevelText:    true,
-	})
+const (
+	// Startup database work other than migrations and the startup refresh
+	startupTimeout   = 5 * time.Second
+	migrationTimeout = 10 * time.Minute
+)
 
-	sqlitePath := os.Getenv("SQLITE_PATH")
-	if sqlitePath == "" {
-		sqlitePath = "./data/app.db"
+func main() {
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"), os.Getenv)
+	if err != nil {
+		logrus.Fatal(err)
 	}
 
-	db, err := sql.Open("sqlite3", sqlitePath)
+	logger := newLogger(cfg.Log)
+
+	store, err := storage.Open(cfg.Database.Path, cfg.Database.ReadConns, int(cfg.Database.BusyTimeout.Milliseconds()))
 	if err != nil {
 		logger.Fatalf("failed to open DB: %v", err)
 	}
-	defer db.Close()
-	db.SetMaxOpenConns(1)
+	defer store.Close()
 
-	store := &storage.Storage{DB: db}
+	// Migrations rewrite whole tables, which takes a while on a large database
+	migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), migrationTimeout)
+	err = store.InitSchema(migrateCtx)
+	cancelMigrate()
+	if err != nil {
+		logger.Fatalf("failed to initialize schema: %v", err)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
 	defer cancel()
+	if interrupted, err := store.FailInterruptedRefreshRuns(ctx); err != nil {
+		logger.Fatalf("failed to recover interrupted refresh runs: %v", err)
+	} else if interrupted > 0 {
+		logger.Infof("Marked %d interrupted refresh runs as failed", interrupted)
+	}
 
-	if err := store.InitSchema(ctx); err != nil {
-		logger.Fatalf("failed to initialize schema: %v", err)
+	transport, err := depsdev.NewTransport(cfg.DepsDev.Mode, cfg.DepsDev.Fixtures, http.DefaultTransport)
+	if err != nil {
+		logger.Fatalf("invalid deps.dev mode: %v", err)
+	}
+	if cfg.DepsDev.Mode == depsdev.ModeRecord || cfg.DepsDev.Mode == depsdev.ModeReplay {
+		logger.Infof("deps.dev client in %s mode, fixtures in %s", cfg.DepsDev.Mode, cfg.DepsDev.Fixtures)
 	}
 
 	client := &depsdev.DepsDevClient{
-		BaseURL:    config.BaseURL,
-		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		BaseURL:    strings.TrimSuffix(cfg.DepsDev.BaseURL, "/"),
+		HTTPClient: &http.Client{Timeout: cfg.DepsDev.Timeout, Transport: transport},
+	}
+
+	chain, err := metadataChain(cfg.Metadata, cfg.DepsDev.Mode, client, store)
+	if err != nil {
+		logger.Fatalf("invalid metadata providers: %v", err)
+	}
+	chain.Log = logger
+
+	evaluator := &policy.Evaluator{
+		Store: store,
+		File:  cfg.Policy.File,
+		Log:   logger,
 	}
 
 	dm := &data.DataManager{
 		Store:         store,
-		API:           client,
+		API:           chain,
 		Log:           logger,
-		MaxConcurrent: config.DefaultMaxConcurrent,
+		MaxConcurrent: cfg.Refresh.MaxConcurrent,
+		BatchSize:     cfg.Refresh.BatchSize,
+		ResumeWindow:  cfg.Refresh.ResumeWindow,
+		RetryDelay:    cfg.Retry.BaseBackoff,
+		MetadataTTL:   cfg.Refresh.MetadataTTL,
+		ScoreTTL:      cfg.Refresh.ScoreTTL,
+		Policies:      evaluator,
+	}
+
+	retrier := &data.RetryWorker{
+		Store:       store,
+		API:         chain,
+		Log:         logger,
+		Interval:    cfg.Retry.Interval,
+		BatchSize:   cfg.Retry.BatchSize,
+		MaxAttempts: cfg.Retry.MaxAttempts,
+		BaseBackoff: cfg.Retry.BaseBackoff,
+		MaxBackoff:  cfg.Retry.MaxBackoff,
+		Policies:    evaluator,
+	}
+	// Stopped before the store is closed, so no retry is left writing to it
+	retryCtx, stopRetrier := context.WithCancel(context.Background())
+	retrierDone := make(chan struct{})
+	go func() {
+		defer close(retrierDone)
+		retrier.Run(retryCtx)
+	}()
+	defer func() {
+		stopRetrier()
+		<-retrierDone
+	}()
+
+	scheduler := &data.Scheduler{
+		Store:     store,
+		Refresher: dm,
+		Log:       logger,
 	}
 
+	root := cfg.Refresh.Root
 	handler := &handlers.Handler{
 		Store:       store,
 		DataManager: dm,
+		Policies:    evaluator,
+		Scheduler:   scheduler,
 		Log:         logger,
+		Root:        root,
 	}
 
 	r := chi.NewRouter()
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5173"},
+		AllowedOrigins:   cfg.Server.CORSOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
@@ -85,38 +153,143 @@ func main() {
 
 	r.Get("/dependencies", handler.ListDependencies)
 	r.Post("/dependencies", handler.CreateDependency)
-	r.Get("/dependencies/{system}/{name}/{version}", handler.GetDependency)
-	r.Put("/dependencies/{system}/{name}/{version}", handler.UpdateDependency)
-	r.Delete("/dependencies/{system}/{name}/{version}", handler.DeleteDependency)
+	r.Get("/dependencies/purl", handler.GetDependencyByPURL)
+	r.Get(handlers.DependencyRoute, handler.GetDependency)
+	r.Put(handlers.DependencyRoute, handler.UpdateDependency)
+	r.Delete(handlers.DependencyRoute, handler.DeleteDependency)
 	r.Post("/dependencies/refresh", handler.RefreshHandler)
+	r.Get("/refresh/failures", handler.ListFetchFailures)
+	r.Post("/imports/npm-lockfile", handler.ImportNpmLockfile)
+	r.Post("/imports/go-mod", handler.ImportGoModules)
+	r.Post("/imports/requirements-txt", handler.ImportRequirements)
+	r.Post("/imports/poetry-lock", handler.ImportPoetryLock)
+	r.Post("/imports/uv-lock", handler.ImportUvLock)
+	r.Post("/imports/cargo-lock", handler.ImportCargoLock)
+	r.Post("/imports/maven-tree", handler.ImportMavenTree)
+	r.Post("/imports/sbom", handler.ImportSBOM)
+	r.Post("/imports/dataset", handler.ImportDataset)
+	r.Get("/export/cyclonedx", handler.ExportCycloneDX)
+	r.Get("/export/spdx", handler.ExportSPDX)
+	r.Post("/check", handler.Check)
+	r.Get("/violations", handler.ListViolations)
+	r.Get("/policy", handler.GetPolicy)
+	r.Put("/policy", handler.UpdatePolicy)
+	r.Get("/waivers", handler.ListWaivers)
+	r.Post("/waivers", handler.CreateWaiver)
+	r.Get("/waivers/{id}", handler.GetWaiver)
+	r.Put("/waivers/{id}", handler.UpdateWaiver)
+	r.Delete("/waivers/{id}", handler.DeleteWaiver)
+	r.Get("/schedules", handler.ListSchedules)
+	r.Post("/schedules", handler.CreateSchedule)
+	r.Get("/schedules/{id}", handler.GetSchedule)
+	r.Put("/schedules/{id}", handler.UpdateSchedule)
+	r.Delete("/schedules/{id}", handler.DeleteSchedule)
 
-	if os.Getenv("WITH_INITIAL_DATA_REFRESH") == "true" {
-		if err := dm.RefreshDependencies(ctx, config.DefaultSystem, config.DefaultPackage, config.DefaultVersion); err != nil {
+	// Interrupting the startup refresh shuts down right away
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	if cfg.Refresh.OnStartup {
+		if err := dm.RefreshDependencies(signals, root.System, root.Name, root.Version, data.RefreshOptions{}); err != nil {
+			if signals.Err() != nil {
+				logger.Info("startup refresh interrupted, shutting down...")
+				return
+			}
 			logger.Fatalf("failed to refresh dependencies: %v", err)
 		}
 	}
 
-	if os.Getenv("WITH_DAILY_DATA_REFRESH") == "true" {
-		c := cron.New()
-		_, err := c.AddFunc("0 0 * * *", func() {
-			logger.Info("Scheduled refresh triggered")
-			ctx := context.Background()
-			if err := dm.RefreshDependencies(ctx, config.DefaultSystem, config.DefaultPackage, config.DefaultVersion); err != nil {
-				logger.Errorf("scheduled refresh failed: %v", err)
-			}
+	// The startup refresh may have outlasted the first deadline
+	ctx, cancel = context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+	if err := data.ConfigureRootSchedule(ctx, store, logger, root.System, root.Name, root.Version, cfg.Refresh.Schedule, cfg.Refresh.Scheduled); err != nil {
+		logger.Fatalf("failed to schedule refresh: %v", err)
+	}
+	if err := scheduler.Start(ctx); err != nil {
+		logger.Fatalf("failed to start refresh scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+
+	server := &http.Server{Addr: fmt.Sprintf(":%d", cfg.Server.Port), Handler: r}
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Infof("starting on port %d...", cfg.Server.Port)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		logger.Fatal(err)
+	case <-signals.Done():
+	}
+
+	// Finish requests in flight, then the deferred stops run before the
+	// store is closed
+	logger.Info("shutting down...")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.WithError(err).Error("failed to shut down HTTP server")
+	}
+}
+
+// newLogger writes to stdout as colored text or as JSON lines.
+func newLogger(cfg config.Log) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	if cfg.Format == config.LogJSON {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	} else {
+		logger.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: "2006-01-02 15:04:05",
+			ForceColors:     true,
+			DisableQuote:    true,
+			PadLevelText:    true,
 		})
-		if err != nil {
-			logger.Fatalf("failed to schedule cron: %v", err)
-		}
-		c.Start()
 	}
+	// Validated by config.Load
+	level, _ := logrus.ParseLevel(cfg.Level)
+	logger.SetLevel(level)
+	return logger
+}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// metadataChain builds the metadata provider chain from the configured
+// provider names in priority order.
+func metadataChain(cfg config.Metadata, mode string, client *depsdev.DepsDevClient, store *storage.Storage) (*providers.Chain, error) {
+	chain := &providers.Chain{}
+	for _, name := range cfg.Providers {
+		var source providers.Source
+		switch name {
+		case providers.DepsDev:
+			source = client
+		case providers.Mirror:
+			source = &depsdev.DepsDevClient{BaseURL: strings.TrimSuffix(cfg.MirrorURL, "/"), HTTPClient: client.HTTPClient}
+		case providers.Dataset:
+			dataset, err := providers.LoadDataset(cfg.Dataset)
+			if err != nil {
+				return nil, fmt.Errorf("loading %s: %w", cfg.Dataset, err)
+			}
+			source = dataset
+		case providers.Imported:
+			source = &providers.ImportedDataset{Store: store}
+		default:
+			return nil, fmt.Errorf("unknown provider %q", name)
+		}
+		chain.Providers = append(chain.Providers, providers.Provider{Name: name, Source: source})
+		// Graphs come from the first provider that has them
+		if graphs, ok := source.(providers.GraphSource); ok && chain.Graphs == nil && providers.ServesGraphs(name) {
+			chain.Graphs = graphs
+		}
 	}
-	logger.Infof("starting on port %s...", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		logger.Fatal(err)
+
+	// Without deps.dev or a mirror, refreshes stay offline
+	if chain.Graphs == nil {
+		if mode == depsdev.ModeReplay {
+			chain.Graphs = client
+		} else {
+			chain.Graphs = providers.NoGraphs{}
+		}
 	}
+	return chain, nil
 }
@@ -0,0 +1,119 @@
+package policy
+
+import (
+	"context"
+	"deps-dev/storage"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Where the active policy comes from
+const (
+	SourceFile     = "file"
+	SourceDatabase = "database"
+)
+
+// ErrReadOnly is returned when saving a policy while a policy file is in use.
+var ErrReadOnly = errors.New("policy is loaded from a file")
+
+// ErrInvalid is returned when saving a policy that fails to parse.
+var ErrInvalid = errors.New("invalid policy")
+
+type Store interface {
+	ForEachDependency(ctx context.Context, name string, minScore *float64, fn func(storage.Dependency) error) error
+	GetPolicyDocument(ctx context.Context) (string, error)
+	SavePolicyDocument(ctx context.Context, document string) error
+	ReplacePolicyViolations(ctx context.Context, violations []storage.PolicyViolation, now time.Time) error
+	ListWaivers(ctx context.Context) ([]storage.Waiver, error)
+}
+
+// Evaluator checks every stored dependency against the active policy and
+// stores the violations found.
+type Evaluator struct {
+	Store Store
+	// File, if set, is a YAML policy file used instead of the policy stored
+	// in the database. It is read on every evaluation, so edits apply
+	// without a restart.
+	File string
+	Log  *logrus.Logger
+
+	// Serializes evaluations, so an older one cannot overwrite a newer result
+	mu sync.Mutex
+}
+
+// Load returns the active policy and where it came from.
+func (e *Evaluator) Load(ctx context.Context) (Policy, string, error) {
+	if e.File != "" {
+		data, err := os.ReadFile(e.File)
+		if err != nil {
+			return Policy{}, SourceFile, fmt.Errorf("failed to read policy file: %w", err)
+		}
+		p, err := Parse(data)
+		return p, SourceFile, err
+	}
+
+	document, err := e.Store.GetPolicyDocument(ctx)
+	if err != nil {
+		return Policy{}, SourceDatabase, err
+	}
+	p, err := Parse([]byte(document))
+	return p, SourceDatabase, err
+}
+
+// Save validates and stores a policy document in the database.
+func (e *Evaluator) Save(ctx context.Context, document []byte) (Policy, error) {
+	if e.File != "" {
+		return Policy{}, ErrReadOnly
+	}
+
+	p, err := Parse(document)
+	if err != nil {
+		return Policy{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := e.Store.SavePolicyDocument(ctx, string(document)); err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+// Evaluate checks all stored dependencies and replaces the stored
+// violations with those found. It returns how many are not waived.
+func (e *Evaluator) Evaluate(ctx context.Context) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	p, source, err := e.Load(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("loading %s policy: %w", source, err)
+	}
+
+	var violations []storage.PolicyViolation
+	err = e.Store.ForEachDependency(ctx, "", nil, func(dep storage.Dependency) error {
+		violations = append(violations, p.Check(dep)...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Waived violations are stored too, and waivers applied when they are
+	// read, so they resurface as soon as their waiver lapses
+	waivers, err := e.Store.ListWaivers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	waived := ApplyWaivers(violations, waivers, now)
+
+	if err := e.Store.ReplacePolicyViolations(ctx, violations, now); err != nil {
+		return 0, err
+	}
+
+	e.Log.WithField("source", source).Infof("Policy evaluation found %d violations of %d rules, %d of them waived", len(violations), len(p.Rules), waived)
+	return len(violations) - waived, nil
+}
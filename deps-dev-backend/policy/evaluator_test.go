@@ -0,0 +1,149 @@
+package policy
+
+import (
+	"context"
+	"deps-dev/storage"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStore struct {
+	Deps     []storage.Dependency
+	Document string
+	Saved    *string
+	Replaced []storage.PolicyViolation
+	Waivers  []storage.Waiver
+}
+
+func (m *mockStore) ForEachDependency(ctx context.Context, name string, minScore *float64, fn func(storage.Dependency) error) error {
+	for _, dep := range m.Deps {
+		if err := fn(dep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (m *mockStore) GetPolicyDocument(ctx context.Context) (string, error) {
+	return m.Document, nil
+}
+func (m *mockStore) SavePolicyDocument(ctx context.Context, document string) error {
+	m.Saved = &document
+	m.Document = document
+	return nil
+}
+func (m *mockStore) ReplacePolicyViolations(ctx context.Context, violations []storage.PolicyViolation, now time.Time) error {
+	m.Replaced = violations
+	return nil
+}
+func (m *mockStore) ListWaivers(ctx context.Context) ([]storage.Waiver, error) {
+	return m.Waivers, nil
+}
+
+var testDeps = []storage.Dependency{
+	{System: "NPM", Name: "react", Version: "18.2.0", Relation: "SELF"},
+	{System: "NPM", Name: "loose-envify", Version: "1.4.0", Relation: "DIRECT", SourceRepo: "github.com/zertosh/loose-envify"},
+	{System: "NPM", Name: "js-tokens", Version: "4.0.0", Relation: "INDIRECT"},
+}
+
+func TestEvaluator_DatabasePolicy(t *testing.T) {
+	store := &mockStore{Deps: testDeps}
+	e := &Evaluator{Store: store, Log: logrus.New()}
+
+	// Without a policy, nothing is violated
+	n, err := e.Evaluate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Empty(t, store.Replaced)
+
+	p, err := e.Save(context.Background(), []byte("rules:\n  - {name: repo, type: require_source_repo}"))
+	assert.NoError(t, err)
+	assert.Len(t, p.Rules, 1)
+
+	n, err = e.Evaluate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	if assert.Len(t, store.Replaced, 1) {
+		assert.Equal(t, "repo", store.Replaced[0].Rule)
+		assert.Equal(t, "js-tokens", store.Replaced[0].Dependency.Name)
+	}
+
+	_, source, err := e.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, SourceDatabase, source)
+}
+
+func TestEvaluator_SaveRejectsInvalidPolicy(t *testing.T) {
+	store := &mockStore{}
+	e := &Evaluator{Store: store, Log: logrus.New()}
+
+	_, err := e.Save(context.Background(), []byte("rules:\n  - {name: a, type: max_age}"))
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.Contains(t, err.Error(), `invalid policy: rule a: unknown type "max_age"`)
+	assert.Nil(t, store.Saved)
+}
+
+func TestEvaluator_FilePolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("rules:\n  - {name: depth, type: max_depth, max_depth: 1}"), 0o644))
+
+	// The stored policy is ignored while a file is configured
+	store := &mockStore{Deps: []storage.Dependency{
+		{System: "NPM", Name: "js-tokens", Version: "4.0.0", Relation: "INDIRECT", Depth: 2},
+	}, Document: "rules:\n  - {name: repo, type: require_source_repo}"}
+	e := &Evaluator{Store: store, File: path, Log: logrus.New()}
+
+	n, err := e.Evaluate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "depth", store.Replaced[0].Rule)
+
+	_, err = e.Save(context.Background(), []byte("rules: []"))
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, source, err := e.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, SourceFile, source)
+}
+
+func TestEvaluator_InvalidFileKeepsViolations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("rules: ["), 0o644))
+
+	store := &mockStore{Deps: testDeps}
+	e := &Evaluator{Store: store, File: path, Log: logrus.New()}
+
+	_, err := e.Evaluate(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "loading file policy")
+	assert.Nil(t, store.Replaced)
+}
+
+func TestEvaluator_Waivers(t *testing.T) {
+	store := &mockStore{
+		Deps:     testDeps,
+		Document: "rules:\n  - {name: repo, type: require_source_repo}",
+		Waivers: []storage.Waiver{
+			{ID: 1, Rule: "repo", System: "NPM", Name: "js-tokens", ExpiresAt: time.Now().Add(time.Hour)},
+		},
+	}
+	e := &Evaluator{Store: store, Log: logrus.New()}
+
+	n, err := e.Evaluate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	// Waived violations are still stored
+	if assert.Len(t, store.Replaced, 1) {
+		assert.Equal(t, int64(1), *store.Replaced[0].WaiverID)
+	}
+
+	store.Waivers[0].ExpiresAt = time.Now().Add(-time.Hour)
+	n, err = e.Evaluate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Nil(t, store.Replaced[0].WaiverID)
+}
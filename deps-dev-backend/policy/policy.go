@@ -0,0 +1,183 @@
+// Package policy checks stored dependencies against rules such as a minimum
+// OpenSSF score for direct dependencies or a list of denied packages.
+package policy
+
+import (
+	"bytes"
+	"deps-dev/ecosystem"
+	"deps-dev/storage"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule types
+const (
+	RuleMinScore          = "min_score"
+	RuleDeny              = "deny"
+	RuleMaxDepth          = "max_depth"
+	RuleRequireSourceRepo = "require_source_repo"
+)
+
+// Policy is a set of rules, written as YAML or JSON:
+//
+//	rules:
+//	  - name: direct-min-score
+//	    type: min_score
+//	    relation: DIRECT
+//	    min_score: 5
+type Policy struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+type Rule struct {
+	// Name identifies the rule in violations
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"`
+	// Relation limits the rule to DIRECT or INDIRECT dependencies
+	Relation string `json:"relation,omitempty" yaml:"relation,omitempty"`
+
+	// MinScore is the lowest OpenSSF score min_score accepts. Unscored
+	// dependencies violate it unless AllowUnscored is set.
+	MinScore      *float64 `json:"min_score,omitempty" yaml:"min_score,omitempty"`
+	AllowUnscored bool     `json:"allow_unscored,omitempty" yaml:"allow_unscored,omitempty"`
+	// MaxDepth is the deepest max_depth accepts, 1 being DIRECT dependencies
+	MaxDepth int `json:"max_depth,omitempty" yaml:"max_depth,omitempty"`
+	// Packages are the packages deny rejects
+	Packages []Package `json:"packages,omitempty" yaml:"packages,omitempty"`
+}
+
+// Package names a denied package, or one version of it.
+type Package struct {
+	System  string `json:"system" yaml:"system"`
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// Reason is shown in violations, e.g. "compromised in CVE-2018-1000620"
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Parse reads a YAML or JSON policy and validates it. Relations and denied
+// packages are canonicalized. An empty document is a policy without rules.
+func Parse(data []byte) (Policy, error) {
+	var p Policy
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return Policy{}, fmt.Errorf("failed to decode policy: %w", err)
+	}
+
+	if err := p.normalize(); err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+func (p *Policy) normalize() error {
+	names := make(map[string]bool)
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("rule %d: missing name", i+1)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rule %s: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+
+		r.Relation = strings.ToUpper(r.Relation)
+		if r.Relation != "" && r.Relation != "DIRECT" && r.Relation != "INDIRECT" {
+			return fmt.Errorf("rule %s: relation must be DIRECT or INDIRECT", r.Name)
+		}
+
+		switch r.Type {
+		case RuleMinScore:
+			if r.MinScore == nil || *r.MinScore < 0 || *r.MinScore > 10 {
+				return fmt.Errorf("rule %s: min_score must be between 0 and 10", r.Name)
+			}
+		case RuleMaxDepth:
+			if r.MaxDepth < 1 {
+				return fmt.Errorf("rule %s: max_depth must be at least 1", r.Name)
+			}
+		case RuleDeny:
+			if len(r.Packages) == 0 {
+				return fmt.Errorf("rule %s: no packages to deny", r.Name)
+			}
+			for j, pkg := range r.Packages {
+				system, name, version, err := ecosystem.Canonicalize(pkg.System, pkg.Name, pkg.Version)
+				if err != nil {
+					return fmt.Errorf("rule %s: %w", r.Name, err)
+				}
+				r.Packages[j].System, r.Packages[j].Name, r.Packages[j].Version = system, name, version
+			}
+		case RuleRequireSourceRepo:
+		default:
+			return fmt.Errorf("rule %s: unknown type %q", r.Name, r.Type)
+		}
+	}
+	return nil
+}
+
+// Check returns the violations of dep. The root of a refresh (relation
+// SELF) is the project itself and never violates anything.
+func (p Policy) Check(dep storage.Dependency) []storage.PolicyViolation {
+	if dep.Relation == "SELF" {
+		return nil
+	}
+
+	var violations []storage.PolicyViolation
+	for _, r := range p.Rules {
+		if reason, ok := r.check(dep); !ok {
+			violations = append(violations, storage.PolicyViolation{
+				Rule:       r.Name,
+				Dependency: storage.Dependency{System: dep.System, Name: dep.Name, Version: dep.Version, PURL: dep.PURL},
+				Reason:     reason,
+			})
+		}
+	}
+	return violations
+}
+
+// check returns false and the reason if dep violates r.
+func (r Rule) check(dep storage.Dependency) (string, bool) {
+	if r.Relation != "" && !strings.EqualFold(dep.Relation, r.Relation) {
+		return "", true
+	}
+
+	switch r.Type {
+	case RuleMinScore:
+		if dep.OpenSSFScore == nil {
+			return "no OpenSSF score", r.AllowUnscored
+		}
+		if *dep.OpenSSFScore < *r.MinScore {
+			return fmt.Sprintf("OpenSSF score %s is below %s", formatScore(*dep.OpenSSFScore), formatScore(*r.MinScore)), false
+		}
+	case RuleDeny:
+		for _, pkg := range r.Packages {
+			if pkg.System == dep.System && pkg.Name == dep.Name && (pkg.Version == "" || pkg.Version == dep.Version) {
+				if pkg.Reason != "" {
+					return "package is denied: " + pkg.Reason, false
+				}
+				return "package is denied", false
+			}
+		}
+	case RuleMaxDepth:
+		// Depth 0 is unknown, e.g. for imports without a dependency graph
+		if dep.Depth > r.MaxDepth {
+			return fmt.Sprintf("depth %d exceeds %d", dep.Depth, r.MaxDepth), false
+		}
+	case RuleRequireSourceRepo:
+		if dep.SourceRepo == "" {
+			return "no source repository", false
+		}
+	}
+	return "", true
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"deps-dev/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicy = `
+rules:
+  - name: direct-min-score
+    type: min_score
+    relation: direct
+    min_score: 5
+  - name: denied
+    type: deny
+    packages:
+      - system: npm
+        name: event-stream
+        version: 3.3.6
+        reason: compromised
+      - system: PYPI
+        name: Request_s
+  - name: max-depth
+    type: max_depth
+    max_depth: 3
+  - name: source-repo
+    type: require_source_repo
+    relation: INDIRECT
+`
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	assert.NoError(t, err)
+
+	if assert.Len(t, p.Rules, 4) {
+		assert.Equal(t, "DIRECT", p.Rules[0].Relation)
+		assert.Equal(t, 5.0, *p.Rules[0].MinScore)
+		assert.Equal(t, []Package{
+			{System: "NPM", Name: "event-stream", Version: "3.3.6", Reason: "compromised"},
+			{System: "PYPI", Name: "request-s"},
+		}, p.Rules[1].Packages)
+		assert.Equal(t, 3, p.Rules[2].MaxDepth)
+	}
+}
+
+func TestParse_JSONAndEmpty(t *testing.T) {
+	p, err := Parse([]byte(`{"rules": [{"name": "repo", "type": "require_source_repo"}]}`))
+	assert.NoError(t, err)
+	assert.Len(t, p.Rules, 1)
+
+	p, err = Parse([]byte("  \n"))
+	assert.NoError(t, err)
+	assert.Empty(t, p.Rules)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{"malformed", "rules: [", "failed to decode policy"},
+		{"unknown field", "rules:\n  - name: a\n    type: deny\n    pakages: []", "field pakages not found"},
+		{"missing name", "rules:\n  - type: require_source_repo", "rule 1: missing name"},
+		{"duplicate name", "rules:\n  - {name: a, type: require_source_repo}\n  - {name: a, type: require_source_repo}", "rule a: duplicate name"},
+		{"unknown type", "rules:\n  - {name: a, type: max_age}", `rule a: unknown type "max_age"`},
+		{"bad relation", "rules:\n  - {name: a, type: require_source_repo, relation: SELF}", "relation must be DIRECT or INDIRECT"},
+		{"missing min_score", "rules:\n  - {name: a, type: min_score}", "min_score must be between 0 and 10"},
+		{"min_score out of range", "rules:\n  - {name: a, type: min_score, min_score: 11}", "min_score must be between 0 and 10"},
+		{"max_depth zero", "rules:\n  - {name: a, type: max_depth}", "max_depth must be at least 1"},
+		{"deny without packages", "rules:\n  - {name: a, type: deny}", "no packages to deny"},
+		{"deny unknown system", "rules:\n  - {name: a, type: deny, packages: [{system: bower, name: jquery}]}", `rule a: unknown system "bower"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.input))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	assert.NoError(t, err)
+
+	score := func(s float64) *float64 { return &s }
+
+	tests := []struct {
+		name     string
+		dep      storage.Dependency
+		expected map[string]string
+	}{
+		{
+			name:     "compliant direct dependency",
+			dep:      storage.Dependency{System: "NPM", Name: "react", Version: "18.2.0", Relation: "DIRECT", OpenSSFScore: score(7.5), Depth: 1},
+			expected: map[string]string{},
+		},
+		{
+			name: "low score and unscored direct dependencies",
+			dep:  storage.Dependency{System: "NPM", Name: "left-pad", Version: "1.3.0", Relation: "DIRECT", OpenSSFScore: score(3.1), Depth: 1},
+			expected: map[string]string{
+				"direct-min-score": "OpenSSF score 3.1 is below 5",
+			},
+		},
+		{
+			name: "unscored direct dependency",
+			dep:  storage.Dependency{System: "NPM", Name: "is-odd", Version: "3.0.1", Relation: "DIRECT"},
+			expected: map[string]string{
+				"direct-min-score": "no OpenSSF score",
+			},
+		},
+		{
+			name: "denied version, deep and without source repo",
+			dep:  storage.Dependency{System: "NPM", Name: "event-stream", Version: "3.3.6", Relation: "INDIRECT", Depth: 4},
+			expected: map[string]string{
+				"denied":      "package is denied: compromised",
+				"max-depth":   "depth 4 exceeds 3",
+				"source-repo": "no source repository",
+			},
+		},
+		{
+			name:     "other version of a denied package",
+			dep:      storage.Dependency{System: "NPM", Name: "event-stream", Version: "4.0.1", Relation: "INDIRECT", SourceRepo: "github.com/dominictarr/event-stream", Depth: 2},
+			expected: map[string]string{},
+		},
+		{
+			name: "every version denied",
+			dep:  storage.Dependency{System: "PYPI", Name: "request-s", Version: "0.1", Relation: "INDIRECT", SourceRepo: "github.com/x/y"},
+			expected: map[string]string{
+				"denied": "package is denied",
+			},
+		},
+		{
+			name:     "root of the refresh",
+			dep:      storage.Dependency{System: "NPM", Name: "event-stream", Version: "3.3.6", Relation: "SELF"},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			for _, v := range p.Check(tt.dep) {
+				assert.Equal(t, tt.dep.Name, v.Dependency.Name)
+				got[v.Rule] = v.Reason
+			}
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestCheck_AllowUnscored(t *testing.T) {
+	p, err := Parse([]byte("rules:\n  - {name: score, type: min_score, min_score: 5, allow_unscored: true}"))
+	assert.NoError(t, err)
+
+	assert.Empty(t, p.Check(storage.Dependency{System: "NPM", Name: "is-odd", Version: "3.0.1", Relation: "INDIRECT"}))
+}
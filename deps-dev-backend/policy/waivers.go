@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"deps-dev/storage"
+	"time"
+)
+
+// ApplyWaivers sets the WaiverID of violations matched by a waiver active at
+// now and returns how many it waived. A waiver matches violations of its rule
+// by the same package, in any version unless it names one. Violations whose
+// waivers have lapsed are left unwaived, so they resurface.
+func ApplyWaivers(violations []storage.PolicyViolation, waivers []storage.Waiver, now time.Time) int {
+	waived := 0
+	for i := range violations {
+		v := &violations[i]
+		v.WaiverID = nil
+
+		// Of several matching waivers, the one lasting longest is reported
+		var match *storage.Waiver
+		for j := range waivers {
+			w := &waivers[j]
+			if w.Active(now) && waives(*w, *v) && (match == nil || w.ExpiresAt.After(match.ExpiresAt)) {
+				match = w
+			}
+		}
+		if match != nil {
+			id := match.ID
+			v.WaiverID = &id
+			waived++
+		}
+	}
+	return waived
+}
+
+func waives(w storage.Waiver, v storage.PolicyViolation) bool {
+	dep := v.Dependency
+	return w.Rule == v.Rule && w.System == dep.System && w.Name == dep.Name &&
+		(w.Version == "" || w.Version == dep.Version)
+}
@@ -0,0 +1,43 @@
+package policy
+
+import (
+	"deps-dev/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyWaivers(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	violation := func(rule, name, version string) storage.PolicyViolation {
+		return storage.PolicyViolation{Rule: rule, Dependency: storage.Dependency{System: "NPM", Name: name, Version: version}}
+	}
+	violations := []storage.PolicyViolation{
+		violation("min-score", "left-pad", "1.3.0"),
+		violation("min-score", "left-pad", "1.2.0"),
+		violation("denied", "left-pad", "1.3.0"),
+		violation("min-score", "is-odd", "3.0.1"),
+		violation("min-score", "event-stream", "3.3.6"),
+	}
+	waivers := []storage.Waiver{
+		{ID: 1, Rule: "min-score", System: "NPM", Name: "left-pad", Version: "1.3.0", ExpiresAt: now.AddDate(0, 1, 0)},
+		{ID: 2, Rule: "min-score", System: "NPM", Name: "is-odd", ExpiresAt: now.AddDate(0, 1, 0)},
+		{ID: 3, Rule: "min-score", System: "NPM", Name: "is-odd", ExpiresAt: now.AddDate(1, 0, 0)},
+		// Lapsed
+		{ID: 4, Rule: "min-score", System: "NPM", Name: "event-stream", ExpiresAt: now},
+	}
+
+	waived := ApplyWaivers(violations, waivers, now)
+	assert.Equal(t, 2, waived)
+
+	ids := make([]int64, len(violations))
+	for i, v := range violations {
+		if v.WaiverID != nil {
+			ids[i] = *v.WaiverID
+		}
+	}
+	// Only the waived version and rule; the longest lasting waiver wins
+	assert.Equal(t, []int64{1, 0, 0, 3, 0}, ids)
+}
@@ -0,0 +1,151 @@
+// Package providers looks up package metadata (source repository, license
+// and OpenSSF score) from several sources in priority order.
+package providers
+
+import (
+	"context"
+	"deps-dev/depsdev"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Names of the built-in providers. They are stored with every value a
+// provider supplies.
+const (
+	DepsDev  = "deps.dev"
+	Mirror   = "mirror"
+	Dataset  = "dataset"
+	Imported = "imported"
+)
+
+// ErrNotFound is returned by providers that know nothing about a package.
+var ErrNotFound = errors.New("package not found")
+
+var errNoProviders = errors.New("no metadata providers configured")
+
+// Source answers metadata lookups the way deps.dev does. The deps.dev client
+// is one, and so is every data.DepsDevAPI.
+type Source interface {
+	GetPackageMetadata(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error)
+	GetScorecardData(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo
+}
+
+type GraphSource interface {
+	GetDependencyGraph(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error)
+}
+
+// ErrNoGraphs is returned for dependency graphs when no provider can serve them.
+var ErrNoGraphs = errors.New("dependency graphs are not available offline, replay recorded deps.dev responses instead")
+
+// NoGraphs is the graph source of a chain without a provider that serves
+// dependency graphs, so refreshes fail instead of reaching out to deps.dev.
+type NoGraphs struct{}
+
+func (NoGraphs) GetDependencyGraph(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+	return nil, ErrNoGraphs
+}
+
+// ServesGraphs reports whether the named provider is deps.dev or a mirror of
+// it, which serve dependency graphs as well as metadata.
+func ServesGraphs(name string) bool {
+	return name == DepsDev || name == Mirror
+}
+
+type Provider struct {
+	Name   string
+	Source Source
+}
+
+// Chain implements data.DepsDevAPI on top of several providers. Metadata
+// comes from the first provider that answers, scores from the first one that
+// has a score; a provider that fails is skipped for the next one. Results
+// are tagged with the name of the provider that supplied them.
+type Chain struct {
+	// Graphs supplies dependency graphs, which only deps.dev and its mirrors
+	// have, unless recorded responses are replayed.
+	Graphs    GraphSource
+	Providers []Provider
+	Log       *logrus.Logger
+}
+
+func (c *Chain) GetDependencyGraph(ctx context.Context, system, name, version string) (*depsdev.DependencyGraph, error) {
+	return c.Graphs.GetDependencyGraph(ctx, system, name, version)
+}
+
+func (c *Chain) GetPackageMetadata(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+	if len(c.Providers) == 0 {
+		return nil, errNoProviders
+	}
+
+	var errs []error
+	for _, p := range c.Providers {
+		meta, err := p.Source.GetPackageMetadata(ctx, vk)
+		if err == nil {
+			meta.Provider = p.Name
+			return meta, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		c.fallback(p, logrus.Fields{"system": vk.System, "name": vk.Name, "version": vk.Version}, err)
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// GetScorecardData asks every provider in turn until one has a score. If
+// none has, the project is unscored unless every provider failed.
+func (c *Chain) GetScorecardData(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+	if len(c.Providers) == 0 {
+		return depsdev.ScorecardInfo{Err: errNoProviders}
+	}
+
+	var (
+		unscored *depsdev.ScorecardInfo
+		failed   depsdev.ScorecardInfo
+		errs     []error
+	)
+	for _, p := range c.Providers {
+		info := p.Source.GetScorecardData(ctx, meta)
+		if info.Err != nil {
+			if ctx.Err() != nil {
+				return info
+			}
+
+			c.fallback(p, logrus.Fields{"project": info.SourceRepo}, info.Err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name, info.Err))
+			failed = info
+			continue
+		}
+
+		if info.OpenSSFScore != nil {
+			info.Provider = p.Name
+			return info
+		}
+		if unscored == nil {
+			unscored = &info
+		}
+	}
+
+	if unscored != nil {
+		return *unscored
+	}
+	failed.Err = errors.Join(errs...)
+	return failed
+}
+
+func (c *Chain) fallback(p Provider, fields logrus.Fields, err error) {
+	if c.Log == nil {
+		return
+	}
+
+	log := c.Log.WithFields(fields).WithField("provider", p.Name).WithError(err)
+	if errors.Is(err, ErrNotFound) {
+		log.Debug("package unknown to metadata provider, trying the next one")
+		return
+	}
+	log.Warn("metadata provider failed, trying the next one")
+}
@@ -0,0 +1,163 @@
+package providers
+
+import (
+	"context"
+	"deps-dev/depsdev"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSource struct {
+	GetPackageMetadataFn func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error)
+	GetScorecardDataFn   func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo
+	Calls                int
+}
+
+func (m *mockSource) GetPackageMetadata(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+	m.Calls++
+	return m.GetPackageMetadataFn(ctx, vk)
+}
+func (m *mockSource) GetScorecardData(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+	m.Calls++
+	return m.GetScorecardDataFn(ctx, meta)
+}
+
+func metadata(repo string) *depsdev.PackageVersionMetadata {
+	return &depsdev.PackageVersionMetadata{
+		RelatedProjects: []depsdev.RelatedProject{
+			{ProjectKey: depsdev.ProjectKey{ID: repo}, RelationType: "SOURCE_REPO"},
+		},
+		Licenses: []string{"MIT"},
+	}
+}
+
+var reactKey = depsdev.VersionKey{System: "NPM", Name: "react", Version: "18.2.0"}
+
+func TestChain_GetPackageMetadata(t *testing.T) {
+	failing := &mockSource{GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+		return nil, errors.New("package metadata request failed for react: 503 Service Unavailable")
+	}}
+	unknown := &mockSource{GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+		return nil, ErrNotFound
+	}}
+	mirror := &mockSource{GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+		return metadata("github.com/facebook/react"), nil
+	}}
+	last := &mockSource{GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+		t.Fatal("providers after the one that answered must not be asked")
+		return nil, nil
+	}}
+
+	chain := &Chain{Providers: []Provider{
+		{Name: DepsDev, Source: failing},
+		{Name: Dataset, Source: unknown},
+		{Name: Mirror, Source: mirror},
+		{Name: "last", Source: last},
+	}, Log: logrus.New()}
+
+	meta, err := chain.GetPackageMetadata(context.Background(), reactKey)
+	assert.NoError(t, err)
+	assert.Equal(t, Mirror, meta.Provider)
+	assert.Equal(t, "github.com/facebook/react", meta.SourceRepo())
+	assert.Equal(t, 1, failing.Calls)
+	assert.Equal(t, 1, unknown.Calls)
+}
+
+func TestChain_GetPackageMetadataAllFail(t *testing.T) {
+	chain := &Chain{Providers: []Provider{
+		{Name: DepsDev, Source: &mockSource{GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			return nil, errors.New("503 Service Unavailable")
+		}}},
+		{Name: Dataset, Source: &mockSource{GetPackageMetadataFn: func(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+			return nil, ErrNotFound
+		}}},
+	}, Log: logrus.New()}
+
+	_, err := chain.GetPackageMetadata(context.Background(), reactKey)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Contains(t, err.Error(), "deps.dev: 503 Service Unavailable")
+	assert.Contains(t, err.Error(), "dataset: package not found")
+
+	_, err = (&Chain{}).GetPackageMetadata(context.Background(), reactKey)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no metadata providers configured")
+}
+
+func TestChain_GetScorecardData(t *testing.T) {
+	score := func(s float64) *float64 { return &s }
+	source := func(info depsdev.ScorecardInfo) *mockSource {
+		return &mockSource{GetScorecardDataFn: func(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+			info.SourceRepo = meta.SourceRepo()
+			return info
+		}}
+	}
+
+	tests := []struct {
+		name             string
+		providers        []depsdev.ScorecardInfo
+		expectedScore    *float64
+		expectedProvider string
+		expectedError    string
+	}{
+		{
+			name:             "first provider has a score",
+			providers:        []depsdev.ScorecardInfo{{OpenSSFScore: score(6.8)}, {OpenSSFScore: score(1)}},
+			expectedScore:    score(6.8),
+			expectedProvider: DepsDev,
+		},
+		{
+			name:             "falls back on failure",
+			providers:        []depsdev.ScorecardInfo{{Err: errors.New("timeout")}, {OpenSSFScore: score(5.5)}},
+			expectedScore:    score(5.5),
+			expectedProvider: Dataset,
+		},
+		{
+			name:             "falls back for unscored projects",
+			providers:        []depsdev.ScorecardInfo{{}, {OpenSSFScore: score(4)}},
+			expectedScore:    score(4),
+			expectedProvider: Dataset,
+		},
+		{
+			name:      "unscored everywhere",
+			providers: []depsdev.ScorecardInfo{{Err: errors.New("timeout")}, {}},
+		},
+		{
+			name:          "failed everywhere",
+			providers:     []depsdev.ScorecardInfo{{Err: errors.New("timeout")}, {Err: errors.New("connection refused")}},
+			expectedError: "deps.dev: timeout\ndataset: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &Chain{Providers: []Provider{
+				{Name: DepsDev, Source: source(tt.providers[0])},
+				{Name: Dataset, Source: source(tt.providers[1])},
+			}, Log: logrus.New()}
+
+			info := chain.GetScorecardData(context.Background(), metadata("github.com/facebook/react"))
+			assert.Equal(t, "github.com/facebook/react", info.SourceRepo)
+			assert.Equal(t, tt.expectedScore, info.OpenSSFScore)
+			assert.Equal(t, tt.expectedProvider, info.Provider)
+			if tt.expectedError != "" {
+				assert.EqualError(t, info.Err, tt.expectedError)
+			} else {
+				assert.NoError(t, info.Err)
+			}
+		})
+	}
+}
+
+func TestChain_NoGraphs(t *testing.T) {
+	chain := &Chain{Graphs: NoGraphs{}, Providers: []Provider{{Name: Imported, Source: &mockSource{}}}}
+	_, err := chain.GetDependencyGraph(context.Background(), "NPM", "react", "18.2.0")
+	assert.ErrorIs(t, err, ErrNoGraphs)
+
+	assert.True(t, ServesGraphs(DepsDev))
+	assert.True(t, ServesGraphs(Mirror))
+	assert.False(t, ServesGraphs(Dataset))
+	assert.False(t, ServesGraphs(Imported))
+}
@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"context"
+	"deps-dev/depsdev"
+	"deps-dev/ecosystem"
+	"deps-dev/storage"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DatasetFile is the JSON document a LocalDataset is loaded from.
+type DatasetFile struct {
+	Packages []DatasetPackage `json:"packages"`
+	Projects []DatasetProject `json:"projects"`
+}
+
+// DatasetPackage describes a package version, or every version of the
+// package if Version is empty.
+type DatasetPackage struct {
+	System     string `json:"system"`
+	Name       string `json:"name"`
+	Version    string `json:"version,omitempty"`
+	SourceRepo string `json:"source_repo,omitempty"`
+	License    string `json:"license,omitempty"`
+}
+
+type DatasetProject struct {
+	ID           string  `json:"id"`
+	OpenSSFScore float64 `json:"openssf_score"`
+}
+
+// LocalDataset answers metadata lookups from a curated JSON file, e.g. for
+// internal packages deps.dev does not know.
+type LocalDataset struct {
+	packages map[string]DatasetPackage
+	scores   map[string]float64
+}
+
+func LoadDataset(path string) (*LocalDataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseDataset(f)
+}
+
+func ParseDataset(r io.Reader) (*LocalDataset, error) {
+	var file DatasetFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode dataset: %w", err)
+	}
+
+	d := &LocalDataset{
+		packages: make(map[string]DatasetPackage, len(file.Packages)),
+		scores:   make(map[string]float64, len(file.Projects)),
+	}
+	for i, pkg := range file.Packages {
+		if pkg.System == "" || pkg.Name == "" {
+			return nil, fmt.Errorf("package %d: system and name are required", i+1)
+		}
+		system, name, version, err := ecosystem.Canonicalize(pkg.System, pkg.Name, pkg.Version)
+		if err != nil {
+			return nil, fmt.Errorf("package %d: %w", i+1, err)
+		}
+		d.packages[storage.DependencyKey(system, name, version)] = pkg
+	}
+	for i, project := range file.Projects {
+		if project.ID == "" {
+			return nil, fmt.Errorf("project %d: id is required", i+1)
+		}
+		if project.OpenSSFScore < 0 || project.OpenSSFScore > 10 {
+			return nil, fmt.Errorf("project %s: openssf_score must be between 0 and 10", project.ID)
+		}
+		d.scores[project.ID] = project.OpenSSFScore
+	}
+	return d, nil
+}
+
+func (d *LocalDataset) GetPackageMetadata(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+	system, name, version, err := ecosystem.Canonicalize(vk.System, vk.Name, vk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, ok := d.packages[storage.DependencyKey(system, name, version)]
+	if !ok {
+		pkg, ok = d.packages[storage.DependencyKey(system, name, "")]
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s@%s", ErrNotFound, system, name, version)
+	}
+
+	return packageMetadata(pkg.SourceRepo, pkg.License), nil
+}
+
+// packageMetadata is the deps.dev shaped metadata of a dataset entry.
+func packageMetadata(sourceRepo, license string) *depsdev.PackageVersionMetadata {
+	meta := &depsdev.PackageVersionMetadata{}
+	if sourceRepo != "" {
+		meta.RelatedProjects = []depsdev.RelatedProject{
+			{ProjectKey: depsdev.ProjectKey{ID: sourceRepo}, RelationType: "SOURCE_REPO"},
+		}
+	}
+	if license != "" {
+		meta.Licenses = []string{license}
+	}
+	return meta
+}
+
+// GetScorecardData returns the score of the source repository in meta, which
+// may come from another provider. Unknown projects are unscored.
+func (d *LocalDataset) GetScorecardData(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+	info := depsdev.ScorecardInfo{SourceRepo: meta.SourceRepo()}
+	if score, ok := d.scores[info.SourceRepo]; ok {
+		info.OpenSSFScore = &score
+	}
+	return info
+}
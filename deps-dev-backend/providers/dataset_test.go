@@ -0,0 +1,79 @@
+package providers
+
+import (
+	"context"
+	"deps-dev/depsdev"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDataset = `{
+  "packages": [
+    {"system": "npm", "name": "@acme/ui", "source_repo": "git.acme.com/web/ui", "license": "Apache-2.0"},
+    {"system": "NPM", "name": "@acme/ui", "version": "0.1.0", "license": "UNLICENSED"},
+    {"system": "pypi", "name": "Acme_Client", "version": "2.0.0", "source_repo": "git.acme.com/py/client"}
+  ],
+  "projects": [
+    {"id": "git.acme.com/web/ui", "openssf_score": 7.2}
+  ]
+}`
+
+func TestLocalDataset(t *testing.T) {
+	d, err := ParseDataset(strings.NewReader(testDataset))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		vk              depsdev.VersionKey
+		expectedRepo    string
+		expectedLicense string
+		expectedScore   *float64
+	}{
+		{"any version", depsdev.VersionKey{System: "NPM", Name: "@acme/ui", Version: "1.4.0"}, "git.acme.com/web/ui", "Apache-2.0", func() *float64 { s := 7.2; return &s }()},
+		{"exact version", depsdev.VersionKey{System: "NPM", Name: "@acme/ui", Version: "0.1.0"}, "", "UNLICENSED", nil},
+		{"canonical name, unscored project", depsdev.VersionKey{System: "PYPI", Name: "acme-client", Version: "2.0.0"}, "git.acme.com/py/client", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := d.GetPackageMetadata(context.Background(), tt.vk)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedRepo, meta.SourceRepo())
+			assert.Equal(t, tt.expectedLicense, meta.License())
+
+			info := d.GetScorecardData(context.Background(), meta)
+			assert.NoError(t, info.Err)
+			assert.Equal(t, tt.expectedRepo, info.SourceRepo)
+			assert.Equal(t, tt.expectedScore, info.OpenSSFScore)
+		})
+	}
+
+	_, err = d.GetPackageMetadata(context.Background(), depsdev.VersionKey{System: "PYPI", Name: "acme-client", Version: "1.0.0"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Contains(t, err.Error(), "PYPI/acme-client@1.0.0")
+}
+
+func TestParseDataset_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{"malformed", `{"packages": [`, "failed to decode dataset"},
+		{"unknown field", `{"packages": [{"system": "npm", "name": "a", "repo": "x"}]}`, `unknown field "repo"`},
+		{"missing name", `{"packages": [{"system": "npm"}]}`, "package 1: system and name are required"},
+		{"unknown system", `{"packages": [{"system": "bower", "name": "jquery"}]}`, `package 1: unknown system "bower"`},
+		{"missing project id", `{"projects": [{"openssf_score": 5}]}`, "project 1: id is required"},
+		{"score out of range", `{"projects": [{"id": "github.com/x/y", "openssf_score": 12}]}`, "openssf_score must be between 0 and 10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDataset(strings.NewReader(tt.input))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
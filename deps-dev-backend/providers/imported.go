@@ -0,0 +1,58 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"deps-dev/depsdev"
+	"deps-dev/ecosystem"
+	"deps-dev/storage"
+	"errors"
+	"fmt"
+)
+
+type ImportedStore interface {
+	GetDatasetPackage(ctx context.Context, system, name, version string) (storage.DatasetPackage, error)
+	GetProjectScore(ctx context.Context, projectID string) (float64, error)
+}
+
+// ImportedDataset is a Source answering from the datasets imported with
+// POST /imports/dataset, so metadata lookups work without reaching deps.dev.
+type ImportedDataset struct {
+	Store ImportedStore
+}
+
+func (d *ImportedDataset) GetPackageMetadata(ctx context.Context, vk depsdev.VersionKey) (*depsdev.PackageVersionMetadata, error) {
+	system, name, version, err := ecosystem.Canonicalize(vk.System, vk.Name, vk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := d.Store.GetDatasetPackage(ctx, system, name, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s/%s@%s", ErrNotFound, system, name, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up imported metadata for %s: %w", name, err)
+	}
+
+	return packageMetadata(pkg.SourceRepo, pkg.License), nil
+}
+
+// GetScorecardData returns the imported score of the source repository in
+// meta. Projects without an imported score are unscored.
+func (d *ImportedDataset) GetScorecardData(ctx context.Context, meta *depsdev.PackageVersionMetadata) depsdev.ScorecardInfo {
+	info := depsdev.ScorecardInfo{SourceRepo: meta.SourceRepo()}
+	if info.SourceRepo == "" {
+		return info
+	}
+
+	score, err := d.Store.GetProjectScore(ctx, info.SourceRepo)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		info.Err = fmt.Errorf("failed to look up imported score for %s: %w", info.SourceRepo, err)
+	default:
+		info.OpenSSFScore = &score
+	}
+	return info
+}
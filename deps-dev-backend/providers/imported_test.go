@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"deps-dev/depsdev"
+	"deps-dev/storage"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockImportedStore struct {
+	Packages map[string]storage.DatasetPackage
+	Scores   map[string]float64
+	Err      error
+}
+
+func (m *mockImportedStore) GetDatasetPackage(ctx context.Context, system, name, version string) (storage.DatasetPackage, error) {
+	if m.Err != nil {
+		return storage.DatasetPackage{}, m.Err
+	}
+	pkg, ok := m.Packages[storage.DependencyKey(system, name, version)]
+	if !ok {
+		return storage.DatasetPackage{}, sql.ErrNoRows
+	}
+	return pkg, nil
+}
+func (m *mockImportedStore) GetProjectScore(ctx context.Context, projectID string) (float64, error) {
+	if m.Err != nil {
+		return 0, m.Err
+	}
+	score, ok := m.Scores[projectID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return score, nil
+}
+
+func TestImportedDataset(t *testing.T) {
+	store := &mockImportedStore{
+		Packages: map[string]storage.DatasetPackage{
+			"NPM|react|18.2.0":    {System: "NPM", Name: "react", SourceRepo: "github.com/facebook/react", License: "MIT"},
+			"NPM|js-tokens|4.0.0": {System: "NPM", Name: "js-tokens", SourceRepo: "github.com/lydell/js-tokens"},
+		},
+		Scores: map[string]float64{"github.com/facebook/react": 6.8},
+	}
+	d := &ImportedDataset{Store: store}
+
+	// Keys are canonicalized before the lookup
+	meta, err := d.GetPackageMetadata(context.Background(), depsdev.VersionKey{System: "npm", Name: "react", Version: "18.2.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "MIT", meta.License())
+	info := d.GetScorecardData(context.Background(), meta)
+	assert.NoError(t, info.Err)
+	assert.Equal(t, "github.com/facebook/react", info.SourceRepo)
+	assert.Equal(t, 6.8, *info.OpenSSFScore)
+
+	meta, err = d.GetPackageMetadata(context.Background(), depsdev.VersionKey{System: "NPM", Name: "js-tokens", Version: "4.0.0"})
+	assert.NoError(t, err)
+	info = d.GetScorecardData(context.Background(), meta)
+	assert.NoError(t, info.Err)
+	assert.Nil(t, info.OpenSSFScore)
+
+	_, err = d.GetPackageMetadata(context.Background(), depsdev.VersionKey{System: "NPM", Name: "vue", Version: "3.0.0"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	store.Err = errors.New("database is locked")
+	info = d.GetScorecardData(context.Background(), meta)
+	assert.Error(t, info.Err)
+	assert.Contains(t, info.Err.Error(), "database is locked")
+}
@@ -0,0 +1,183 @@
+// Package purl converts between deps.dev package versions and package URLs
+// (https://github.com/package-url/purl-spec).
+package purl
+
+import (
+	"deps-dev/ecosystem"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Package URL types of the deps.dev systems
+var types = map[string]string{
+	"NPM":      "npm",
+	"GO":       "golang",
+	"PYPI":     "pypi",
+	"CARGO":    "cargo",
+	"MAVEN":    "maven",
+	"NUGET":    "nuget",
+	"RUBYGEMS": "gem",
+}
+
+// Format returns the package URL of a deps.dev package version, e.g.
+// pkg:npm/%40babel/core@7.22.0, or "" if the system has no purl type or
+// the name does not fit it.
+func Format(system, name, version string) string {
+	purlType, ok := types[strings.ToUpper(system)]
+	if !ok || name == "" {
+		return ""
+	}
+
+	namespace, name := split(purlType, name)
+	if name == "" {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("pkg:")
+	sb.WriteString(purlType)
+	sb.WriteString("/")
+	if namespace != "" {
+		for _, segment := range strings.Split(namespace, "/") {
+			sb.WriteString(escape(segment))
+			sb.WriteString("/")
+		}
+	}
+	sb.WriteString(escape(name))
+	if version != "" {
+		sb.WriteString("@")
+		sb.WriteString(escape(version))
+	}
+	return sb.String()
+}
+
+// split divides a deps.dev package name into the purl namespace and name.
+func split(purlType, name string) (string, string) {
+	switch purlType {
+	case "npm":
+		// @scope/name
+		if i := strings.Index(name, "/"); strings.HasPrefix(name, "@") && i > 0 {
+			return name[:i], name[i+1:]
+		}
+	case "golang":
+		if i := strings.LastIndex(name, "/"); i > 0 {
+			return name[:i], name[i+1:]
+		}
+	case "maven":
+		// group:artifact
+		group, artifact, ok := strings.Cut(name, ":")
+		if !ok {
+			return "", ""
+		}
+		return group, artifact
+	case "pypi":
+		return "", strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	}
+	return "", name
+}
+
+// escape percent-encodes a purl segment. "@" separates the version, so it is
+// encoded too, e.g. in npm scopes.
+func escape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+}
+
+// PackageURL is a parsed package URL.
+type PackageURL struct {
+	Type       string
+	Namespace  string
+	Name       string
+	Version    string
+	Qualifiers map[string]string
+	Subpath    string
+}
+
+// Parse parses a package URL such as pkg:maven/org.apache/commons-lang3@3.12.0.
+func Parse(s string) (PackageURL, error) {
+	var p PackageURL
+
+	scheme, rest, ok := strings.Cut(s, ":")
+	if !ok || !strings.EqualFold(scheme, "pkg") {
+		return p, fmt.Errorf("invalid package URL %q: missing pkg: scheme", s)
+	}
+	rest = strings.TrimLeft(rest, "/")
+
+	rest, subpath, _ := strings.Cut(rest, "#")
+	p.Subpath = strings.Trim(subpath, "/")
+
+	rest, query, _ := strings.Cut(rest, "?")
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return p, fmt.Errorf("invalid package URL %q: %w", s, err)
+		}
+		p.Qualifiers = make(map[string]string, len(values))
+		for key, value := range values {
+			p.Qualifiers[strings.ToLower(key)] = value[0]
+		}
+	}
+
+	// The version follows the last "@", unless that one is in the namespace,
+	// like an unencoded npm scope
+	if i := strings.LastIndex(rest, "@"); i > strings.LastIndex(rest, "/") {
+		version, err := url.PathUnescape(rest[i+1:])
+		if err != nil {
+			return p, fmt.Errorf("invalid package URL %q: %w", s, err)
+		}
+		p.Version = version
+		rest = rest[:i]
+	}
+
+	purlType, path, _ := strings.Cut(rest, "/")
+	p.Type = strings.ToLower(purlType)
+
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			return p, fmt.Errorf("invalid package URL %q: %w", s, err)
+		}
+		segments = append(segments, decoded)
+	}
+
+	if p.Type == "" || len(segments) == 0 {
+		return p, fmt.Errorf("invalid package URL %q: missing type or name", s)
+	}
+	p.Name = segments[len(segments)-1]
+	p.Namespace = strings.Join(segments[:len(segments)-1], "/")
+
+	return p, nil
+}
+
+// DepsDev returns the deps.dev system and package name p refers to, or
+// false if deps.dev does not cover its type.
+func (p PackageURL) DepsDev() (string, string, bool) {
+	var system string
+	for s, t := range types {
+		if t == p.Type {
+			system = s
+		}
+	}
+	if system == "" {
+		return "", "", false
+	}
+
+	switch p.Type {
+	case "maven":
+		if p.Namespace == "" {
+			return "", "", false
+		}
+		return system, p.Namespace + ":" + p.Name, true
+	case "pypi":
+		return system, ecosystem.NormalizePyPIName(p.Name), true
+	}
+
+	if p.Namespace != "" {
+		return system, p.Namespace + "/" + p.Name, true
+	}
+	return system, p.Name, true
+}
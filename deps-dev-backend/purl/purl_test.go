@@ -0,0 +1,192 @@
+package purl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		system, name, version string
+		expected              string
+	}{
+		{"NPM", "react", "18.2.0", "pkg:npm/react@18.2.0"},
+		{"npm", "@babel/core", "7.22.0", "pkg:npm/%40babel/core@7.22.0"},
+		{"GO", "github.com/go-chi/chi/v5", "v5.0.10", "pkg:golang/github.com/go-chi/chi/v5@v5.0.10"},
+		{"GO", "golang.org/x/mod", "v0.12.0+incompatible", "pkg:golang/golang.org/x/mod@v0.12.0+incompatible"},
+		{"PYPI", "Django_Rest", "3.14.0", "pkg:pypi/django-rest@3.14.0"},
+		{"CARGO", "serde", "1.0.188", "pkg:cargo/serde@1.0.188"},
+		{"MAVEN", "org.springframework:spring-core", "5.3.29", "pkg:maven/org.springframework/spring-core@5.3.29"},
+		{"NUGET", "Newtonsoft.Json", "13.0.3", "pkg:nuget/Newtonsoft.Json@13.0.3"},
+		{"RUBYGEMS", "rails", "7.0.8", "pkg:gem/rails@7.0.8"},
+		{"NPM", "left pad", "", "pkg:npm/left%20pad"},
+		{"MAVEN", "no-group", "1.0", ""},
+		{"UNKNOWN", "pkg", "1.0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.system+" "+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Format(tt.system, tt.name, tt.version))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected PackageURL
+		system   string
+		name     string
+	}{
+		{
+			input:    "pkg:npm/%40babel/core@7.22.0",
+			expected: PackageURL{Type: "npm", Namespace: "@babel", Name: "core", Version: "7.22.0"},
+			system:   "NPM", name: "@babel/core",
+		},
+		{
+			input:    "pkg:golang/github.com/go-chi/chi/v5@v5.0.10",
+			expected: PackageURL{Type: "golang", Namespace: "github.com/go-chi/chi", Name: "v5", Version: "v5.0.10"},
+			system:   "GO", name: "github.com/go-chi/chi/v5",
+		},
+		{
+			input:    "pkg:maven/org.apache.commons/commons-lang3@3.12.0?type=jar",
+			expected: PackageURL{Type: "maven", Namespace: "org.apache.commons", Name: "commons-lang3", Version: "3.12.0", Qualifiers: map[string]string{"type": "jar"}},
+			system:   "MAVEN", name: "org.apache.commons:commons-lang3",
+		},
+		{
+			input:    "pkg:pypi/Django_Rest@3.14.0",
+			expected: PackageURL{Type: "pypi", Name: "Django_Rest", Version: "3.14.0"},
+			system:   "PYPI", name: "django-rest",
+		},
+		{
+			input:    "pkg:pypi/Zope.Interface@5.0",
+			expected: PackageURL{Type: "pypi", Name: "Zope.Interface", Version: "5.0"},
+			system:   "PYPI", name: "zope-interface",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := Parse(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, p)
+
+			system, name, ok := p.DepsDev()
+			assert.True(t, ok)
+			assert.Equal(t, tt.system, system)
+			assert.Equal(t, tt.name, name)
+		})
+	}
+}
+
+func TestParse_Components(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected PackageURL
+	}{
+		{
+			// Scheme and type are case-insensitive, slashes after the scheme ignored
+			input:    "PKG://NPM/react@18.2.0",
+			expected: PackageURL{Type: "npm", Name: "react", Version: "18.2.0"},
+		},
+		{
+			// An unencoded npm scope is not mistaken for the version
+			input:    "pkg:npm/@babel/core@7.22.0",
+			expected: PackageURL{Type: "npm", Namespace: "@babel", Name: "core", Version: "7.22.0"},
+		},
+		{
+			input:    "pkg:npm/@babel/core",
+			expected: PackageURL{Type: "npm", Namespace: "@babel", Name: "core"},
+		},
+		{
+			input:    "pkg:golang/golang.org/x/mod@v0.12.0%2Bincompatible",
+			expected: PackageURL{Type: "golang", Namespace: "golang.org/x", Name: "mod", Version: "v0.12.0+incompatible"},
+		},
+		{
+			input: "pkg:golang/google.golang.org/genproto@v0.0.0-20230822#googleapis/api/annotations/",
+			expected: PackageURL{Type: "golang", Namespace: "google.golang.org", Name: "genproto", Version: "v0.0.0-20230822",
+				Subpath: "googleapis/api/annotations"},
+		},
+		{
+			input: "pkg:maven/org.apache.commons/commons-lang3@3.12.0?Classifier=sources&repository_url=repo.example.com",
+			expected: PackageURL{Type: "maven", Namespace: "org.apache.commons", Name: "commons-lang3", Version: "3.12.0",
+				Qualifiers: map[string]string{"classifier": "sources", "repository_url": "repo.example.com"}},
+		},
+		{
+			input:    "pkg:npm/left%20pad@1.0.0",
+			expected: PackageURL{Type: "npm", Name: "left pad", Version: "1.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := Parse(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, p)
+		})
+	}
+}
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	tests := []struct{ system, name, version string }{
+		{"NPM", "react", "18.2.0"},
+		{"NPM", "@babel/core", "7.22.0"},
+		{"NPM", "@types/node", "20.4.5-beta.1"},
+		{"GO", "github.com/go-chi/chi/v5", "v5.0.10"},
+		{"GO", "golang.org/x/mod", "v0.12.0+incompatible"},
+		{"GO", "gopkg.in/yaml.v3", "v3.0.1"},
+		{"PYPI", "django-rest", "3.14.0"},
+		{"CARGO", "serde_json", "1.0.105"},
+		{"MAVEN", "org.springframework:spring-core", "5.3.29"},
+		{"NUGET", "Newtonsoft.Json", "13.0.3"},
+		{"RUBYGEMS", "rails", "7.0.8"},
+		{"RUBYGEMS", "nokogiri", "1.16.0.rc1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.system+" "+tt.name, func(t *testing.T) {
+			p, err := Parse(Format(tt.system, tt.name, tt.version))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.version, p.Version)
+
+			system, name, ok := p.DepsDev()
+			assert.True(t, ok)
+			assert.Equal(t, tt.system, system)
+			assert.Equal(t, tt.name, name)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"npm/react@18.2.0",
+		"http://example.com/react",
+		"pkg:npm",
+		"pkg:/react@1.0",
+		"pkg:npm/@1.0.0",
+		"pkg:npm/react@1.0%zz",
+		"pkg:npm/bad%zzname@1.0.0",
+		"pkg:npm/react@1.0?a=%zz",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := Parse(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDepsDev_Unsupported(t *testing.T) {
+	for _, input := range []string{
+		"pkg:deb/debian/curl@7.50.3-1",
+		"pkg:docker/library/alpine@3.18",
+		"pkg:github/package-url/purl-spec@1.0",
+		"pkg:maven/commons-lang3@3.12.0",
+	} {
+		p, err := Parse(input)
+		assert.NoError(t, err)
+		_, _, ok := p.DepsDev()
+		assert.False(t, ok, input)
+	}
+}
@@ -0,0 +1,167 @@
+package sbom
+
+import (
+	"deps-dev/purl"
+	"deps-dev/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CycloneDXSpecVersion = "1.5"
+
+type CycloneDXBOM struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     CycloneDXMetadata     `json:"metadata"`
+	Components   []CycloneDXComponent  `json:"components"`
+	Dependencies []CycloneDXDependency `json:"dependencies,omitempty"`
+}
+
+type CycloneDXMetadata struct {
+	Timestamp string              `json:"timestamp"`
+	Tools     CycloneDXTools      `json:"tools"`
+	Component *CycloneDXComponent `json:"component,omitempty"`
+}
+
+type CycloneDXTools struct {
+	Components []CycloneDXComponent `json:"components"`
+}
+
+type CycloneDXComponent struct {
+	Type               string                 `json:"type"`
+	BOMRef             string                 `json:"bom-ref,omitempty"`
+	Group              string                 `json:"group,omitempty"`
+	Name               string                 `json:"name"`
+	Version            string                 `json:"version,omitempty"`
+	Scope              string                 `json:"scope,omitempty"`
+	PURL               string                 `json:"purl,omitempty"`
+	Licenses           []CycloneDXLicense     `json:"licenses,omitempty"`
+	ExternalReferences []CycloneDXExternalRef `json:"externalReferences,omitempty"`
+	Properties         []CycloneDXProperty    `json:"properties,omitempty"`
+}
+
+type CycloneDXLicense struct {
+	Expression string `json:"expression"`
+}
+
+type CycloneDXExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type CycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// Property names, following the CycloneDX property taxonomy convention of
+// a namespace prefix
+const (
+	PropertyOpenSSFScore = "openssf:scorecard:score"
+	PropertyRelation     = "deps.dev:relation"
+	PropertySystem       = "deps.dev:system"
+)
+
+// CycloneDX builds a CycloneDX document for deps. Stored DIRECT and
+// INDIRECT dependencies are required components. If the set has a single
+// SELF package, it becomes the document's subject and depends on the DIRECT
+// ones.
+func CycloneDX(deps []storage.Dependency, info Info) CycloneDXBOM {
+	bom := CycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  CycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + info.ID,
+		Version:      1,
+		Metadata: CycloneDXMetadata{
+			Timestamp: info.Created.UTC().Format(time.RFC3339),
+			Tools: CycloneDXTools{Components: []CycloneDXComponent{
+				{Type: "application", Name: ToolName},
+			}},
+		},
+		Components: []CycloneDXComponent{},
+	}
+
+	subject, deps := root(deps)
+	if subject != nil {
+		component := cycloneDXComponent(*subject)
+		component.Type = "application"
+		component.Scope = ""
+		bom.Metadata.Component = &component
+	}
+
+	direct := []string{}
+	for _, dep := range deps {
+		component := cycloneDXComponent(dep)
+		bom.Components = append(bom.Components, component)
+		if strings.EqualFold(dep.Relation, "DIRECT") {
+			direct = append(direct, component.BOMRef)
+		}
+	}
+
+	if subject != nil {
+		bom.Dependencies = []CycloneDXDependency{{Ref: bom.Metadata.Component.BOMRef, DependsOn: direct}}
+	}
+
+	return bom
+}
+
+func cycloneDXComponent(dep storage.Dependency) CycloneDXComponent {
+	component := CycloneDXComponent{
+		Type:    "library",
+		Name:    dep.Name,
+		Version: dep.Version,
+		PURL:    purl.Format(dep.System, dep.Name, dep.Version),
+	}
+	component.BOMRef = component.PURL
+	if component.BOMRef == "" {
+		component.BOMRef = storage.DependencyKey(dep.System, dep.Name, dep.Version)
+	}
+
+	// Split names the way the ecosystems do: Maven groups and npm scopes
+	switch strings.ToUpper(dep.System) {
+	case "MAVEN":
+		if group, artifact, ok := strings.Cut(dep.Name, ":"); ok {
+			component.Group, component.Name = group, artifact
+		}
+	case "NPM":
+		if scope, name, ok := strings.Cut(dep.Name, "/"); ok && strings.HasPrefix(scope, "@") {
+			component.Group, component.Name = scope, name
+		}
+	}
+
+	switch strings.ToUpper(dep.Relation) {
+	case "DIRECT", "INDIRECT":
+		component.Scope = "required"
+	}
+
+	if dep.License != "" {
+		component.Licenses = []CycloneDXLicense{{Expression: dep.License}}
+	}
+
+	if dep.SourceRepo != "" {
+		component.ExternalReferences = []CycloneDXExternalRef{
+			{Type: "vcs", URL: sourceRepoURL(dep.SourceRepo)},
+		}
+	}
+
+	component.Properties = append(component.Properties, CycloneDXProperty{Name: PropertySystem, Value: dep.System})
+	if dep.Relation != "" {
+		component.Properties = append(component.Properties, CycloneDXProperty{Name: PropertyRelation, Value: dep.Relation})
+	}
+	if dep.OpenSSFScore != nil {
+		component.Properties = append(component.Properties, CycloneDXProperty{
+			Name:  PropertyOpenSSFScore,
+			Value: strconv.FormatFloat(*dep.OpenSSFScore, 'f', -1, 64),
+		})
+	}
+
+	return component
+}
@@ -0,0 +1,119 @@
+package sbom
+
+import (
+	"deps-dev/storage"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testInfo = Info{
+	ID:      "3e671687-395b-41f5-a30f-a58921a69b79",
+	Created: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestCycloneDX(t *testing.T) {
+	deps := []storage.Dependency{
+		{System: "NPM", Name: "react", Version: "18.2.0", Relation: "SELF", SourceRepo: "github.com/facebook/react", OpenSSFScore: floatPtr(7.5)},
+		{System: "NPM", Name: "loose-envify", Version: "1.4.0", Relation: "DIRECT", SourceRepo: "github.com/zertosh/loose-envify", License: "MIT"},
+		{System: "NPM", Name: "js-tokens", Version: "4.0.0", Relation: "INDIRECT", OpenSSFScore: floatPtr(4.2)},
+		{System: "MAVEN", Name: "junit:junit", Version: "4.13.2"},
+	}
+
+	bom := CycloneDX(deps, testInfo)
+
+	actual, err := json.MarshalIndent(bom, "", "  ")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.5",
+		"serialNumber": "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		"version": 1,
+		"metadata": {
+			"timestamp": "2024-05-01T12:00:00Z",
+			"tools": {"components": [{"type": "application", "name": "deps-dev-backend"}]},
+			"component": {
+				"type": "application",
+				"bom-ref": "pkg:npm/react@18.2.0",
+				"name": "react",
+				"version": "18.2.0",
+				"purl": "pkg:npm/react@18.2.0",
+				"externalReferences": [{"type": "vcs", "url": "https://github.com/facebook/react"}],
+				"properties": [
+					{"name": "deps.dev:system", "value": "NPM"},
+					{"name": "deps.dev:relation", "value": "SELF"},
+					{"name": "openssf:scorecard:score", "value": "7.5"}
+				]
+			}
+		},
+		"components": [
+			{
+				"type": "library",
+				"bom-ref": "pkg:npm/loose-envify@1.4.0",
+				"name": "loose-envify",
+				"version": "1.4.0",
+				"scope": "required",
+				"purl": "pkg:npm/loose-envify@1.4.0",
+				"licenses": [{"expression": "MIT"}],
+				"externalReferences": [{"type": "vcs", "url": "https://github.com/zertosh/loose-envify"}],
+				"properties": [
+					{"name": "deps.dev:system", "value": "NPM"},
+					{"name": "deps.dev:relation", "value": "DIRECT"}
+				]
+			},
+			{
+				"type": "library",
+				"bom-ref": "pkg:npm/js-tokens@4.0.0",
+				"name": "js-tokens",
+				"version": "4.0.0",
+				"scope": "required",
+				"purl": "pkg:npm/js-tokens@4.0.0",
+				"properties": [
+					{"name": "deps.dev:system", "value": "NPM"},
+					{"name": "deps.dev:relation", "value": "INDIRECT"},
+					{"name": "openssf:scorecard:score", "value": "4.2"}
+				]
+			},
+			{
+				"type": "library",
+				"bom-ref": "pkg:maven/junit/junit@4.13.2",
+				"group": "junit",
+				"name": "junit",
+				"version": "4.13.2",
+				"purl": "pkg:maven/junit/junit@4.13.2",
+				"properties": [{"name": "deps.dev:system", "value": "MAVEN"}]
+			}
+		],
+		"dependencies": [
+			{"ref": "pkg:npm/react@18.2.0", "dependsOn": ["pkg:npm/loose-envify@1.4.0"]}
+		]
+	}`, string(actual))
+}
+
+func TestCycloneDX_WithoutSingleRoot(t *testing.T) {
+	deps := []storage.Dependency{
+		{System: "NPM", Name: "react", Version: "18.2.0", Relation: "SELF"},
+		{System: "NPM", Name: "vue", Version: "3.3.4", Relation: "SELF"},
+		{System: "UNKNOWN", Name: "thing", Version: "1"},
+	}
+
+	bom := CycloneDX(deps, testInfo)
+
+	assert.Nil(t, bom.Metadata.Component)
+	assert.Nil(t, bom.Dependencies)
+	assert.Len(t, bom.Components, 3)
+	assert.Equal(t, "UNKNOWN|thing|1", bom.Components[2].BOMRef)
+	assert.Empty(t, bom.Components[2].PURL)
+}
+
+func TestNewInfo(t *testing.T) {
+	info := NewInfo()
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, info.ID)
+	assert.NotEqual(t, info.ID, NewInfo().ID)
+}
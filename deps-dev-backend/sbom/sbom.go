@@ -0,0 +1,67 @@
+// Package sbom renders stored dependencies as software bills of materials.
+package sbom
+
+import (
+	"crypto/rand"
+	"deps-dev/storage"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const ToolName = "deps-dev-backend"
+
+const RelationSelf = "SELF"
+
+// Info identifies a generated document.
+type Info struct {
+	// ID is a random UUID, used as the CycloneDX serial number and in the
+	// SPDX document namespace.
+	ID      string
+	Created time.Time
+}
+
+func NewInfo() Info {
+	return Info{ID: newUUID(), Created: time.Now().UTC()}
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// root returns the package the dependency set was resolved for, if exactly
+// one stored dependency is marked SELF, and the remaining dependencies.
+func root(deps []storage.Dependency) (*storage.Dependency, []storage.Dependency) {
+	var (
+		roots  []storage.Dependency
+		others []storage.Dependency
+	)
+	for _, dep := range deps {
+		if strings.EqualFold(dep.Relation, RelationSelf) {
+			roots = append(roots, dep)
+		} else {
+			others = append(others, dep)
+		}
+	}
+
+	if len(roots) != 1 {
+		return nil, deps
+	}
+	return &roots[0], others
+}
+
+// sourceRepoURL turns a deps.dev project ID such as github.com/user/repo
+// into a URL.
+func sourceRepoURL(repo string) string {
+	if repo == "" || strings.Contains(repo, "://") {
+		return repo
+	}
+	return "https://" + repo
+}
@@ -0,0 +1,208 @@
+package sbom
+
+import (
+	"deps-dev/purl"
+	"deps-dev/storage"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const SPDXVersion = "SPDX-2.3"
+
+const (
+	spdxDocumentID = "SPDXRef-DOCUMENT"
+	spdxNoAssert   = "NOASSERTION"
+)
+
+type SPDXDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      SPDXCreationInfo   `json:"creationInfo"`
+	Packages          []SPDXPackage      `json:"packages"`
+	Relationships     []SPDXRelationship `json:"relationships"`
+}
+
+type SPDXCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type SPDXPackage struct {
+	Name                  string            `json:"name"`
+	SPDXID                string            `json:"SPDXID"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	LicenseConcluded      string            `json:"licenseConcluded"`
+	LicenseDeclared       string            `json:"licenseDeclared"`
+	CopyrightText         string            `json:"copyrightText"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+	ExternalRefs          []SPDXExternalRef `json:"externalRefs,omitempty"`
+}
+
+type SPDXExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type SPDXRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+var spdxIDInvalidChars = regexp.MustCompile(`[^A-Za-z0-9.]+`)
+
+// SPDX builds an SPDX document for deps. The document describes a root
+// package: the single SELF package if there is one, otherwise a stand-in
+// for the whole dependency set. The root DEPENDS_ON every dependency that
+// is not INDIRECT.
+func SPDX(deps []storage.Dependency, info Info) SPDXDocument {
+	doc := SPDXDocument{
+		SPDXVersion:       SPDXVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            spdxDocumentID,
+		Name:              ToolName + "-export",
+		DocumentNamespace: "https://spdx.org/spdxdocs/" + ToolName + "-" + info.ID,
+		CreationInfo: SPDXCreationInfo{
+			Created:  info.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + ToolName},
+		},
+		Packages:      []SPDXPackage{},
+		Relationships: []SPDXRelationship{},
+	}
+
+	ids := make(map[string]bool)
+	subject, deps := root(deps)
+
+	var rootPackage SPDXPackage
+	if subject != nil {
+		rootPackage = spdxPackage(*subject, ids)
+		doc.Name = subject.Name + "-" + subject.Version
+	} else {
+		rootPackage = SPDXPackage{
+			Name:             ToolName + " dependencies",
+			SPDXID:           spdxID("Root", ids),
+			DownloadLocation: spdxNoAssert,
+			LicenseConcluded: spdxNoAssert,
+			LicenseDeclared:  spdxNoAssert,
+			CopyrightText:    spdxNoAssert,
+		}
+	}
+	rootPackage.PrimaryPackagePurpose = "APPLICATION"
+	doc.Packages = append(doc.Packages, rootPackage)
+	doc.Relationships = append(doc.Relationships, SPDXRelationship{
+		SPDXElementID:      spdxDocumentID,
+		RelationshipType:   "DESCRIBES",
+		RelatedSPDXElement: rootPackage.SPDXID,
+	})
+
+	for _, dep := range deps {
+		pkg := spdxPackage(dep, ids)
+		doc.Packages = append(doc.Packages, pkg)
+
+		if !strings.EqualFold(dep.Relation, "INDIRECT") {
+			doc.Relationships = append(doc.Relationships, SPDXRelationship{
+				SPDXElementID:      rootPackage.SPDXID,
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: pkg.SPDXID,
+			})
+		}
+	}
+
+	return doc
+}
+
+func spdxPackage(dep storage.Dependency, ids map[string]bool) SPDXPackage {
+	pkg := SPDXPackage{
+		Name:                  dep.Name,
+		SPDXID:                spdxID("Package-"+dep.System+"-"+dep.Name+"-"+dep.Version, ids),
+		VersionInfo:           dep.Version,
+		DownloadLocation:      spdxNoAssert,
+		LicenseConcluded:      spdxNoAssert,
+		LicenseDeclared:       spdxNoAssert,
+		CopyrightText:         spdxNoAssert,
+		PrimaryPackagePurpose: "LIBRARY",
+	}
+	if dep.SourceRepo != "" {
+		pkg.DownloadLocation = "git+" + sourceRepoURL(dep.SourceRepo)
+	}
+	if dep.License != "" {
+		pkg.LicenseDeclared = dep.License
+	}
+	if p := purl.Format(dep.System, dep.Name, dep.Version); p != "" {
+		pkg.ExternalRefs = []SPDXExternalRef{
+			{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: p},
+		}
+	}
+	return pkg
+}
+
+// spdxID returns a unique SPDX identifier derived from name.
+func spdxID(name string, ids map[string]bool) string {
+	id := "SPDXRef-" + strings.Trim(spdxIDInvalidChars.ReplaceAllString(name, "-"), "-")
+	unique := id
+	for i := 2; ids[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", id, i)
+	}
+	ids[unique] = true
+	return unique
+}
+
+// WriteTagValue writes doc in the SPDX tag-value format.
+func (doc SPDXDocument) WriteTagValue(w io.Writer) error {
+	var sb strings.Builder
+	tag := func(name, value string) {
+		sb.WriteString(name)
+		sb.WriteString(": ")
+		sb.WriteString(value)
+		sb.WriteString("\n")
+	}
+
+	tag("SPDXVersion", doc.SPDXVersion)
+	tag("DataLicense", doc.DataLicense)
+	tag("SPDXID", doc.SPDXID)
+	tag("DocumentName", doc.Name)
+	tag("DocumentNamespace", doc.DocumentNamespace)
+	for _, creator := range doc.CreationInfo.Creators {
+		tag("Creator", creator)
+	}
+	tag("Created", doc.CreationInfo.Created)
+
+	for _, pkg := range doc.Packages {
+		sb.WriteString("\n")
+		tag("PackageName", pkg.Name)
+		tag("SPDXID", pkg.SPDXID)
+		if pkg.VersionInfo != "" {
+			tag("PackageVersion", pkg.VersionInfo)
+		}
+		tag("PackageDownloadLocation", pkg.DownloadLocation)
+		tag("FilesAnalyzed", fmt.Sprint(pkg.FilesAnalyzed))
+		tag("PackageLicenseConcluded", pkg.LicenseConcluded)
+		tag("PackageLicenseDeclared", pkg.LicenseDeclared)
+		tag("PackageCopyrightText", pkg.CopyrightText)
+		if pkg.PrimaryPackagePurpose != "" {
+			tag("PrimaryPackagePurpose", pkg.PrimaryPackagePurpose)
+		}
+		for _, ref := range pkg.ExternalRefs {
+			tag("ExternalRef", ref.ReferenceCategory+" "+ref.ReferenceType+" "+ref.ReferenceLocator)
+		}
+	}
+
+	if len(doc.Relationships) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, rel := range doc.Relationships {
+		tag("Relationship", rel.SPDXElementID+" "+rel.RelationshipType+" "+rel.RelatedSPDXElement)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
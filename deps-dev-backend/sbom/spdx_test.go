@@ -0,0 +1,138 @@
+package sbom
+
+import (
+	"bytes"
+	"deps-dev/storage"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSPDX(t *testing.T) {
+	deps := []storage.Dependency{
+		{System: "NPM", Name: "react", Version: "18.2.0", Relation: "SELF", SourceRepo: "github.com/facebook/react", License: "MIT"},
+		{System: "NPM", Name: "loose-envify", Version: "1.4.0", Relation: "DIRECT", SourceRepo: "github.com/zertosh/loose-envify", License: "MIT"},
+		{System: "NPM", Name: "js-tokens", Version: "4.0.0", Relation: "INDIRECT"},
+	}
+
+	doc := SPDX(deps, testInfo)
+
+	actual, err := json.Marshal(doc)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"spdxVersion": "SPDX-2.3",
+		"dataLicense": "CC0-1.0",
+		"SPDXID": "SPDXRef-DOCUMENT",
+		"name": "react-18.2.0",
+		"documentNamespace": "https://spdx.org/spdxdocs/deps-dev-backend-3e671687-395b-41f5-a30f-a58921a69b79",
+		"creationInfo": {"created": "2024-05-01T12:00:00Z", "creators": ["Tool: deps-dev-backend"]},
+		"packages": [
+			{
+				"name": "react",
+				"SPDXID": "SPDXRef-Package-NPM-react-18.2.0",
+				"versionInfo": "18.2.0",
+				"downloadLocation": "git+https://github.com/facebook/react",
+				"filesAnalyzed": false,
+				"licenseConcluded": "NOASSERTION",
+				"licenseDeclared": "MIT",
+				"copyrightText": "NOASSERTION",
+				"primaryPackagePurpose": "APPLICATION",
+				"externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:npm/react@18.2.0"}]
+			},
+			{
+				"name": "loose-envify",
+				"SPDXID": "SPDXRef-Package-NPM-loose-envify-1.4.0",
+				"versionInfo": "1.4.0",
+				"downloadLocation": "git+https://github.com/zertosh/loose-envify",
+				"filesAnalyzed": false,
+				"licenseConcluded": "NOASSERTION",
+				"licenseDeclared": "MIT",
+				"copyrightText": "NOASSERTION",
+				"primaryPackagePurpose": "LIBRARY",
+				"externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:npm/loose-envify@1.4.0"}]
+			},
+			{
+				"name": "js-tokens",
+				"SPDXID": "SPDXRef-Package-NPM-js-tokens-4.0.0",
+				"versionInfo": "4.0.0",
+				"downloadLocation": "NOASSERTION",
+				"filesAnalyzed": false,
+				"licenseConcluded": "NOASSERTION",
+				"licenseDeclared": "NOASSERTION",
+				"copyrightText": "NOASSERTION",
+				"primaryPackagePurpose": "LIBRARY",
+				"externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:npm/js-tokens@4.0.0"}]
+			}
+		],
+		"relationships": [
+			{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-Package-NPM-react-18.2.0"},
+			{"spdxElementId": "SPDXRef-Package-NPM-react-18.2.0", "relationshipType": "DEPENDS_ON", "relatedSpdxElement": "SPDXRef-Package-NPM-loose-envify-1.4.0"}
+		]
+	}`, string(actual))
+}
+
+func TestSPDX_WithoutSingleRoot(t *testing.T) {
+	deps := []storage.Dependency{
+		{System: "NPM", Name: "@babel/core", Version: "7.22.0", Relation: "DIRECT"},
+		{System: "NPM", Name: "@babel_core", Version: "7.22.0"},
+	}
+
+	doc := SPDX(deps, testInfo)
+
+	assert.Equal(t, "deps-dev-backend-export", doc.Name)
+	assert.Len(t, doc.Packages, 3)
+	assert.Equal(t, "SPDXRef-Root", doc.Packages[0].SPDXID)
+	// Both names sanitize to the same identifier
+	assert.Equal(t, "SPDXRef-Package-NPM-babel-core-7.22.0", doc.Packages[1].SPDXID)
+	assert.Equal(t, "SPDXRef-Package-NPM-babel-core-7.22.0-2", doc.Packages[2].SPDXID)
+	assert.Equal(t, []SPDXRelationship{
+		{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: "SPDXRef-Root"},
+		{SPDXElementID: "SPDXRef-Root", RelationshipType: "DEPENDS_ON", RelatedSPDXElement: "SPDXRef-Package-NPM-babel-core-7.22.0"},
+		{SPDXElementID: "SPDXRef-Root", RelationshipType: "DEPENDS_ON", RelatedSPDXElement: "SPDXRef-Package-NPM-babel-core-7.22.0-2"},
+	}, doc.Relationships)
+}
+
+func TestSPDX_WriteTagValue(t *testing.T) {
+	deps := []storage.Dependency{
+		{System: "NPM", Name: "react", Version: "18.2.0", Relation: "SELF"},
+		{System: "NPM", Name: "loose-envify", Version: "1.4.0", Relation: "DIRECT", License: "MIT"},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, SPDX(deps, testInfo).WriteTagValue(&buf))
+
+	assert.Equal(t, `SPDXVersion: SPDX-2.3
+DataLicense: CC0-1.0
+SPDXID: SPDXRef-DOCUMENT
+DocumentName: react-18.2.0
+DocumentNamespace: https://spdx.org/spdxdocs/deps-dev-backend-3e671687-395b-41f5-a30f-a58921a69b79
+Creator: Tool: deps-dev-backend
+Created: 2024-05-01T12:00:00Z
+
+PackageName: react
+SPDXID: SPDXRef-Package-NPM-react-18.2.0
+PackageVersion: 18.2.0
+PackageDownloadLocation: NOASSERTION
+FilesAnalyzed: false
+PackageLicenseConcluded: NOASSERTION
+PackageLicenseDeclared: NOASSERTION
+PackageCopyrightText: NOASSERTION
+PrimaryPackagePurpose: APPLICATION
+ExternalRef: PACKAGE-MANAGER purl pkg:npm/react@18.2.0
+
+PackageName: loose-envify
+SPDXID: SPDXRef-Package-NPM-loose-envify-1.4.0
+PackageVersion: 1.4.0
+PackageDownloadLocation: NOASSERTION
+FilesAnalyzed: false
+PackageLicenseConcluded: NOASSERTION
+PackageLicenseDeclared: MIT
+PackageCopyrightText: NOASSERTION
+PrimaryPackagePurpose: LIBRARY
+ExternalRef: PACKAGE-MANAGER purl pkg:npm/loose-envify@1.4.0
+
+Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package-NPM-react-18.2.0
+Relationship: SPDXRef-Package-NPM-react-18.2.0 DEPENDS_ON SPDXRef-Package-NPM-loose-envify-1.4.0
+`, buf.String())
+}
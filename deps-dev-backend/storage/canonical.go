@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"deps-dev/ecosystem"
+	"fmt"
+)
+
+// keyGroup is the rows of a table whose keys canonicalize to the same package
+// version, at least one of them spelled differently. The row already stored
+// under the canonical key is kept, or else the oldest one.
+type keyGroup struct {
+	system, name, version string
+	ids                   []int64
+	keep                  int64
+}
+
+// nonCanonicalKeys groups the rows of table that are not stored under their
+// canonical key with the rows they collide with. Rows of unknown systems are
+// left as they are.
+func nonCanonicalKeys(ctx context.Context, tx *sql.Tx, table string) ([]*keyGroup, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT id, system, name, version FROM %s ORDER BY id", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make(map[string]*keyGroup)
+	var ordered []*keyGroup
+	changed := make(map[*keyGroup]bool)
+	for rows.Next() {
+		var (
+			id                    int64
+			system, name, version string
+		)
+		if err := rows.Scan(&id, &system, &name, &version); err != nil {
+			return nil, err
+		}
+		cs, cn, cv, err := ecosystem.Canonicalize(system, name, version)
+		if err != nil {
+			continue
+		}
+
+		key := DependencyKey(cs, cn, cv)
+		g, ok := groups[key]
+		if !ok {
+			g = &keyGroup{system: cs, name: cn, version: cv}
+			groups[key] = g
+			ordered = append(ordered, g)
+		}
+		g.ids = append(g.ids, id)
+		if system == cs && name == cn && version == cv {
+			g.keep = id
+		} else {
+			changed[g] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []*keyGroup
+	for _, g := range ordered {
+		if !changed[g] {
+			continue
+		}
+		if g.keep == 0 {
+			g.keep = g.ids[0]
+		}
+		result = append(result, g)
+	}
+	return result, nil
+}
+
+// deleteOthers deletes the rows of g other than the kept one.
+func (g *keyGroup) deleteOthers(ctx context.Context, tx *sql.Tx, table string) error {
+	for _, id := range g.ids {
+		if id == g.keep {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", table), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canonicalizeDependencies moves dependencies to their canonical keys.
+// Colliding rows are merged as a refresh would merge them: the values of the
+// kept row win, and the others fill in what it lacks, newer rows first.
+func (s *Storage) canonicalizeDependencies(ctx context.Context) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	groups, err := nonCanonicalKeys(ctx, tx, "dependencies")
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		var merged, kept Dependency
+		for _, id := range g.ids {
+			dep, err := scanDependency(tx.QueryRowContext(ctx, "SELECT "+dependencyColumns+" FROM dependencies WHERE id = ?", id))
+			if err != nil {
+				return err
+			}
+			if id == g.keep {
+				kept = dep
+			} else {
+				merged = MergeDependency(merged, dep)
+			}
+		}
+		merged = MergeDependency(merged, kept)
+		merged.System, merged.Name, merged.Version = g.system, g.name, g.version
+
+		if err := g.deleteOthers(ctx, tx, "dependencies"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE dependencies
+			SET system = ?, name = ?, version = ?, relation = ?, source_repo = ?, openssf_score = ?,
+				metadata_fetched_at = ?, score_fetched_at = ?, license = ?, depth = ?,
+				source_repo_provider = ?, license_provider = ?, score_provider = ?
+			WHERE id = ?`,
+			append(dependencyArgs(merged), g.keep)...,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// canonicalizeFetchFailures moves queued lookups to their canonical keys.
+// Colliding items become the kept one, still pending if any of them is, due
+// when the earliest is and with the most attempts made.
+func (s *Storage) canonicalizeFetchFailures(ctx context.Context) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	groups, err := nonCanonicalKeys(ctx, tx, "fetch_failures")
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		merged, err := scanFetchFailure(tx.QueryRowContext(ctx, selectFetchFailureColumns+" WHERE id = ?", g.keep))
+		if err != nil {
+			return err
+		}
+		for _, id := range g.ids {
+			if id == g.keep {
+				continue
+			}
+			f, err := scanFetchFailure(tx.QueryRowContext(ctx, selectFetchFailureColumns+" WHERE id = ?", id))
+			if err != nil {
+				return err
+			}
+			mergeFetchFailure(&merged, f)
+		}
+
+		if err := g.deleteOthers(ctx, tx, "fetch_failures"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE fetch_failures
+			SET system = ?, name = ?, version = ?, relation = ?, depth = ?, stage = ?, status = ?, attempts = ?,
+				last_error = ?, next_attempt_at = ?
+			WHERE id = ?`,
+			g.system, g.name, g.version, merged.Relation, merged.Depth, merged.Stage, merged.Status, merged.Attempts,
+			merged.LastError, merged.NextAttemptAt.UTC(), g.keep,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func mergeFetchFailure(merged *FetchFailure, f FetchFailure) {
+	if merged.Relation == "" {
+		merged.Relation = f.Relation
+	}
+	if merged.Depth == 0 {
+		merged.Depth = f.Depth
+	}
+	if f.Status == FailureStatusPending {
+		merged.Status = FailureStatusPending
+	}
+	if f.Attempts > merged.Attempts {
+		merged.Attempts = f.Attempts
+	}
+	if f.NextAttemptAt.Before(merged.NextAttemptAt) {
+		merged.NextAttemptAt = f.NextAttemptAt
+		merged.Stage, merged.LastError = f.Stage, f.LastError
+	}
+}
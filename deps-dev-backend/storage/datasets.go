@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Bulk metadata imported for offline lookups, see providers.Imported.
+// Version is empty for metadata that applies to every version.
+const createDatasetPackagesTable = `
+	CREATE TABLE IF NOT EXISTS dataset_packages (
+		system TEXT NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT NOT NULL DEFAULT '',
+		source_repo TEXT NOT NULL DEFAULT '',
+		license TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (system, name, version)
+	);`
+
+// Project IDs are stored in lower case, as deps.dev reports them
+const createDatasetScoresTable = `
+	CREATE TABLE IF NOT EXISTS dataset_scores (
+		project_id TEXT PRIMARY KEY,
+		openssf_score REAL NOT NULL
+	);`
+
+// Rows of dataset imports still being uploaded. They are moved into
+// dataset_packages and dataset_scores once the whole upload has been read.
+const createDatasetImportsTable = `
+	CREATE TABLE IF NOT EXISTS dataset_imports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at DATETIME NOT NULL
+	);`
+
+const createDatasetStagedPackagesTable = `
+	CREATE TABLE IF NOT EXISTS dataset_staged_packages (
+		import_id INTEGER NOT NULL,
+		system TEXT NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT NOT NULL,
+		source_repo TEXT NOT NULL,
+		license TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_dataset_staged_packages_import ON dataset_staged_packages (import_id);`
+
+const createDatasetStagedScoresTable = `
+	CREATE TABLE IF NOT EXISTS dataset_staged_scores (
+		import_id INTEGER NOT NULL,
+		project_id TEXT NOT NULL,
+		openssf_score REAL NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_dataset_staged_scores_import ON dataset_staged_scores (import_id);`
+
+// DatasetStager stages a batch of rows of a dataset import.
+type DatasetStager func(packages []DatasetPackage, scores []ProjectScore) error
+
+// ImportDataset adds a dataset to the stored one, all or nothing. load is
+// handed a DatasetStager for the rows it reads; each batch is staged in its
+// own transaction, so a large upload does not hold up other writes, and the
+// staged rows are only applied if load returns nil. Imported values replace
+// stored ones, except that an empty source repo or license keeps what is
+// stored.
+func (s *Storage) ImportDataset(ctx context.Context, load func(stage DatasetStager) error) error {
+	res, err := s.DB.ExecContext(ctx, `INSERT INTO dataset_imports (started_at) VALUES (?)`, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	importID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	err = load(func(packages []DatasetPackage, scores []ProjectScore) error {
+		return s.stageDataset(ctx, importID, packages, scores)
+	})
+	if err == nil {
+		err = s.applyDataset(ctx, importID)
+	}
+	if err != nil {
+		// Rows left behind if this fails too are dropped on the next startup
+		_ = s.discardDatasetImport(context.WithoutCancel(ctx), importID)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) stageDataset(ctx context.Context, importID int64, packages []DatasetPackage, scores []ProjectScore) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	pkgStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO dataset_staged_packages (import_id, system, name, version, source_repo, license)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer pkgStmt.Close()
+
+	for _, pkg := range packages {
+		if _, err := pkgStmt.ExecContext(ctx, importID, pkg.System, pkg.Name, pkg.Version, pkg.SourceRepo, pkg.License); err != nil {
+			return err
+		}
+	}
+
+	scoreStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO dataset_staged_scores (import_id, project_id, openssf_score)
+		VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer scoreStmt.Close()
+
+	for _, score := range scores {
+		if _, err := scoreStmt.ExecContext(ctx, importID, strings.ToLower(score.ProjectID), score.OpenSSFScore); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// applyDataset moves the staged rows of an import into the dataset, in the
+// order they were staged.
+func (s *Storage) applyDataset(ctx context.Context, importID int64) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{`
+		INSERT INTO dataset_packages (system, name, version, source_repo, license)
+		SELECT system, name, version, source_repo, license
+		FROM dataset_staged_packages WHERE import_id = ? ORDER BY rowid
+		ON CONFLICT(system, name, version)
+		DO UPDATE SET
+			source_repo = CASE WHEN excluded.source_repo != '' THEN excluded.source_repo ELSE source_repo END,
+			license = CASE WHEN excluded.license != '' THEN excluded.license ELSE license END`, `
+		INSERT INTO dataset_scores (project_id, openssf_score)
+		SELECT project_id, openssf_score
+		FROM dataset_staged_scores WHERE import_id = ? ORDER BY rowid
+		ON CONFLICT(project_id) DO UPDATE SET openssf_score = excluded.openssf_score`,
+		`DELETE FROM dataset_staged_packages WHERE import_id = ?`,
+		`DELETE FROM dataset_staged_scores WHERE import_id = ?`,
+		`DELETE FROM dataset_imports WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, importID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) discardDatasetImport(ctx context.Context, importID int64) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM dataset_staged_packages WHERE import_id = ?`,
+		`DELETE FROM dataset_staged_scores WHERE import_id = ?`,
+		`DELETE FROM dataset_imports WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, importID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// discardInterruptedDatasetImports drops the staged rows of imports that
+// were still being uploaded when the server stopped.
+func (s *Storage) discardInterruptedDatasetImports(ctx context.Context) error {
+	for _, query := range []string{
+		`DELETE FROM dataset_staged_packages`,
+		`DELETE FROM dataset_staged_scores`,
+		`DELETE FROM dataset_imports`,
+	} {
+		if _, err := s.DB.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDatasetPackage returns the imported metadata of a package version,
+// falling back to the metadata for every version, or sql.ErrNoRows.
+func (s *Storage) GetDatasetPackage(ctx context.Context, system, name, version string) (DatasetPackage, error) {
+	var pkg DatasetPackage
+	err := s.reader().QueryRowContext(ctx, `
+		SELECT system, name, version, source_repo, license
+		FROM dataset_packages
+		WHERE system = ? AND name = ? AND version IN (?, '')
+		ORDER BY version DESC
+		LIMIT 1`,
+		system, name, version,
+	).Scan(&pkg.System, &pkg.Name, &pkg.Version, &pkg.SourceRepo, &pkg.License)
+	return pkg, err
+}
+
+// GetProjectScore returns the imported score of a project, or sql.ErrNoRows.
+func (s *Storage) GetProjectScore(ctx context.Context, projectID string) (float64, error) {
+	var score float64
+	err := s.reader().QueryRowContext(ctx,
+		`SELECT openssf_score FROM dataset_scores WHERE project_id = ?`,
+		strings.ToLower(projectID),
+	).Scan(&score)
+	return score, err
+}
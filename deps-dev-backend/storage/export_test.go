@@ -0,0 +1,6 @@
+package storage
+
+// Unexported query builders, for the tests in package storage_test
+var LookupDependenciesQuery = lookupDependenciesQuery
+
+const LookupChunkSize = lookupChunkSize
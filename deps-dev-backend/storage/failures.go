@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	FailureStatusPending   = "PENDING"
+	FailureStatusExhausted = "EXHAUSTED"
+
+	FailureStageMetadata  = "METADATA"
+	FailureStageScorecard = "SCORECARD"
+)
+
+const createFetchFailuresTable = `
+	CREATE TABLE IF NOT EXISTS fetch_failures (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		system TEXT NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT NOT NULL,
+		relation TEXT,
+		depth INTEGER NOT NULL DEFAULT 0,
+		stage TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		next_attempt_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		UNIQUE(system, name, version)
+	);`
+
+// A failure recorded again for the same dependency (e.g. by the next scheduled
+// refresh) counts as another attempt and puts the item back into the queue.
+const recordFetchFailureQuery = `
+	INSERT INTO fetch_failures (system, name, version, relation, depth, stage, status, attempts, last_error, next_attempt_at, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+	ON CONFLICT(system, name, version)
+	DO UPDATE SET
+		relation = excluded.relation,
+		depth = excluded.depth,
+		stage = excluded.stage,
+		status = excluded.status,
+		attempts = fetch_failures.attempts + 1,
+		last_error = excluded.last_error,
+		next_attempt_at = excluded.next_attempt_at,
+		updated_at = excluded.updated_at;
+`
+
+const selectFetchFailureColumns = `
+	SELECT id, system, name, version, relation, depth, stage, status, attempts, last_error, next_attempt_at, created_at, updated_at
+	FROM fetch_failures
+`
+
+func (s *Storage) RecordFetchFailure(ctx context.Context, f FetchFailure) error {
+	now := time.Now().UTC()
+	_, err := s.DB.ExecContext(ctx, recordFetchFailureQuery,
+		f.System,
+		f.Name,
+		f.Version,
+		f.Relation,
+		f.Depth,
+		f.Stage,
+		FailureStatusPending,
+		f.LastError,
+		f.NextAttemptAt.UTC(),
+		now,
+		now,
+	)
+	return err
+}
+
+func (s *Storage) ListFetchFailures(ctx context.Context) ([]FetchFailure, error) {
+	return s.queryFetchFailures(ctx, selectFetchFailureColumns+" ORDER BY next_attempt_at, system, name, version")
+}
+
+func (s *Storage) DueFetchFailures(ctx context.Context, now time.Time, limit int) ([]FetchFailure, error) {
+	return s.queryFetchFailures(ctx,
+		selectFetchFailureColumns+" WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?",
+		FailureStatusPending, now.UTC(), limit,
+	)
+}
+
+func (s *Storage) RescheduleFetchFailure(ctx context.Context, f FetchFailure) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE fetch_failures
+		SET stage = ?, status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+		WHERE id = ?`,
+		f.Stage, f.Status, f.Attempts, f.LastError, f.NextAttemptAt.UTC(), time.Now().UTC(), f.ID,
+	)
+	return err
+}
+
+// ClearFetchFailures removes queued failures for dependencies that have since
+// been fetched successfully.
+func (s *Storage) ClearFetchFailures(ctx context.Context, deps []Dependency) error {
+	if len(deps) == 0 {
+		return nil
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `DELETE FROM fetch_failures WHERE system=? AND name=? AND version=?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, dep := range deps {
+		if _, err := stmt.ExecContext(ctx, dep.System, dep.Name, dep.Version); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) queryFetchFailures(ctx context.Context, query string, args ...any) ([]FetchFailure, error) {
+	rows, err := s.reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []FetchFailure
+	for rows.Next() {
+		f, err := scanFetchFailure(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
+
+func scanFetchFailure(row rowScanner) (FetchFailure, error) {
+	var f FetchFailure
+	err := row.Scan(&f.ID, &f.System, &f.Name, &f.Version, &f.Relation, &f.Depth, &f.Stage, &f.Status,
+		&f.Attempts, &f.LastError, &f.NextAttemptAt, &f.CreatedAt, &f.UpdatedAt)
+	return f, err
+}
@@ -1,10 +1,169 @@
 package storage
 
+import (
+	"fmt"
+	"time"
+)
+
 type Dependency struct {
-	System       string   `json:"system"`
-	Name         string   `json:"name"`
-	Version      string   `json:"version"`
+	System  string `json:"system"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// PURL is the package URL of the version, derived from the key on read
+	PURL         string   `json:"purl,omitempty"`
 	Relation     string   `json:"relation,omitempty"`
 	SourceRepo   string   `json:"source_repo,omitempty"`
 	OpenSSFScore *float64 `json:"openssf_score,omitempty"`
+	// License is an SPDX license expression, as reported by deps.dev
+	License string `json:"license,omitempty"`
+	// Depth is the shortest distance from the project in the dependency
+	// graph, 1 for DIRECT dependencies; 0 if unknown
+	Depth int `json:"depth,omitempty"`
+	// SourceRepoProvider, LicenseProvider and ScoreProvider name the metadata
+	// provider that supplied each value, or ProviderManual if it was set
+	// through the API
+	SourceRepoProvider string `json:"source_repo_provider,omitempty"`
+	LicenseProvider    string `json:"license_provider,omitempty"`
+	ScoreProvider      string `json:"openssf_score_provider,omitempty"`
+
+	MetadataFetchedAt *time.Time `json:"metadata_fetched_at,omitempty"`
+	ScoreFetchedAt    *time.Time `json:"score_fetched_at,omitempty"`
+}
+
+// ProviderManual is the provenance of values entered through the API.
+const ProviderManual = "manual"
+
+// DependencyKey identifies a dependency in maps keyed by system, name and version.
+func DependencyKey(system, name, version string) string {
+	return fmt.Sprintf("%s|%s|%s", system, name, version)
+}
+
+// MergeDependency copies non-empty fields from incoming into existing,
+// together with the providers of the copied values.
+func MergeDependency(existing, incoming Dependency) Dependency {
+	merged := existing
+	if incoming.Relation != "" {
+		merged.Relation = incoming.Relation
+	}
+	if incoming.SourceRepo != "" {
+		merged.SourceRepo = incoming.SourceRepo
+		merged.SourceRepoProvider = incoming.SourceRepoProvider
+	}
+	if incoming.License != "" {
+		merged.License = incoming.License
+		merged.LicenseProvider = incoming.LicenseProvider
+	}
+	if incoming.Depth != 0 {
+		merged.Depth = incoming.Depth
+	}
+	if incoming.OpenSSFScore != nil {
+		merged.OpenSSFScore = incoming.OpenSSFScore
+		merged.ScoreProvider = incoming.ScoreProvider
+	}
+	if incoming.MetadataFetchedAt != nil {
+		merged.MetadataFetchedAt = incoming.MetadataFetchedAt
+	}
+	if incoming.ScoreFetchedAt != nil {
+		merged.ScoreFetchedAt = incoming.ScoreFetchedAt
+	}
+	return merged
+}
+
+type FetchFailure struct {
+	ID            int64     `json:"id"`
+	System        string    `json:"system"`
+	Name          string    `json:"name"`
+	Version       string    `json:"version"`
+	Relation      string    `json:"relation,omitempty"`
+	Depth         int       `json:"depth,omitempty"`
+	Stage         string    `json:"stage"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type RefreshRun struct {
+	ID        int64     `json:"id"`
+	System    string    `json:"system"`
+	Name      string    `json:"name"`
+	Version   string    `json:"version"`
+	Status    string    `json:"status"`
+	Total     int       `json:"total"`
+	Processed int       `json:"processed"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// PolicyViolation records a stored dependency breaking a policy rule.
+// Dependency carries only the key and purl of the dependency.
+type PolicyViolation struct {
+	Rule       string     `json:"rule"`
+	Dependency Dependency `json:"dependency"`
+	Reason     string     `json:"reason"`
+	// DetectedAt is when the violation was first found; it is kept while
+	// the violation persists across evaluations
+	DetectedAt time.Time `json:"detected_at"`
+	// WaiverID is the active waiver suppressing the violation, if any. It is
+	// not stored, since waivers lapse between evaluations.
+	WaiverID *int64 `json:"waiver_id,omitempty"`
+}
+
+// Waiver is a time-boxed exception to a policy rule for a package, or for one
+// version of it if Version is set.
+type Waiver struct {
+	ID         int64     `json:"id"`
+	Rule       string    `json:"rule"`
+	System     string    `json:"system"`
+	Name       string    `json:"name"`
+	Version    string    `json:"version,omitempty"`
+	Reason     string    `json:"reason"`
+	ApprovedBy string    `json:"approved_by"`
+	Ticket     string    `json:"ticket,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Active reports whether the waiver has not expired at now.
+func (w Waiver) Active(now time.Time) bool {
+	return now.Before(w.ExpiresAt)
+}
+
+// DatasetPackage is imported metadata for a package version, or for every
+// version of the package if Version is empty.
+type DatasetPackage struct {
+	System     string `json:"system"`
+	Name       string `json:"name"`
+	Version    string `json:"version,omitempty"`
+	SourceRepo string `json:"source_repo,omitempty"`
+	License    string `json:"license,omitempty"`
+}
+
+// ProjectScore is an imported OpenSSF score of a source repository project.
+type ProjectScore struct {
+	ProjectID    string  `json:"project_id"`
+	OpenSSFScore float64 `json:"openssf_score"`
+}
+
+// RefreshSchedule refreshes a package version on a cron schedule in its
+// timezone.
+type RefreshSchedule struct {
+	ID        int64      `json:"id"`
+	System    string     `json:"system"`
+	Name      string     `json:"name"`
+	Version   string     `json:"version"`
+	Cron      string     `json:"cron"`
+	Timezone  string     `json:"timezone"`
+	Enabled   bool       `json:"enabled"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	// LastStatus is ScheduleRunSucceeded or ScheduleRunFailed, empty until
+	// the first run
+	LastStatus string `json:"last_status,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+	// NextRunAt is unset while the schedule is disabled
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+)
+
+// Open opens the SQLite database at path in WAL mode with a single writer
+// connection and a pool of read-only connections, so reads are not blocked
+// while a refresh holds the write transaction.
+func Open(path string, readConns int, busyTimeoutMs int) (*Storage, error) {
+	writeParams := url.Values{}
+	writeParams.Set("_journal_mode", "WAL")
+	writeParams.Set("_synchronous", "NORMAL")
+	writeParams.Set("_busy_timeout", fmt.Sprint(busyTimeoutMs))
+	// Take the write lock when the transaction starts instead of failing with
+	// SQLITE_BUSY when a deferred transaction tries to upgrade it
+	writeParams.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite3", fileURI(path, writeParams))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	// The writer creates the database file and switches it to WAL before any
+	// read-only connection opens it
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	readParams := url.Values{}
+	readParams.Set("mode", "ro")
+	readParams.Set("_busy_timeout", fmt.Sprint(busyTimeoutMs))
+
+	readDB, err := sql.Open("sqlite3", fileURI(path, readParams))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	readDB.SetMaxOpenConns(readConns)
+	readDB.SetMaxIdleConns(readConns)
+
+	return &Storage{DB: db, ReadDB: readDB}, nil
+}
+
+// fileURI builds the SQLite URI of the database at path, escaping characters
+// such as ? and # that would otherwise end the path.
+func fileURI(path string, params url.Values) string {
+	u := url.URL{Scheme: "file", Opaque: url.PathEscape(path), RawQuery: params.Encode()}
+	return u.String()
+}
+
+func (s *Storage) Close() error {
+	if s.ReadDB != nil {
+		if err := s.ReadDB.Close(); err != nil {
+			s.DB.Close()
+			return err
+		}
+	}
+	return s.DB.Close()
+}
+
+// reader returns the read-only pool, or the writer when the storage was
+// created without one (e.g. for an in-memory database).
+func (s *Storage) reader() *sql.DB {
+	if s.ReadDB != nil {
+		return s.ReadDB
+	}
+	return s.DB
+}
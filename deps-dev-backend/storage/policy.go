@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"deps-dev/purl"
+	"errors"
+	"time"
+)
+
+// The policy is a single YAML or JSON document, parsed by the policy package
+const createPoliciesTable = `
+	CREATE TABLE IF NOT EXISTS policies (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		document TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
+const createPolicyViolationsTable = `
+	CREATE TABLE IF NOT EXISTS policy_violations (
+		rule TEXT NOT NULL,
+		system TEXT NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		detected_at DATETIME NOT NULL,
+		evaluated_at DATETIME NOT NULL,
+		PRIMARY KEY (rule, system, name, version)
+	);`
+
+// GetPolicyDocument returns the stored policy document, or "" if none was saved.
+func (s *Storage) GetPolicyDocument(ctx context.Context) (string, error) {
+	var document string
+	err := s.reader().QueryRowContext(ctx, `SELECT document FROM policies WHERE id = 1`).Scan(&document)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return document, err
+}
+
+func (s *Storage) SavePolicyDocument(ctx context.Context, document string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO policies (id, document, updated_at) VALUES (1, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET document = excluded.document, updated_at = excluded.updated_at`,
+		document, time.Now().UTC())
+	return err
+}
+
+// ReplacePolicyViolations stores the violations of an evaluation finished at
+// now, dropping those it no longer found. Violations found before keep their
+// DetectedAt.
+func (s *Storage) ReplacePolicyViolations(ctx context.Context, violations []PolicyViolation, now time.Time) error {
+	now = now.UTC()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO policy_violations (rule, system, name, version, reason, detected_at, evaluated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(rule, system, name, version)
+		DO UPDATE SET
+			reason = excluded.reason,
+			evaluated_at = excluded.evaluated_at;`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, v := range violations {
+		dep := v.Dependency
+		if _, err := stmt.ExecContext(ctx, v.Rule, dep.System, dep.Name, dep.Version, v.Reason, now, now); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM policy_violations WHERE evaluated_at != ?`, now); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListPolicyViolations lists the current violations, of one rule if rule is set.
+func (s *Storage) ListPolicyViolations(ctx context.Context, rule string) ([]PolicyViolation, error) {
+	query := `
+		SELECT rule, system, name, version, reason, detected_at
+		FROM policy_violations
+	`
+	var args []any
+	if rule != "" {
+		query += " WHERE rule = ?"
+		args = append(args, rule)
+	}
+	query += " ORDER BY rule, system, name, version"
+
+	rows, err := s.reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []PolicyViolation{}
+	for rows.Next() {
+		var v PolicyViolation
+		dep := &v.Dependency
+		if err := rows.Scan(&v.Rule, &dep.System, &dep.Name, &dep.Version, &v.Reason, &v.DetectedAt); err != nil {
+			return nil, err
+		}
+		dep.PURL = purl.Format(dep.System, dep.Name, dep.Version)
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
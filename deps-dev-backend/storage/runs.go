@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	RunStatusRunning   = "RUNNING"
+	RunStatusCompleted = "COMPLETED"
+	RunStatusFailed    = "FAILED"
+	RunStatusAbandoned = "ABANDONED"
+)
+
+const createRefreshRunsTable = `
+	CREATE TABLE IF NOT EXISTS refresh_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		system TEXT NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT NOT NULL,
+		status TEXT NOT NULL,
+		total INTEGER NOT NULL DEFAULT 0,
+		processed INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME
+	);`
+
+// Dependencies already written by a run, so a resumed run can skip them.
+const createRefreshRunItemsTable = `
+	CREATE TABLE IF NOT EXISTS refresh_run_items (
+		run_id INTEGER NOT NULL,
+		system TEXT NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT NOT NULL,
+		PRIMARY KEY (run_id, system, name, version)
+	);`
+
+// ResumableRefreshRun returns the latest failed run for the root package
+// started after since, or nil if there is none. Running runs belong to a
+// refresh still in progress and are never resumed.
+func (s *Storage) ResumableRefreshRun(ctx context.Context, system, name, version string, since time.Time) (*RefreshRun, error) {
+	var run RefreshRun
+	err := s.reader().QueryRowContext(ctx, `
+		SELECT id, system, name, version, status, total, processed, started_at
+		FROM refresh_runs
+		WHERE system=? AND name=? AND version=? AND status = ? AND started_at >= ?
+		ORDER BY started_at DESC, id DESC
+		LIMIT 1`,
+		system, name, version, RunStatusFailed, since.UTC(),
+	).Scan(&run.ID, &run.System, &run.Name, &run.Version, &run.Status, &run.Total, &run.Processed, &run.StartedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// CreateRefreshRun starts a new run for the root package, abandoning any
+// failed earlier ones.
+func (s *Storage) CreateRefreshRun(ctx context.Context, system, name, version string, total int) (RefreshRun, error) {
+	run := RefreshRun{
+		System:    system,
+		Name:      name,
+		Version:   version,
+		Status:    RunStatusRunning,
+		Total:     total,
+		StartedAt: time.Now().UTC(),
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return run, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_runs SET status = ?, finished_at = ?
+		WHERE system=? AND name=? AND version=? AND status = ?`,
+		RunStatusAbandoned, run.StartedAt, system, name, version, RunStatusFailed,
+	); err != nil {
+		return run, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO refresh_runs (system, name, version, status, total, started_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		system, name, version, run.Status, total, run.StartedAt,
+	)
+	if err != nil {
+		return run, err
+	}
+	if run.ID, err = res.LastInsertId(); err != nil {
+		return run, err
+	}
+
+	return run, tx.Commit()
+}
+
+// FailInterruptedRefreshRuns marks runs left running by a previous process as
+// failed, so they can be resumed. It must be called before any refresh starts
+// and returns the number of runs marked.
+func (s *Storage) FailInterruptedRefreshRuns(ctx context.Context) (int64, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE refresh_runs SET status = ?, error = ?, finished_at = ? WHERE status = ?`,
+		RunStatusFailed, "interrupted by shutdown", time.Now().UTC(), RunStatusRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Storage) RefreshRunItems(ctx context.Context, runID int64) (map[string]bool, error) {
+	rows, err := s.reader().QueryContext(ctx,
+		`SELECT system, name, version FROM refresh_run_items WHERE run_id = ?`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[string]bool)
+	for rows.Next() {
+		var system, name, version string
+		if err := rows.Scan(&system, &name, &version); err != nil {
+			return nil, err
+		}
+		done[DependencyKey(system, name, version)] = true
+	}
+	return done, rows.Err()
+}
+
+// CheckpointRefreshRun marks dependencies as processed by the run.
+func (s *Storage) CheckpointRefreshRun(ctx context.Context, runID int64, deps []Dependency) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO refresh_run_items (run_id, system, name, version)
+		VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, dep := range deps {
+		if _, err := stmt.ExecContext(ctx, runID, dep.System, dep.Name, dep.Version); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_runs
+		SET processed = (SELECT COUNT(*) FROM refresh_run_items WHERE run_id = ?)
+		WHERE id = ?`,
+		runID, runID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FinishRefreshRun records the outcome of a run. Checkpoints of completed runs
+// are no longer needed and are dropped.
+func (s *Storage) FinishRefreshRun(ctx context.Context, runID int64, status, errMsg string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE refresh_runs SET status = ?, error = ?, finished_at = ? WHERE id = ?`,
+		status, errMsg, time.Now().UTC(), runID,
+	); err != nil {
+		return err
+	}
+
+	if status == RunStatusCompleted {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM refresh_run_items WHERE run_id = ?`, runID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	ScheduleRunSucceeded = "succeeded"
+	ScheduleRunFailed    = "failed"
+)
+
+// ErrScheduleExists is returned when a package version already has a schedule.
+var ErrScheduleExists = errors.New("package already has a refresh schedule")
+
+const createRefreshSchedulesTable = `
+	CREATE TABLE IF NOT EXISTS refresh_schedules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		system TEXT NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT NOT NULL,
+		cron TEXT NOT NULL,
+		timezone TEXT NOT NULL DEFAULT 'UTC',
+		enabled BOOLEAN NOT NULL DEFAULT 1,
+		last_run_at DATETIME,
+		last_status TEXT NOT NULL DEFAULT '',
+		last_error TEXT NOT NULL DEFAULT '',
+		next_run_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		UNIQUE(system, name, version)
+	);`
+
+const selectScheduleColumns = `
+	SELECT id, system, name, version, cron, timezone, enabled, last_run_at, last_status, last_error, next_run_at, created_at, updated_at
+	FROM refresh_schedules
+`
+
+// CreateRefreshSchedule stores a new schedule and returns it with its ID and
+// timestamps, or ErrScheduleExists.
+func (s *Storage) CreateRefreshSchedule(ctx context.Context, rs RefreshSchedule) (RefreshSchedule, error) {
+	now := time.Now().UTC()
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO refresh_schedules (system, name, version, cron, timezone, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		rs.System, rs.Name, rs.Version, rs.Cron, rs.Timezone, rs.Enabled, now, now,
+	)
+	if err != nil {
+		return RefreshSchedule{}, scheduleError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return RefreshSchedule{}, err
+	}
+	return s.GetRefreshSchedule(ctx, id)
+}
+
+// GetRefreshSchedule returns the schedule with the given ID, or sql.ErrNoRows.
+func (s *Storage) GetRefreshSchedule(ctx context.Context, id int64) (RefreshSchedule, error) {
+	return scanSchedule(s.reader().QueryRowContext(ctx, selectScheduleColumns+" WHERE id = ?", id))
+}
+
+// UpdateRefreshSchedule replaces the package, cron expression, timezone and
+// enabled flag of the schedule with rs.ID, keeping its run history. It
+// returns sql.ErrNoRows if there is none, or ErrScheduleExists.
+func (s *Storage) UpdateRefreshSchedule(ctx context.Context, rs RefreshSchedule) (RefreshSchedule, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE refresh_schedules
+		SET system = ?, name = ?, version = ?, cron = ?, timezone = ?, enabled = ?, updated_at = ?
+		WHERE id = ?`,
+		rs.System, rs.Name, rs.Version, rs.Cron, rs.Timezone, rs.Enabled, time.Now().UTC(), rs.ID,
+	)
+	if err != nil {
+		return RefreshSchedule{}, scheduleError(err)
+	}
+	if err := expectRow(res); err != nil {
+		return RefreshSchedule{}, err
+	}
+	return s.GetRefreshSchedule(ctx, rs.ID)
+}
+
+// DeleteRefreshSchedule removes a schedule, or returns sql.ErrNoRows if there
+// is none.
+func (s *Storage) DeleteRefreshSchedule(ctx context.Context, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM refresh_schedules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+// ListRefreshSchedules lists all schedules, disabled ones included.
+func (s *Storage) ListRefreshSchedules(ctx context.Context) ([]RefreshSchedule, error) {
+	rows, err := s.reader().QueryContext(ctx, selectScheduleColumns+" ORDER BY system, name, version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []RefreshSchedule{}
+	for rows.Next() {
+		rs, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, rs)
+	}
+	return list, rows.Err()
+}
+
+// RecordScheduleRun stores the outcome of a scheduled refresh started at
+// ranAt, failed if runErr is set.
+func (s *Storage) RecordScheduleRun(ctx context.Context, id int64, ranAt time.Time, runErr error) error {
+	status, lastError := ScheduleRunSucceeded, ""
+	if runErr != nil {
+		status, lastError = ScheduleRunFailed, runErr.Error()
+	}
+
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE refresh_schedules
+		SET last_run_at = ?, last_status = ?, last_error = ?
+		WHERE id = ?`,
+		ranAt.UTC(), status, lastError, id,
+	)
+	return err
+}
+
+// SetScheduleNextRun stores when the schedule runs next, nil if it does not.
+func (s *Storage) SetScheduleNextRun(ctx context.Context, id int64, next *time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `UPDATE refresh_schedules SET next_run_at = ? WHERE id = ?`, utcPtr(next), id)
+	return err
+}
+
+func scanSchedule(row rowScanner) (RefreshSchedule, error) {
+	var rs RefreshSchedule
+	err := row.Scan(&rs.ID, &rs.System, &rs.Name, &rs.Version, &rs.Cron, &rs.Timezone, &rs.Enabled,
+		&rs.LastRunAt, &rs.LastStatus, &rs.LastError, &rs.NextRunAt, &rs.CreatedAt, &rs.UpdatedAt)
+	return rs, err
+}
+
+func scheduleError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrScheduleExists
+	}
+	return err
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
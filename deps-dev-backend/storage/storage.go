@@ -3,16 +3,18 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"deps-dev/purl"
 	"fmt"
 	"strings"
 )
 
 type Storage struct {
 	DB *sql.DB
+	// ReadDB is an optional pool of read-only connections used for queries
+	ReadDB *sql.DB
 }
 
-func (s *Storage) InitSchema(ctx context.Context) error {
-	query := `
+const createDependenciesTable = `
 	CREATE TABLE IF NOT EXISTS dependencies (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		system TEXT NOT NULL,
@@ -21,22 +23,149 @@ func (s *Storage) InitSchema(ctx context.Context) error {
 		relation TEXT,
 		source_repo TEXT,
 		openssf_score REAL,
+		metadata_fetched_at DATETIME,
+		score_fetched_at DATETIME,
+		license TEXT NOT NULL DEFAULT '',
+		depth INTEGER NOT NULL DEFAULT 0,
+		source_repo_provider TEXT NOT NULL DEFAULT '',
+		license_provider TEXT NOT NULL DEFAULT '',
+		score_provider TEXT NOT NULL DEFAULT '',
 		UNIQUE(system, name, version)
 	);`
-	_, err := s.DB.ExecContext(ctx, query)
+
+func (s *Storage) InitSchema(ctx context.Context) error {
+	for _, query := range []string{
+		createDependenciesTable,
+		createFetchFailuresTable,
+		createRefreshRunsTable,
+		createRefreshRunItemsTable,
+		createPoliciesTable,
+		createPolicyViolationsTable,
+		createWaiversTable,
+		createDatasetPackagesTable,
+		createDatasetScoresTable,
+		createDatasetImportsTable,
+		createDatasetStagedPackagesTable,
+		createDatasetStagedScoresTable,
+		createRefreshSchedulesTable,
+	} {
+		if _, err := s.DB.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	// Columns added after the first release, missing from existing databases
+	for _, col := range []struct{ table, name, definition string }{
+		{"dependencies", "metadata_fetched_at", "DATETIME"},
+		{"dependencies", "score_fetched_at", "DATETIME"},
+		{"dependencies", "license", "TEXT NOT NULL DEFAULT ''"},
+		{"dependencies", "depth", "INTEGER NOT NULL DEFAULT 0"},
+		{"dependencies", "source_repo_provider", "TEXT NOT NULL DEFAULT ''"},
+		{"dependencies", "license_provider", "TEXT NOT NULL DEFAULT ''"},
+		{"dependencies", "score_provider", "TEXT NOT NULL DEFAULT ''"},
+		{"fetch_failures", "depth", "INTEGER NOT NULL DEFAULT 0"},
+	} {
+		if err := s.addColumnIfMissing(ctx, col.table, col.name, col.definition); err != nil {
+			return fmt.Errorf("migrating %s.%s: %w", col.table, col.name, err)
+		}
+	}
+
+	// Keys were stored as sent before they were canonicalized
+	if err := s.canonicalizeDependencies(ctx); err != nil {
+		return fmt.Errorf("migrating dependency keys: %w", err)
+	}
+	if err := s.canonicalizeFetchFailures(ctx); err != nil {
+		return fmt.Errorf("migrating fetch failure keys: %w", err)
+	}
+
+	if err := s.discardInterruptedDatasetImports(ctx); err != nil {
+		return fmt.Errorf("discarding interrupted dataset imports: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) addColumnIfMissing(ctx context.Context, table, column, definition string) error {
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = s.DB.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
 }
 
+const dependencyColumns = `system, name, version, relation, source_repo, openssf_score, metadata_fetched_at, score_fetched_at, license, depth,
+	source_repo_provider, license_provider, score_provider`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDependency(row rowScanner) (Dependency, error) {
+	var d Dependency
+	err := row.Scan(&d.System, &d.Name, &d.Version, &d.Relation, &d.SourceRepo, &d.OpenSSFScore,
+		&d.MetadataFetchedAt, &d.ScoreFetchedAt, &d.License, &d.Depth,
+		&d.SourceRepoProvider, &d.LicenseProvider, &d.ScoreProvider)
+	if err == nil {
+		d.PURL = purl.Format(d.System, d.Name, d.Version)
+	}
+	return d, err
+}
+
 const upsertDependencyQuery = `
-  INSERT INTO dependencies (system, name, version, relation, source_repo, openssf_score)
-  VALUES (?, ?, ?, ?, ?, ?)
+  INSERT INTO dependencies (` + dependencyColumns + `)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
   ON CONFLICT(system, name, version)
   DO UPDATE SET
     relation = excluded.relation,
     source_repo = excluded.source_repo,
-    openssf_score = excluded.openssf_score;
+    openssf_score = excluded.openssf_score,
+    metadata_fetched_at = excluded.metadata_fetched_at,
+    score_fetched_at = excluded.score_fetched_at,
+    license = excluded.license,
+    depth = excluded.depth,
+    source_repo_provider = excluded.source_repo_provider,
+    license_provider = excluded.license_provider,
+    score_provider = excluded.score_provider;
 `
 
+func dependencyArgs(dep Dependency) []any {
+	return []any{
+		dep.System,
+		dep.Name,
+		dep.Version,
+		dep.Relation,
+		dep.SourceRepo,
+		dep.OpenSSFScore,
+		dep.MetadataFetchedAt,
+		dep.ScoreFetchedAt,
+		dep.License,
+		dep.Depth,
+		dep.SourceRepoProvider,
+		dep.LicenseProvider,
+		dep.ScoreProvider,
+	}
+}
+
 func (s *Storage) UpsertDependencies(ctx context.Context, deps []Dependency) error {
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
@@ -51,14 +180,7 @@ func (s *Storage) UpsertDependencies(ctx context.Context, deps []Dependency) err
 	defer stmt.Close()
 
 	for _, dep := range deps {
-		if _, err := stmt.ExecContext(ctx,
-			dep.System,
-			dep.Name,
-			dep.Version,
-			dep.Relation,
-			dep.SourceRepo,
-			dep.OpenSSFScore,
-		); err != nil {
+		if _, err := stmt.ExecContext(ctx, dependencyArgs(dep)...); err != nil {
 			return err
 		}
 	}
@@ -67,32 +189,36 @@ func (s *Storage) UpsertDependencies(ctx context.Context, deps []Dependency) err
 }
 
 func (s *Storage) UpsertDependency(ctx context.Context, dep Dependency) error {
-	_, err := s.DB.ExecContext(ctx, upsertDependencyQuery,
-		dep.System,
-		dep.Name,
-		dep.Version,
-		dep.Relation,
-		dep.SourceRepo,
-		dep.OpenSSFScore,
-	)
-
+	_, err := s.DB.ExecContext(ctx, upsertDependencyQuery, dependencyArgs(dep)...)
 	return err
 }
 
 func (s *Storage) GetDependency(ctx context.Context, system, name, version string) (Dependency, error) {
-	var d Dependency
-	err := s.DB.QueryRowContext(ctx,
-		`SELECT system, name, version, relation, source_repo, openssf_score
+	return scanDependency(s.reader().QueryRowContext(ctx,
+		`SELECT `+dependencyColumns+`
 	 FROM dependencies WHERE system=? AND name=? AND version=?`,
 		system, name, version,
-	).Scan(&d.System, &d.Name, &d.Version, &d.Relation, &d.SourceRepo, &d.OpenSSFScore)
-
-	return d, err
+	))
 }
 
 func (s *Storage) ListDependenciesFiltered(ctx context.Context, name string, minScore *float64) ([]Dependency, error) {
+	var list []Dependency
+	err := s.ForEachDependency(ctx, name, minScore, func(d Dependency) error {
+		list = append(list, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ForEachDependency calls fn for every dependency matching the filters of
+// ListDependenciesFiltered, in the same order, straight from the rows cursor.
+// It stops at the first error fn returns.
+func (s *Storage) ForEachDependency(ctx context.Context, name string, minScore *float64, fn func(Dependency) error) error {
 	query := `
-		SELECT system, name, version, relation, source_repo, openssf_score
+		SELECT ` + dependencyColumns + `
 		FROM dependencies
 		WHERE 1=1
 	`
@@ -110,21 +236,22 @@ func (s *Storage) ListDependenciesFiltered(ctx context.Context, name string, min
 
 	query += " ORDER BY system, name, version"
 
-	rows, err := s.DB.QueryContext(ctx, query, args...)
+	rows, err := s.reader().QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var list []Dependency
 	for rows.Next() {
-		var d Dependency
-		if err := rows.Scan(&d.System, &d.Name, &d.Version, &d.Relation, &d.SourceRepo, &d.OpenSSFScore); err != nil {
-			return nil, err
+		d, err := scanDependency(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(d); err != nil {
+			return err
 		}
-		list = append(list, d)
 	}
-	return list, rows.Err()
+	return rows.Err()
 }
 
 func (s *Storage) DeleteDependency(ctx context.Context, system, name, version string) error {
@@ -134,45 +261,53 @@ func (s *Storage) DeleteDependency(ctx context.Context, system, name, version st
 	return err
 }
 
+// Keys per lookup query. Three bound variables per key keeps each query under
+// SQLITE_MAX_VARIABLE_NUMBER even on builds using the old limit of 999.
+const lookupChunkSize = 300
+
+// GetDependenciesMap loads the stored records for deps, keyed by DependencyKey.
+// Keys are looked up in chunks, each matched against the unique
+// (system, name, version) index with a row-value IN.
 func (s *Storage) GetDependenciesMap(ctx context.Context, deps []Dependency) (map[string]Dependency, error) {
-	if len(deps) == 0 {
-		return map[string]Dependency{}, nil
+	result := make(map[string]Dependency, len(deps))
+
+	for start := 0; start < len(deps); start += lookupChunkSize {
+		chunk := deps[start:min(start+lookupChunkSize, len(deps))]
+		if err := s.lookupDependencies(ctx, chunk, result); err != nil {
+			return nil, err
+		}
 	}
 
-	var (
-		args       []any
-		conditions []string
-	)
+	return result, nil
+}
+
+func (s *Storage) lookupDependencies(ctx context.Context, deps []Dependency, result map[string]Dependency) error {
+	args := make([]any, 0, len(deps)*3)
 	for _, dep := range deps {
-		conditions = append(conditions, "(system = ? AND name = ? AND version = ?)")
 		args = append(args, dep.System, dep.Name, dep.Version)
 	}
 
-	query := fmt.Sprintf(`
-		SELECT system, name, version, source_repo, openssf_score, relation
-		FROM dependencies
-		WHERE %s;
-	`, strings.Join(conditions, " OR "))
-
-	rows, err := s.DB.QueryContext(ctx, query, args...)
+	rows, err := s.reader().QueryContext(ctx, lookupDependenciesQuery(len(deps)), args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	result := make(map[string]Dependency)
 	for rows.Next() {
-		var dep Dependency
-		var score sql.NullFloat64
-		if err := rows.Scan(&dep.System, &dep.Name, &dep.Version, &dep.SourceRepo, &score, &dep.Relation); err != nil {
-			return nil, err
-		}
-		if score.Valid {
-			dep.OpenSSFScore = &score.Float64
+		dep, err := scanDependency(rows)
+		if err != nil {
+			return err
 		}
-		key := fmt.Sprintf("%s|%s|%s", dep.System, dep.Name, dep.Version)
-		result[key] = dep
+		result[DependencyKey(dep.System, dep.Name, dep.Version)] = dep
 	}
 
-	return result, nil
+	return rows.Err()
+}
+
+func lookupDependenciesQuery(keys int) string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM dependencies
+		WHERE (system, name, version) IN (VALUES %s);
+	`, dependencyColumns, strings.TrimSuffix(strings.Repeat("(?, ?, ?),", keys), ","))
 }
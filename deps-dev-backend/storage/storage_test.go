@@ -4,13 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"deps-dev/storage"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
-func setupTestDB(t *testing.T) (*sql.DB, *storage.Storage) {
+func setupTestDB(t testing.TB) (*sql.DB, *storage.Storage) {
 	db, err := sql.Open("sqlite3", ":memory:")
 	assert.NoError(t, err)
 
@@ -31,6 +37,7 @@ func TestUpsertAndGetDependency(t *testing.T) {
 		Relation:     "direct",
 		SourceRepo:   "github.com/facebook/react",
 		OpenSSFScore: floatPtr(9.1),
+		License:      "MIT",
 	}
 
 	err := store.UpsertDependency(context.Background(), dep)
@@ -40,6 +47,8 @@ func TestUpsertAndGetDependency(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, dep.System, got.System)
 	assert.Equal(t, *dep.OpenSSFScore, *got.OpenSSFScore)
+	assert.Equal(t, "MIT", got.License)
+	assert.Equal(t, "pkg:npm/react@18.2.0", got.PURL)
 }
 
 func TestListDependencies(t *testing.T) {
@@ -92,6 +101,35 @@ func TestListDependencies(t *testing.T) {
 	})
 }
 
+func TestForEachDependency(t *testing.T) {
+	_, store := setupTestDB(t)
+
+	for _, name := range []string{"react", "express", "lodash"} {
+		assert.NoError(t, store.UpsertDependency(context.Background(), storage.Dependency{System: "npm", Name: name, Version: "1.0.0"}))
+	}
+
+	t.Run("visits rows in list order", func(t *testing.T) {
+		var names []string
+		err := store.ForEachDependency(context.Background(), "", nil, func(d storage.Dependency) error {
+			names = append(names, d.Name)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"express", "lodash", "react"}, names)
+	})
+
+	t.Run("stops at the first callback error", func(t *testing.T) {
+		stop := errors.New("stop")
+		calls := 0
+		err := store.ForEachDependency(context.Background(), "", nil, func(d storage.Dependency) error {
+			calls++
+			return stop
+		})
+		assert.Equal(t, stop, err)
+		assert.Equal(t, 1, calls)
+	})
+}
+
 func TestUpsertDependencies(t *testing.T) {
 	_, store := setupTestDB(t)
 
@@ -146,6 +184,644 @@ func TestGetDependenciesMap(t *testing.T) {
 	assert.Equal(t, dbDep.SourceRepo, m[key].SourceRepo)
 }
 
+func seedDependencies(t testing.TB, store *storage.Storage, n int) []storage.Dependency {
+	deps := make([]storage.Dependency, 0, n)
+	for i := 0; i < n; i++ {
+		deps = append(deps, storage.Dependency{
+			System:  "npm",
+			Name:    fmt.Sprintf("pkg-%d", i),
+			Version: "1.0.0",
+		})
+	}
+	assert.NoError(t, store.UpsertDependencies(context.Background(), deps))
+	return deps
+}
+
+func TestGetDependenciesMap_ManyKeys(t *testing.T) {
+	_, store := setupTestDB(t)
+
+	// Far more keys than fit in a single query's bound variables
+	stored := seedDependencies(t, store, 5000)
+	input := append([]storage.Dependency{}, stored...)
+	for i := 0; i < 1000; i++ {
+		input = append(input, storage.Dependency{System: "npm", Name: fmt.Sprintf("missing-%d", i), Version: "1.0.0"})
+	}
+
+	m, err := store.GetDependenciesMap(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Len(t, m, 5000)
+	assert.Contains(t, m, "npm|pkg-4999|1.0.0")
+	assert.NotContains(t, m, "npm|missing-0|1.0.0")
+}
+
+func TestGetDependenciesMap_UsesIndex(t *testing.T) {
+	db, _ := setupTestDB(t)
+
+	// A full chunk, as GetDependenciesMap queries it
+	args := make([]any, 0, storage.LookupChunkSize*3)
+	for i := 0; i < storage.LookupChunkSize; i++ {
+		args = append(args, "NPM", fmt.Sprintf("pkg-%d", i), "1.0.0")
+	}
+	rows, err := db.Query("EXPLAIN QUERY PLAN "+storage.LookupDependenciesQuery(storage.LookupChunkSize), args...)
+	assert.NoError(t, err)
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		assert.NoError(t, rows.Scan(&id, &parent, &notUsed, &detail))
+		plan = append(plan, detail)
+	}
+	assert.NoError(t, rows.Err())
+	assert.Contains(t, strings.Join(plan, "\n"), "SEARCH dependencies USING INDEX sqlite_autoindex_dependencies_1 (system=? AND name=? AND version=?)")
+	assert.NotContains(t, strings.Join(plan, "\n"), "SCAN dependencies")
+}
+
+func BenchmarkGetDependenciesMap(b *testing.B) {
+	_, store := setupTestDB(b)
+	keys := seedDependencies(b, store, 50000)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m, err := store.GetDependenciesMap(ctx, keys)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(m) != len(keys) {
+			b.Fatalf("expected %d dependencies, got %d", len(keys), len(m))
+		}
+	}
+}
+
+func TestOpenAllowsReadsDuringWrite(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(filepath.Join(t.TempDir(), "app.db"), 2, 1000)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.NoError(t, store.InitSchema(ctx))
+
+	var mode string
+	assert.NoError(t, store.DB.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+
+	assert.NoError(t, store.UpsertDependency(ctx, storage.Dependency{System: "npm", Name: "react", Version: "18.2.0"}))
+
+	// Hold the write transaction open like a long refresh would
+	tx, err := store.DB.BeginTx(ctx, nil)
+	assert.NoError(t, err)
+	_, err = tx.Exec(`INSERT INTO dependencies (system, name, version, relation, source_repo) VALUES ('npm', 'lodash', '4.17.21', '', '')`)
+	assert.NoError(t, err)
+
+	readCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	list, err := store.ListDependenciesFiltered(readCtx, "", nil)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1, "uncommitted rows are not visible to readers")
+
+	assert.NoError(t, tx.Commit())
+
+	list, err = store.ListDependenciesFiltered(ctx, "", nil)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	_, err = store.ReadDB.Exec(`DELETE FROM dependencies`)
+	assert.Error(t, err, "reader connections are read-only")
+}
+
+func TestOpen_EscapesPath(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "deps #1 100%")
+	assert.NoError(t, os.Mkdir(dir, 0o755))
+	path := filepath.Join(dir, "app?.db")
+
+	store, err := storage.Open(path, 2, 1000)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.NoError(t, store.InitSchema(ctx))
+
+	// Both pools open the file at that exact path, in WAL mode
+	var mode string
+	assert.NoError(t, store.DB.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+	assert.NoError(t, store.UpsertDependency(ctx, storage.Dependency{System: "npm", Name: "react", Version: "18.2.0"}))
+	list, err := store.ListDependenciesFiltered(ctx, "", nil)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "app?.db", entries[0].Name())
+}
+
+func TestFetchTimestamps(t *testing.T) {
+	_, store := setupTestDB(t)
+	ctx := context.Background()
+
+	fetchedAt := time.Date(2025, 7, 1, 12, 30, 0, 0, time.UTC)
+	dep := storage.Dependency{
+		System:            "npm",
+		Name:              "react",
+		Version:           "18.2.0",
+		MetadataFetchedAt: &fetchedAt,
+	}
+	assert.NoError(t, store.UpsertDependencies(ctx, []storage.Dependency{dep}))
+
+	got, err := store.GetDependency(ctx, "npm", "react", "18.2.0")
+	assert.NoError(t, err)
+	assert.NotNil(t, got.MetadataFetchedAt)
+	assert.True(t, fetchedAt.Equal(*got.MetadataFetchedAt))
+	assert.Nil(t, got.ScoreFetchedAt)
+
+	m, err := store.GetDependenciesMap(ctx, []storage.Dependency{dep})
+	assert.NoError(t, err)
+	assert.True(t, fetchedAt.Equal(*m["npm|react|18.2.0"].MetadataFetchedAt))
+}
+
+func TestInitSchemaMigratesExistingTable(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	// Schema as created by the first release
+	_, err = db.Exec(`
+	CREATE TABLE dependencies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		system TEXT NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT NOT NULL,
+		relation TEXT,
+		source_repo TEXT,
+		openssf_score REAL,
+		UNIQUE(system, name, version)
+	);
+	INSERT INTO dependencies (system, name, version, relation, source_repo, openssf_score)
+	VALUES ('npm', 'react', '18.2.0', 'SELF', 'github.com/facebook/react', 9.1);`)
+	assert.NoError(t, err)
+
+	store := &storage.Storage{DB: db}
+	assert.NoError(t, store.InitSchema(context.Background()))
+	// Running it again on a migrated database is a no-op
+	assert.NoError(t, store.InitSchema(context.Background()))
+
+	// The system is migrated to its canonical upper case name
+	got, err := store.GetDependency(context.Background(), "NPM", "react", "18.2.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "github.com/facebook/react", got.SourceRepo)
+	assert.Nil(t, got.MetadataFetchedAt)
+	assert.Nil(t, got.ScoreFetchedAt)
+	assert.Empty(t, got.License)
+}
+
+func TestInitSchemaCanonicalizesKeys(t *testing.T) {
+	db, store := setupTestDB(t)
+
+	_, err := db.Exec(`
+	INSERT INTO dependencies (system, name, version, relation, source_repo, openssf_score, license, depth) VALUES
+		('npm', 'react', '18.2.0', '', 'lower', 7.5, 'MIT', 0),
+		('NPM', 'react', '18.2.0', 'SELF', 'canonical', NULL, '', 0),
+		('Npm', 'lodash', '4.17.21', '', 'first', NULL, '', 0),
+		('npm', 'lodash', '4.17.21', '', 'second', NULL, '', 0),
+		('go', 'golang.org/x/mod', 'v0.12.0', '', 'only', NULL, '', 0),
+		('PYPI', 'Zope.Interface', '5.0', 'DIRECT', 'github.com/zopefoundation/zope.interface', 6.1, 'ZPL-2.1', 1),
+		('PYPI', 'zope-interface', '5.0', 'DIRECT', '', NULL, '', 1),
+		('GO', 'github.com/a/b', '1.2.3', 'INDIRECT', '', NULL, 'MIT', 2),
+		('BOWER', 'jquery', '3.7.0', 'DIRECT', '', NULL, '', 1);
+	INSERT INTO fetch_failures (system, name, version, relation, stage, status, attempts, last_error, next_attempt_at, created_at, updated_at) VALUES
+		('npm', 'left-pad', '1.3.0', 'DIRECT', 'METADATA', 'PENDING', 1, 'timeout', '2026-10-18 10:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+		('PYPI', 'Zope_Interface', '5.0', 'DIRECT', 'SCORECARD', 'PENDING', 5, 'project request failed', '2026-10-18 09:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+		('PYPI', 'zope-interface', '5.0', '', 'METADATA', 'EXHAUSTED', 8, 'timeout', '2026-10-18 12:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.InitSchema(context.Background()))
+
+	list, err := store.ListDependenciesFiltered(context.Background(), "", nil)
+	assert.NoError(t, err)
+	got := make(map[string]storage.Dependency)
+	for _, d := range list {
+		got[storage.DependencyKey(d.System, d.Name, d.Version)] = d
+	}
+	assert.Len(t, got, 6)
+	assert.Equal(t, "only", got["GO|golang.org/x/mod|v0.12.0"].SourceRepo)
+	// The oldest spelling is kept if none is canonical
+	assert.Equal(t, "first", got["NPM|lodash|4.17.21"].SourceRepo)
+	assert.Contains(t, got, "BOWER|jquery|3.7.0")
+	assert.Equal(t, "MIT", got["GO|github.com/a/b|v1.2.3"].License)
+
+	// The canonical row wins, the other fills in what it lacks
+	react := got["NPM|react|18.2.0"]
+	assert.Equal(t, "canonical", react.SourceRepo)
+	assert.Equal(t, "SELF", react.Relation)
+	assert.Equal(t, 7.5, *react.OpenSSFScore)
+	assert.Equal(t, "MIT", react.License)
+
+	zope := got["PYPI|zope-interface|5.0"]
+	assert.Equal(t, "github.com/zopefoundation/zope.interface", zope.SourceRepo)
+	assert.Equal(t, 6.1, *zope.OpenSSFScore)
+	assert.Equal(t, "ZPL-2.1", zope.License)
+
+	failures, err := store.ListFetchFailures(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, failures, 2) {
+		assert.Equal(t, storage.FetchFailure{System: "PYPI", Name: "zope-interface", Version: "5.0", Relation: "DIRECT",
+			Stage: "SCORECARD", Status: "PENDING", Attempts: 8, LastError: "project request failed"},
+			storage.FetchFailure{System: failures[0].System, Name: failures[0].Name, Version: failures[0].Version,
+				Relation: failures[0].Relation, Stage: failures[0].Stage, Status: failures[0].Status,
+				Attempts: failures[0].Attempts, LastError: failures[0].LastError})
+		assert.Equal(t, "NPM", failures[1].System)
+	}
+}
+
+func TestRefreshRuns(t *testing.T) {
+	_, store := setupTestDB(t)
+	ctx := context.Background()
+	since := time.Now().Add(-time.Hour)
+
+	run, err := store.CreateRefreshRun(ctx, "NPM", "react", "18.2.0", 3)
+	assert.NoError(t, err)
+	assert.NotZero(t, run.ID)
+
+	// A running run belongs to a refresh still in progress
+	resumable, err := store.ResumableRefreshRun(ctx, "NPM", "react", "18.2.0", since)
+	assert.NoError(t, err)
+	assert.Nil(t, resumable)
+
+	err = store.CheckpointRefreshRun(ctx, run.ID, []storage.Dependency{
+		{System: "NPM", Name: "react", Version: "18.2.0"},
+		{System: "NPM", Name: "loose-envify", Version: "1.4.0"},
+	})
+	assert.NoError(t, err)
+	// Checkpointing the same dependency twice is harmless
+	err = store.CheckpointRefreshRun(ctx, run.ID, []storage.Dependency{
+		{System: "NPM", Name: "loose-envify", Version: "1.4.0"},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.FinishRefreshRun(ctx, run.ID, storage.RunStatusFailed, "db locked"))
+
+	resumable, err = store.ResumableRefreshRun(ctx, "NPM", "react", "18.2.0", since)
+	assert.NoError(t, err)
+	assert.NotNil(t, resumable)
+	assert.Equal(t, run.ID, resumable.ID)
+	assert.Equal(t, 2, resumable.Processed)
+
+	done, err := store.RefreshRunItems(ctx, run.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"NPM|react|18.2.0": true, "NPM|loose-envify|1.4.0": true}, done)
+
+	t.Run("runs started before the window are not resumed", func(t *testing.T) {
+		resumable, err := store.ResumableRefreshRun(ctx, "NPM", "react", "18.2.0", time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Nil(t, resumable)
+	})
+
+	t.Run("a new run abandons failed ones", func(t *testing.T) {
+		next, err := store.CreateRefreshRun(ctx, "NPM", "react", "18.2.0", 3)
+		assert.NoError(t, err)
+
+		resumable, err := store.ResumableRefreshRun(ctx, "NPM", "react", "18.2.0", since)
+		assert.NoError(t, err)
+		assert.Nil(t, resumable)
+
+		assert.NoError(t, store.FinishRefreshRun(ctx, next.ID, storage.RunStatusCompleted, ""))
+
+		resumable, err = store.ResumableRefreshRun(ctx, "NPM", "react", "18.2.0", since)
+		assert.NoError(t, err)
+		assert.Nil(t, resumable)
+	})
+
+	t.Run("a new run leaves running ones alone", func(t *testing.T) {
+		running, err := store.CreateRefreshRun(ctx, "NPM", "vue", "3.4.0", 3)
+		assert.NoError(t, err)
+		_, err = store.CreateRefreshRun(ctx, "NPM", "vue", "3.4.0", 3)
+		assert.NoError(t, err)
+
+		// Both are still running, so a restart marks both as failed and the
+		// latest is resumed
+		interrupted, err := store.FailInterruptedRefreshRuns(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), interrupted)
+
+		resumable, err := store.ResumableRefreshRun(ctx, "NPM", "vue", "3.4.0", since)
+		assert.NoError(t, err)
+		assert.NotNil(t, resumable)
+		assert.NotEqual(t, running.ID, resumable.ID)
+		assert.Equal(t, storage.RunStatusFailed, resumable.Status)
+	})
+}
+
+func TestFetchFailures(t *testing.T) {
+	_, store := setupTestDB(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	failure := storage.FetchFailure{
+		System:        "npm",
+		Name:          "loose-envify",
+		Version:       "1.4.0",
+		Relation:      "DIRECT",
+		Depth:         1,
+		Stage:         storage.FailureStageMetadata,
+		LastError:     "timeout",
+		NextAttemptAt: now.Add(-time.Minute),
+	}
+	assert.NoError(t, store.RecordFetchFailure(ctx, failure))
+	assert.NoError(t, store.RecordFetchFailure(ctx, storage.FetchFailure{
+		System:        "npm",
+		Name:          "js-tokens",
+		Version:       "4.0.0",
+		Stage:         storage.FailureStageScorecard,
+		LastError:     "503",
+		NextAttemptAt: now.Add(time.Hour),
+	}))
+
+	t.Run("recording again counts another attempt", func(t *testing.T) {
+		assert.NoError(t, store.RecordFetchFailure(ctx, failure))
+
+		list, err := store.ListFetchFailures(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+		assert.Equal(t, "loose-envify", list[0].Name)
+		assert.Equal(t, 2, list[0].Attempts)
+		assert.Equal(t, storage.FailureStatusPending, list[0].Status)
+		assert.Equal(t, 1, list[0].Depth)
+	})
+
+	t.Run("only items past their next attempt are due", func(t *testing.T) {
+		due, err := store.DueFetchFailures(ctx, now, 10)
+		assert.NoError(t, err)
+		assert.Len(t, due, 1)
+		assert.Equal(t, "loose-envify", due[0].Name)
+	})
+
+	t.Run("exhausted items are no longer due", func(t *testing.T) {
+		list, err := store.ListFetchFailures(ctx)
+		assert.NoError(t, err)
+
+		exhausted := list[0]
+		exhausted.Status = storage.FailureStatusExhausted
+		exhausted.Attempts = 8
+		assert.NoError(t, store.RescheduleFetchFailure(ctx, exhausted))
+
+		due, err := store.DueFetchFailures(ctx, now, 10)
+		assert.NoError(t, err)
+		assert.Len(t, due, 0)
+	})
+
+	t.Run("clear removes resolved items", func(t *testing.T) {
+		err := store.ClearFetchFailures(ctx, []storage.Dependency{
+			{System: "npm", Name: "loose-envify", Version: "1.4.0"},
+		})
+		assert.NoError(t, err)
+
+		list, err := store.ListFetchFailures(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, "js-tokens", list[0].Name)
+	})
+}
+
 func floatPtr(f float64) *float64 {
 	return &f
 }
+
+func TestPolicyDocument(t *testing.T) {
+	_, store := setupTestDB(t)
+	ctx := context.Background()
+
+	doc, err := store.GetPolicyDocument(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "", doc)
+
+	assert.NoError(t, store.SavePolicyDocument(ctx, "rules: []"))
+	assert.NoError(t, store.SavePolicyDocument(ctx, "rules:\n  - name: deny\n"))
+
+	doc, err = store.GetPolicyDocument(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "rules:\n  - name: deny\n", doc)
+}
+
+func TestReplacePolicyViolations(t *testing.T) {
+	_, store := setupTestDB(t)
+	ctx := context.Background()
+
+	violation := func(rule, name, reason string) storage.PolicyViolation {
+		return storage.PolicyViolation{
+			Rule:       rule,
+			Dependency: storage.Dependency{System: "NPM", Name: name, Version: "1.0.0"},
+			Reason:     reason,
+		}
+	}
+
+	first := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.ReplacePolicyViolations(ctx, []storage.PolicyViolation{
+		violation("min-score", "left-pad", "score 3.1 is below 5"),
+		violation("denied", "event-stream", "package is denied"),
+	}, first))
+
+	second := first.Add(24 * time.Hour)
+	assert.NoError(t, store.ReplacePolicyViolations(ctx, []storage.PolicyViolation{
+		violation("min-score", "left-pad", "score 2.5 is below 5"),
+		violation("min-score", "is-odd", "no OpenSSF score"),
+	}, second))
+
+	list, err := store.ListPolicyViolations(ctx, "")
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "is-odd", list[0].Dependency.Name)
+		assert.True(t, second.Equal(list[0].DetectedAt))
+
+		// Still violating, so the first detection is kept with the latest reason
+		assert.Equal(t, "left-pad", list[1].Dependency.Name)
+		assert.Equal(t, "pkg:npm/left-pad@1.0.0", list[1].Dependency.PURL)
+		assert.Equal(t, "score 2.5 is below 5", list[1].Reason)
+		assert.True(t, first.Equal(list[1].DetectedAt))
+	}
+
+	list, err = store.ListPolicyViolations(ctx, "denied")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestWaivers(t *testing.T) {
+	_, store := setupTestDB(t)
+	ctx := context.Background()
+
+	expires := time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC)
+	created, err := store.CreateWaiver(ctx, storage.Waiver{
+		Rule:       "direct-min-score",
+		System:     "NPM",
+		Name:       "left-pad",
+		Version:    "1.3.0",
+		Reason:     "replacement scheduled",
+		ApprovedBy: "security-team",
+		Ticket:     "SEC-42",
+		ExpiresAt:  expires,
+	})
+	assert.NoError(t, err)
+	assert.NotZero(t, created.ID)
+	assert.True(t, expires.Equal(created.ExpiresAt))
+	assert.False(t, created.CreatedAt.IsZero())
+
+	other, err := store.CreateWaiver(ctx, storage.Waiver{
+		Rule: "denied", System: "NPM", Name: "event-stream", Reason: "fork in use", ApprovedBy: "security-team",
+		ExpiresAt: expires.AddDate(0, -1, 0),
+	})
+	assert.NoError(t, err)
+
+	list, err := store.ListWaivers(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		// Soonest expiring first
+		assert.Equal(t, other.ID, list[0].ID)
+		assert.Equal(t, "", list[0].Version)
+		assert.Equal(t, created, list[1])
+	}
+
+	created.Ticket = "SEC-43"
+	updated, err := store.UpdateWaiver(ctx, created)
+	assert.NoError(t, err)
+	assert.Equal(t, "SEC-43", updated.Ticket)
+	assert.True(t, created.CreatedAt.Equal(updated.CreatedAt))
+
+	assert.NoError(t, store.DeleteWaiver(ctx, created.ID))
+	_, err = store.GetWaiver(ctx, created.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = store.UpdateWaiver(ctx, created)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.ErrorIs(t, store.DeleteWaiver(ctx, created.ID), sql.ErrNoRows)
+}
+
+func TestImportDataset(t *testing.T) {
+	db, store := setupTestDB(t)
+	ctx := context.Background()
+	importRows := func(packages []storage.DatasetPackage, scores []storage.ProjectScore) error {
+		return store.ImportDataset(ctx, func(stage storage.DatasetStager) error {
+			return stage(packages, scores)
+		})
+	}
+
+	err := importRows([]storage.DatasetPackage{
+		{System: "NPM", Name: "react", SourceRepo: "github.com/facebook/react", License: "MIT"},
+		{System: "NPM", Name: "react", Version: "0.14.0", License: "BSD-3-Clause"},
+	}, []storage.ProjectScore{
+		{ProjectID: "github.com/Facebook/React", OpenSSFScore: 6.8},
+	})
+	assert.NoError(t, err)
+
+	pkg, err := store.GetDatasetPackage(ctx, "NPM", "react", "18.2.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "github.com/facebook/react", pkg.SourceRepo)
+	assert.Equal(t, "", pkg.Version)
+
+	pkg, err = store.GetDatasetPackage(ctx, "NPM", "react", "0.14.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "BSD-3-Clause", pkg.License)
+
+	_, err = store.GetDatasetPackage(ctx, "NPM", "vue", "3.0.0")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	score, err := store.GetProjectScore(ctx, "github.com/facebook/react")
+	assert.NoError(t, err)
+	assert.Equal(t, 6.8, score)
+
+	// A later import without a license keeps the stored one
+	err = importRows([]storage.DatasetPackage{
+		{System: "NPM", Name: "react", SourceRepo: "github.com/react/react"},
+	}, []storage.ProjectScore{{ProjectID: "github.com/facebook/react", OpenSSFScore: 7.1}})
+	assert.NoError(t, err)
+
+	pkg, err = store.GetDatasetPackage(ctx, "NPM", "react", "18.2.0")
+	assert.NoError(t, err)
+	assert.Equal(t, storage.DatasetPackage{System: "NPM", Name: "react", SourceRepo: "github.com/react/react", License: "MIT"}, pkg)
+	score, err = store.GetProjectScore(ctx, "github.com/facebook/react")
+	assert.NoError(t, err)
+	assert.Equal(t, 7.1, score)
+
+	t.Run("a failed import stores nothing", func(t *testing.T) {
+		err := store.ImportDataset(ctx, func(stage storage.DatasetStager) error {
+			err := stage([]storage.DatasetPackage{{System: "NPM", Name: "vue", Version: "3.0.0", License: "MIT"}},
+				[]storage.ProjectScore{{ProjectID: "github.com/facebook/react", OpenSSFScore: 1}})
+			assert.NoError(t, err)
+			return errors.New("line 1001: invalid row")
+		})
+		assert.EqualError(t, err, "line 1001: invalid row")
+
+		_, err = store.GetDatasetPackage(ctx, "NPM", "vue", "3.0.0")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		score, err := store.GetProjectScore(ctx, "github.com/facebook/react")
+		assert.NoError(t, err)
+		assert.Equal(t, 7.1, score)
+
+		var staged int
+		assert.NoError(t, db.QueryRow(`SELECT (SELECT COUNT(*) FROM dataset_staged_packages) + (SELECT COUNT(*) FROM dataset_staged_scores)`).Scan(&staged))
+		assert.Zero(t, staged)
+	})
+}
+
+func TestRefreshSchedules(t *testing.T) {
+	_, store := setupTestDB(t)
+	ctx := context.Background()
+
+	created, err := store.CreateRefreshSchedule(ctx, storage.RefreshSchedule{
+		System: "NPM", Name: "react", Version: "18.2.0", Cron: "@hourly", Timezone: "UTC", Enabled: true,
+	})
+	assert.NoError(t, err)
+	assert.NotZero(t, created.ID)
+	assert.True(t, created.Enabled)
+	assert.Nil(t, created.LastRunAt)
+	assert.Nil(t, created.NextRunAt)
+
+	_, err = store.CreateRefreshSchedule(ctx, storage.RefreshSchedule{
+		System: "NPM", Name: "react", Version: "18.2.0", Cron: "@daily", Timezone: "UTC",
+	})
+	assert.ErrorIs(t, err, storage.ErrScheduleExists)
+
+	archived, err := store.CreateRefreshSchedule(ctx, storage.RefreshSchedule{
+		System: "NPM", Name: "left-pad", Version: "1.3.0", Cron: "0 3 * * 1", Timezone: "Europe/Berlin",
+	})
+	assert.NoError(t, err)
+	assert.False(t, archived.Enabled)
+
+	// Runs are recorded, and kept when the schedule changes
+	ranAt := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	next := ranAt.Add(time.Hour)
+	assert.NoError(t, store.RecordScheduleRun(ctx, created.ID, ranAt, errors.New("deps.dev unavailable")))
+	assert.NoError(t, store.SetScheduleNextRun(ctx, created.ID, &next))
+	created.Cron = "*/30 * * * *"
+	updated, err := store.UpdateRefreshSchedule(ctx, created)
+	assert.NoError(t, err)
+	assert.Equal(t, "*/30 * * * *", updated.Cron)
+	assert.True(t, ranAt.Equal(*updated.LastRunAt))
+	assert.Equal(t, storage.ScheduleRunFailed, updated.LastStatus)
+	assert.Equal(t, "deps.dev unavailable", updated.LastError)
+	assert.True(t, next.Equal(*updated.NextRunAt))
+
+	assert.NoError(t, store.RecordScheduleRun(ctx, created.ID, ranAt.Add(time.Hour), nil))
+	assert.NoError(t, store.SetScheduleNextRun(ctx, created.ID, nil))
+	got, err := store.GetRefreshSchedule(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.ScheduleRunSucceeded, got.LastStatus)
+	assert.Equal(t, "", got.LastError)
+	assert.Nil(t, got.NextRunAt)
+
+	archived.Name = "react"
+	archived.Version = "18.2.0"
+	_, err = store.UpdateRefreshSchedule(ctx, archived)
+	assert.ErrorIs(t, err, storage.ErrScheduleExists)
+
+	list, err := store.ListRefreshSchedules(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "left-pad", list[0].Name)
+		assert.Equal(t, got, list[1])
+	}
+
+	assert.NoError(t, store.DeleteRefreshSchedule(ctx, created.ID))
+	_, err = store.GetRefreshSchedule(ctx, created.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = store.UpdateRefreshSchedule(ctx, created)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.ErrorIs(t, store.DeleteRefreshSchedule(ctx, created.ID), sql.ErrNoRows)
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Expired waivers are kept as a record of past exceptions
+const createWaiversTable = `
+	CREATE TABLE IF NOT EXISTS waivers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule TEXT NOT NULL,
+		system TEXT NOT NULL,
+		name TEXT NOT NULL,
+		version TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL,
+		approved_by TEXT NOT NULL,
+		ticket TEXT NOT NULL DEFAULT '',
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
+const selectWaiverColumns = `
+	SELECT id, rule, system, name, version, reason, approved_by, ticket, expires_at, created_at, updated_at
+	FROM waivers
+`
+
+// CreateWaiver stores a new waiver and returns it with its ID and timestamps.
+func (s *Storage) CreateWaiver(ctx context.Context, w Waiver) (Waiver, error) {
+	now := time.Now().UTC()
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO waivers (rule, system, name, version, reason, approved_by, ticket, expires_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		w.Rule, w.System, w.Name, w.Version, w.Reason, w.ApprovedBy, w.Ticket, w.ExpiresAt.UTC(), now, now,
+	)
+	if err != nil {
+		return Waiver{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Waiver{}, err
+	}
+	return s.GetWaiver(ctx, id)
+}
+
+// GetWaiver returns the waiver with the given ID, or sql.ErrNoRows.
+func (s *Storage) GetWaiver(ctx context.Context, id int64) (Waiver, error) {
+	return scanWaiver(s.reader().QueryRowContext(ctx, selectWaiverColumns+" WHERE id = ?", id))
+}
+
+// UpdateWaiver replaces the waiver with w.ID and returns it, or sql.ErrNoRows
+// if there is none.
+func (s *Storage) UpdateWaiver(ctx context.Context, w Waiver) (Waiver, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE waivers
+		SET rule = ?, system = ?, name = ?, version = ?, reason = ?, approved_by = ?, ticket = ?, expires_at = ?, updated_at = ?
+		WHERE id = ?`,
+		w.Rule, w.System, w.Name, w.Version, w.Reason, w.ApprovedBy, w.Ticket, w.ExpiresAt.UTC(), time.Now().UTC(), w.ID,
+	)
+	if err != nil {
+		return Waiver{}, err
+	}
+	if err := expectRow(res); err != nil {
+		return Waiver{}, err
+	}
+	return s.GetWaiver(ctx, w.ID)
+}
+
+// DeleteWaiver removes a waiver, or returns sql.ErrNoRows if there is none.
+func (s *Storage) DeleteWaiver(ctx context.Context, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM waivers WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+// ListWaivers lists all waivers, expired ones included, soonest expiring first.
+func (s *Storage) ListWaivers(ctx context.Context) ([]Waiver, error) {
+	rows, err := s.reader().QueryContext(ctx, selectWaiverColumns+" ORDER BY expires_at, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Waiver{}
+	for rows.Next() {
+		w, err := scanWaiver(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, w)
+	}
+	return list, rows.Err()
+}
+
+func scanWaiver(row rowScanner) (Waiver, error) {
+	var w Waiver
+	err := row.Scan(&w.ID, &w.Rule, &w.System, &w.Name, &w.Version, &w.Reason, &w.ApprovedBy, &w.Ticket,
+		&w.ExpiresAt, &w.CreatedAt, &w.UpdatedAt)
+	return w, err
+}
+
+func expectRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -25,7 +25,7 @@ function DependencyTable({ dependencies, onEdit, onDelete }) {
           <tr
             key={`${dep.system}-${dep.name}-${dep.version}`}
             className="cursor-pointer hover:bg-gray-100 divide-x divide-gray-200"
-            onClick={() => navigate(`/dependencies/${dep.system}/${dep.name}/${dep.version}`)}
+            onClick={() => navigate(`/dependencies/${dep.system}/${encodeURIComponent(dep.name)}/${dep.version}`)}
           >
             <td>{dep.system}</td>
             <td>{dep.name}</td>